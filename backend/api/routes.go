@@ -1,31 +1,54 @@
 package api
 
 import (
+	"context"
 	"net/http"
+	"time"
+	"valorant-mobile-web/backend/internal/config"
+	"valorant-mobile-web/backend/internal/database"
 	"valorant-mobile-web/backend/internal/handlers"
+	"valorant-mobile-web/backend/internal/logging"
+	"valorant-mobile-web/backend/internal/metrics"
+	"valorant-mobile-web/backend/internal/middleware"
 	"valorant-mobile-web/backend/internal/services"
 
 	"github.com/gorilla/mux"
 )
 
-func SetupRoutes() *mux.Router {
+// NewServer builds the services and handlers of the API from cfg, which
+// must have been validated, restores the state saved by the last shutdown
+// and starts the background jobs
+func NewServer(cfg *config.Config) *Server {
 	router := mux.NewRouter()
+	configLogger := logging.Component("config")
+
+	// Request IDs and the caller are added to everything logged for a request
+	router.Use(middleware.RequestLogging(logging.Component("http")))
+	router.Use(middleware.RequestMetrics)
 
 	// CORS middleware mejorado - aplicado globalmente
+	allowAnyOrigin := false
+	allowedOrigins := make(map[string]bool)
+	for _, origin := range cfg.Server.CORSOrigins {
+		allowAnyOrigin = allowAnyOrigin || origin == "*"
+		allowedOrigins[origin] = true
+	}
 	router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Configurar headers CORS
-			w.Header().Set("Access-Control-Allow-Origin", "*")
+			if allowAnyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else if origin := r.Header.Get("Origin"); allowedOrigins[origin] {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			w.Header().Add("Vary", "Origin")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-User-ID, X-Username, X-User-ELO, Accept, Origin, X-Requested-With")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-User-ID, X-Username, X-User-ELO, X-Admin-Token, Idempotency-Key, Accept, Origin, X-Requested-With")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag, Idempotent-Replayed")
 			w.Header().Set("Access-Control-Max-Age", "3600")
 
-			// Log para debug
-			println("CORS: Request Method:", r.Method, "URL:", r.URL.Path)
-
 			// Manejar preflight requests
 			if r.Method == "OPTIONS" {
-				println("CORS: Handling OPTIONS preflight request")
 				w.WriteHeader(http.StatusOK)
 				return
 			}
@@ -34,25 +57,245 @@ func SetupRoutes() *mux.Router {
 		})
 	})
 
-	// Initialize shared services (SINGLETONS)
-	queueService := services.NewQueueService()
-	matchRoomService := services.NewMatchRoomServiceWithQueue(queueService)
-	matchAcceptanceService := services.NewMatchAcceptanceService()
+	// Settings were validated at startup, see config.Validate
+	placementMatches := cfg.Rating.PlacementMatches
+	ratingEngine, err := services.NewRatingEngine(cfg.Rating.Engine, placementMatches, cfg.Rating.KFactor)
+	if err != nil {
+		configLogger.Error("invalid rating engine, falling back to elo", "error", err)
+		ratingEngine = services.NewELORatingEngine(placementMatches, cfg.Rating.KFactor)
+	}
+	ratingMode, err := services.ParseRatingMode(cfg.Rating.Mode)
+	if err != nil {
+		configLogger.Error("invalid rating mode, falling back to individual", "error", err)
+		ratingMode = services.RatingModeIndividual
+	}
+
+	seasonOptions := services.SeasonOptions{
+		Length:          config.Days(cfg.Season.LengthDays),
+		SoftResetFactor: cfg.Season.SoftReset,
+	}
+
+	tierOptions := services.TierOptions{
+		RadiantSlots:     cfg.Tiers.RadiantSlots,
+		ShieldGames:      cfg.Tiers.DemotionShieldGames,
+		PlacementMatches: placementMatches,
+	}
+	if cfg.Tiers.Table != "" {
+		if tiers, err := services.ParseTierTable([]byte(cfg.Tiers.Table)); err == nil {
+			tierOptions.Tiers = tiers
+		} else {
+			configLogger.Error("invalid tier table, using default tiers", "error", err)
+		}
+	}
+
+	decayOptions := services.DecayOptions{
+		MinRating:       cfg.Decay.MinRating,
+		Inactive:        config.Days(cfg.Decay.InactiveDays),
+		WeeklyAmount:    cfg.Decay.WeeklyAmount,
+		WeeklyDeviation: cfg.Decay.WeeklyDeviation,
+		Floor:           cfg.Decay.Floor,
+		Warning:         config.Days(cfg.Decay.WarningDays),
+	}
+
+	matchRoomOptions := services.MatchRoomOptions{
+		AcceptTimeout:          config.Seconds(cfg.Match.AcceptSeconds),
+		LobbyJoinTimeout:       config.Seconds(cfg.Match.LobbyJoinSeconds),
+		SubstituteOfferTimeout: config.Seconds(cfg.Match.SubstituteOfferSeconds),
+	}
+
+	// Initialize shared services (SINGLETONS), each logging as its own
+	// component so LOG_LEVELS can turn them up or down separately
+	queueService := services.NewQueueService(logging.Component("queue"))
+	matchRoomService := services.NewMatchRoomServiceWithQueue(queueService, matchRoomOptions, logging.Component("match_room"))
+	matchAcceptanceService := services.NewMatchAcceptanceServiceWithServices(matchRoomService, queueService, logging.Component("match_acceptance"))
+	tierService := services.NewTierService(database.DB, tierOptions, logging.Component("tier"))
+	seasonService := services.NewSeasonService(database.DB, tierService, seasonOptions, logging.Component("season"))
+	ratingHistoryService := services.NewRatingHistoryService(database.DB, logging.Component("rating"))
+	ratingService := services.NewRatingService(database.DB, ratingEngine, seasonService, ratingHistoryService, services.RatingOptions{
+		Mode:             ratingMode,
+		MarginFactor:     cfg.Rating.MarginFactor,
+		PlacementMatches: placementMatches,
+		SubstituteStakes: cfg.Rating.SubstituteStakes,
+	}, logging.Component("rating"))
+	notificationService := services.NewNotificationService(database.DB)
+	matchArchiveService := services.NewMatchArchiveService(database.DB, logging.Component("match"))
+	matchStatsService := services.NewMatchStatsService(database.DB, logging.Component("stats"))
+	abandonService := services.NewAbandonService(database.DB)
+	friendService := services.NewFriendService(database.DB)
+	riotClient := services.NewFixtureRiotClient(cfg.Riot.FixtureDir)
+	riotImportService := services.NewRiotImportService(database.DB, riotClient)
+	riotAccountService := services.NewRiotAccountService(database.DB, riotClient, logging.Component("riot"))
+	decayService := services.NewDecayService(database.DB, ratingEngine, notificationService, ratingHistoryService, tierService, decayOptions, logging.Component("decay"))
+	leaderboardIndex := services.NewLeaderboardIndex(database.DB, placementMatches)
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	server := &Server{
+		Router:       router,
+		queueService: queueService,
+		logger:       logging.Component("server"),
+		jobsCtx:      jobsCtx,
+		stop:         stopJobs,
+	}
+
+	// Share the queue and match rooms with the other instances, if any
+	coordinator, err := services.NewCoordinator(cfg.Cluster.Coordination, database.DB, cfg.Database.URL, logging.Component("cluster"))
+	if err != nil {
+		configLogger.Error("could not start coordination, keeping state to this instance", "backend", cfg.Cluster.Coordination, "error", err)
+	}
+	if coordinator != nil {
+		queueService.Share(coordinator)
+		matchRoomService.Share(coordinator)
+		server.coordinator = coordinator
+	} else {
+		// Alone, the queue and match rooms are saved on shutdown and
+		// brought back by the next start
+		server.runtimeState = services.NewRuntimeStateService(database.DB, queueService, matchRoomService, logging.Component("runtime_state"))
+		if err := server.runtimeState.Restore(context.Background()); err != nil {
+			server.logger.Error("could not restore runtime state", "error", err)
+		}
+	}
+
+	// Queue depth and connection pool usage are read on every scrape
+	metrics.NewGaugeFunc("valorant_queue_depth", "Players waiting in each queue.", func() []metrics.Sample {
+		queued, substitutes := queueService.Depths()
+		return []metrics.Sample{
+			{LabelValues: []string{metrics.QueueMain}, Value: float64(queued)},
+			{LabelValues: []string{metrics.QueueSubstitute}, Value: float64(substitutes)},
+		}
+	}, "queue")
+	metrics.RegisterDBStats(database.DB)
+
+	leaderboardLogger := logging.Component("leaderboard")
+	if err := tierService.RefreshRadiant(); err != nil {
+		logging.Component("tier").Warn("could not refresh radiant players", "error", err)
+	}
+
+	// Start the first season and roll over seasons whose end date has passed
+	seasonLogger := logging.Component("season")
+	season, err := seasonService.EnsureCurrentSeason(time.Now())
+	if err != nil {
+		seasonLogger.Warn("could not start current season", "error", err)
+	}
+	server.every(time.Hour, func() {
+		current, err := seasonService.EnsureCurrentSeason(time.Now())
+		if err != nil {
+			seasonLogger.Error("could not check season rollover", "error", err)
+			return
+		}
+		// A rollover soft resets every rating
+		if season != nil && current.ID != season.ID {
+			if _, err := leaderboardIndex.Rebuild(); err != nil {
+				leaderboardLogger.Error("could not rebuild leaderboard", "reason", "season rollover", "error", err)
+			}
+		}
+		season = current
+	})
+
+	// Warn inactive players and apply due rating decay
+	server.every(time.Hour, func() {
+		result, err := decayService.Run(time.Now())
+		if err != nil {
+			logging.Component("decay").Error("could not run rating decay", "error", err)
+			return
+		}
+		if result.Decayed > 0 {
+			if _, err := leaderboardIndex.Rebuild(); err != nil {
+				leaderboardLogger.Error("could not rebuild leaderboard", "reason", "rating decay", "error", err)
+			}
+		}
+	})
+
+	// Build the leaderboard index and reconcile it with the database every
+	// leaderboard.reconcile_seconds to pick up changes made outside the API
+	if _, err := leaderboardIndex.Rebuild(); err != nil {
+		leaderboardLogger.Warn("could not build leaderboard index, ranking from the database", "error", err)
+	}
+	reconcileInterval := config.Seconds(cfg.Leaderboard.ReconcileSeconds)
+	if reconcileInterval > 0 {
+		server.every(reconcileInterval, func() {
+			drift, err := leaderboardIndex.Rebuild()
+			if err != nil {
+				leaderboardLogger.Error("could not reconcile leaderboard", "error", err)
+				return
+			}
+			if drift > 0 {
+				leaderboardLogger.Info("leaderboard reconciled", "out_of_date", drift)
+			}
+		})
+	}
+
+	// Matches whose archive failed when they completed
+	server.every(5*time.Minute, func() {
+		archived, err := matchArchiveService.RetryPendingArchives()
+		if err != nil {
+			logging.Component("match").Error("could not retry match archives", "error", err)
+		}
+		if archived > 0 {
+			logging.Component("match").Info("pending match archives written", "archived", archived)
+		}
+	})
+
+	// Close captain votes, lobby check-ins and substitute offers whose
+	// deadline passed even if nobody acts on the match again
+	server.every(5*time.Second, func() {
+		matchRoomService.CloseExpiredCaptainVotes()
+		matchRoomService.ExpireSubstituteOffers(time.Now())
+		// Lobby no-shows count as abandons, like players who left
+		for _, noShow := range matchRoomService.ExpireLobbyCheckIns(time.Now()) {
+			if err := abandonService.RecordAbandon(noShow.MatchID, noShow.Substitution); err != nil {
+				logging.Component("match_room").Error("could not record lobby no-show",
+					"match_id", noShow.MatchID, "user_id", noShow.Substitution.LeaverID, "error", err)
+			}
+		}
+	})
+
+	// Responses to requests carrying an Idempotency-Key are replayed to retries
+	idempotencyLogger := logging.Component("idempotency")
+	idempotencyStore, err := services.NewIdempotencyStore(cfg.Idempotency.Store, database.DB)
+	if err != nil {
+		configLogger.Error("invalid idempotency store, falling back to memory", "error", err)
+		idempotencyStore = services.NewMemoryIdempotencyStore()
+	}
+	idempotent := func(handler http.HandlerFunc) http.Handler {
+		return middleware.Idempotency(idempotencyStore, config.Seconds(cfg.Idempotency.WindowSeconds), idempotencyLogger)(handler)
+	}
+	server.every(time.Hour, func() {
+		if _, err := idempotencyStore.Prune(context.Background(), time.Now()); err != nil {
+			idempotencyLogger.Error("could not prune idempotency keys", "error", err)
+		}
+	})
 
 	// Initialize handlers with shared services
-	queueHandler := handlers.NewQueueHandlerWithService(queueService)
-	matchRoomHandler := handlers.NewMatchRoomHandlerWithServices(matchRoomService, queueService)
-	matchAcceptanceHandler := handlers.NewMatchAcceptanceHandlerWithService(matchAcceptanceService)
-	leaderboardHandler := handlers.NewLeaderboardHandler()
-	authHandler := handlers.NewAuthHandler()
+	queueHandler := handlers.NewQueueHandlerWithServices(queueService, ratingService, tierService, riotAccountService, logging.Component("queue"))
+	matchRoomHandler := handlers.NewMatchRoomHandlerWithServices(matchRoomService, queueService, abandonService, logging.Component("match_room"))
+	matchAcceptanceHandler := handlers.NewMatchAcceptanceHandlerWithService(matchAcceptanceService, logging.Component("match_acceptance"))
+	matchHandler := handlers.NewMatchHandlerWithServices(matchRoomService, ratingService, tierService, matchArchiveService, riotImportService, leaderboardIndex, logging.Component("match"))
+	leaderboardHandler := handlers.NewLeaderboardHandlerWithServices(ratingService, seasonService, tierService, matchStatsService, leaderboardIndex, leaderboardLogger)
+	seasonHandler := handlers.NewSeasonHandlerWithService(seasonService)
+	notificationHandler := handlers.NewNotificationHandlerWithService(notificationService)
+	statsHandler := handlers.NewStatsHandlerWithService(matchStatsService)
+	riotAccountHandler := handlers.NewRiotAccountHandlerWithService(riotAccountService)
+	friendHandler := handlers.NewFriendHandlerWithService(friendService)
+	// An admin token enables the admin endpoints
+	ratingHistoryHandler := handlers.NewRatingHistoryHandlerWithServices(ratingHistoryService, leaderboardIndex, cfg.Auth.AdminToken, logging.Component("rating"))
+	authHandler := handlers.NewAuthHandler(cfg.Auth.JWTSecret, logging.Component("auth"))
 
-	// Health check endpoint
+	// Health check endpoint, failing while draining so load balancers stop
+	// sending players here
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+		if server.Draining() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status": "draining", "message": "server restarting"}`))
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status": "ok", "message": "Valorant Backend API is running"}`))
 	}).Methods("GET")
 
+	// Prometheus scrape endpoint
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
 	// API routes
 	api := router.PathPrefix("/api").Subrouter()
 
@@ -61,24 +304,68 @@ func SetupRoutes() *mux.Router {
 	api.HandleFunc("/auth/login", authHandler.Login).Methods("POST", "OPTIONS")
 
 	// Queue endpoints
-	api.HandleFunc("/queue/join", queueHandler.JoinQueue).Methods("POST", "OPTIONS")
-	api.HandleFunc("/queue/leave", queueHandler.LeaveQueue).Methods("POST", "OPTIONS")
+	api.Handle("/queue/join", idempotent(queueHandler.JoinQueue)).Methods("POST", "OPTIONS")
+	api.Handle("/queue/leave", idempotent(queueHandler.LeaveQueue)).Methods("POST", "OPTIONS")
 	api.HandleFunc("/queue/status", queueHandler.GetQueueStatus).Methods("GET", "OPTIONS")
 
 	// Match room endpoints
 	api.HandleFunc("/match-room/create", matchRoomHandler.CreateMatchRoom).Methods("POST", "OPTIONS")
 	api.HandleFunc("/match-room/debug", matchRoomHandler.DebugMatchRoom).Methods("GET", "OPTIONS")
+	api.HandleFunc("/match-room/substitute-offer", matchRoomHandler.GetSubstituteOffer).Methods("GET", "OPTIONS")
 	api.HandleFunc("/match-room/{matchId}", matchRoomHandler.GetMatchRoom).Methods("GET", "OPTIONS")
 	api.HandleFunc("/match-room/player", matchRoomHandler.GetPlayerMatchRoom).Methods("GET", "OPTIONS")
 	api.HandleFunc("/match-room/{matchId}/captain-selection", matchRoomHandler.SetCaptainSelectionMethod).Methods("POST", "OPTIONS")
-	api.HandleFunc("/match-room/{matchId}/vote-captain", matchRoomHandler.VoteForCaptain).Methods("POST", "OPTIONS")
+	api.Handle("/match-room/{matchId}/vote-captain", idempotent(matchRoomHandler.VoteForCaptain)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/match-room/{matchId}/lobby", matchRoomHandler.GetLobby).Methods("GET", "OPTIONS")
+	api.HandleFunc("/match-room/{matchId}/lobby/host", matchRoomHandler.SetLobbyHost).Methods("POST", "OPTIONS")
+	api.HandleFunc("/match-room/{matchId}/lobby/code", matchRoomHandler.PostPartyCode).Methods("POST", "OPTIONS")
+	api.HandleFunc("/match-room/{matchId}/lobby/check-in", matchRoomHandler.CheckInLobby).Methods("POST", "OPTIONS")
+	api.HandleFunc("/match-room/{matchId}/substitute", matchRoomHandler.RequestSubstitute).Methods("POST", "OPTIONS")
+	api.HandleFunc("/match-room/{matchId}/substitute/accept", matchRoomHandler.AcceptSubstitute).Methods("POST", "OPTIONS")
+	api.HandleFunc("/match-room/{matchId}/substitute/decline", matchRoomHandler.DeclineSubstitute).Methods("POST", "OPTIONS")
 
 	// Match acceptance endpoints
-	api.HandleFunc("/match/{id}/accept", matchAcceptanceHandler.AcceptMatch).Methods("POST", "OPTIONS")
-	api.HandleFunc("/match/{id}/decline", matchAcceptanceHandler.DeclineMatch).Methods("POST", "OPTIONS")
+	api.Handle("/match/{id}/accept", idempotent(matchAcceptanceHandler.AcceptMatch)).Methods("POST", "OPTIONS")
+	api.Handle("/match/{id}/decline", idempotent(matchAcceptanceHandler.DeclineMatch)).Methods("POST", "OPTIONS")
+	api.Handle("/match/result", idempotent(matchHandler.ReportResult)).Methods("POST", "OPTIONS")
+
+	// Match history endpoints
+	api.HandleFunc("/matches/{id}", matchHandler.GetMatch).Methods("GET", "OPTIONS")
+	api.HandleFunc("/matches/{id}/import", matchHandler.ImportMatch).Methods("POST", "OPTIONS")
+	api.HandleFunc("/users/{id}/matches", matchHandler.GetUserMatches).Methods("GET", "OPTIONS")
+
+	// Stats endpoints
+	api.HandleFunc("/matches/{id}/stats", statsHandler.SubmitMatchStats).Methods("POST", "OPTIONS")
+	api.HandleFunc("/users/{id}/stats", statsHandler.GetUserStats).Methods("GET", "OPTIONS")
 
 	// Leaderboard endpoints
 	api.HandleFunc("/leaderboard", leaderboardHandler.GetLeaderboard).Methods("GET", "OPTIONS")
+	api.HandleFunc("/leaderboard/rank", leaderboardHandler.GetUserRank).Methods("GET", "OPTIONS")
+	api.HandleFunc("/leaderboard/{name}", leaderboardHandler.GetNamedLeaderboard).Methods("GET", "OPTIONS")
+	api.HandleFunc("/leaderboards", leaderboardHandler.ListLeaderboards).Methods("GET", "OPTIONS")
+
+	// Friends endpoints
+	api.HandleFunc("/friends", friendHandler.GetFriends).Methods("GET", "OPTIONS")
+	api.HandleFunc("/friends", friendHandler.AddFriend).Methods("POST")
+	api.HandleFunc("/friends/{id}", friendHandler.RemoveFriend).Methods("DELETE", "OPTIONS")
+
+	// Season endpoints
+	api.HandleFunc("/seasons", seasonHandler.ListSeasons).Methods("GET", "OPTIONS")
+	api.HandleFunc("/seasons/current", seasonHandler.GetCurrentSeason).Methods("GET", "OPTIONS")
+	api.HandleFunc("/users/{id}/seasons", seasonHandler.GetUserSeasons).Methods("GET", "OPTIONS")
+
+	// Rating history endpoints
+	api.HandleFunc("/users/{id}/rating-history", ratingHistoryHandler.GetUserRatingHistory).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/users/{id}/rating-adjustment", ratingHistoryHandler.AdjustRating).Methods("POST", "OPTIONS")
+
+	// Riot ID endpoints
+	api.HandleFunc("/riot/link", riotAccountHandler.LinkRiotID).Methods("POST", "OPTIONS")
+	api.HandleFunc("/riot/link", riotAccountHandler.UnlinkRiotID).Methods("DELETE")
+	api.HandleFunc("/riot/verify", riotAccountHandler.VerifyRiotID).Methods("POST", "OPTIONS")
+	api.HandleFunc("/users/{id}/riot-id", riotAccountHandler.GetUserRiotID).Methods("GET", "OPTIONS")
+
+	// Notification endpoints
+	api.HandleFunc("/users/{id}/notifications", notificationHandler.GetUserNotifications).Methods("GET", "OPTIONS")
 
-	return router
+	return server
 }
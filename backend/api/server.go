@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+	"valorant-mobile-web/backend/internal/services"
+)
+
+// Server is the API with its shared services and background jobs
+type Server struct {
+	Router http.Handler
+
+	queueService *services.QueueService
+	runtimeState *services.RuntimeStateService // Only when the state isn't shared
+	coordinator  services.Coordinator          // Only when the state is shared
+	draining     atomic.Bool
+	logger       *slog.Logger
+
+	// Background jobs run until stop is called, see every
+	jobsCtx context.Context
+	stop    context.CancelFunc
+	jobs    sync.WaitGroup
+}
+
+// every runs job every interval in the background until the server shuts
+// down. A job that is running when the server shuts down is allowed to finish.
+func (s *Server) every(interval time.Duration, job func()) {
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.jobsCtx.Done():
+				return
+			case <-ticker.C:
+				job()
+			}
+		}
+	}()
+}
+
+// Drain stops accepting new queue joins and reports the server as
+// unhealthy so load balancers send players elsewhere. Requests that are
+// already running, and every other endpoint, keep working.
+func (s *Server) Drain() {
+	if s.draining.Swap(true) {
+		return
+	}
+	s.queueService.Close()
+	s.logger.Info("draining, no longer accepting queue joins")
+}
+
+// Draining reports whether Drain was called
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// Shutdown stops the background jobs and saves the queue and match rooms
+// for the next start, unless they are shared with other instances, which
+// keep them. The HTTP server must have been shut down first so no request
+// changes them while they are saved. It gives up when ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Drain()
+
+	s.stop()
+	stopped := make(chan struct{})
+	go func() {
+		s.jobs.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		s.logger.Info("background jobs stopped")
+	case <-ctx.Done():
+		return fmt.Errorf("background jobs did not stop: %w", ctx.Err())
+	}
+
+	if s.coordinator != nil {
+		return s.coordinator.Close()
+	}
+	return s.runtimeState.Save(ctx)
+}
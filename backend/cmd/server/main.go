@@ -1,25 +1,146 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"valorant-mobile-web/backend/api"
+	"valorant-mobile-web/backend/internal/config"
 	"valorant-mobile-web/backend/internal/database"
+	"valorant-mobile-web/backend/internal/logging"
+
+	"gopkg.in/yaml.v3"
 )
 
+const usage = `Usage: server [-config file] [command]
+
+Commands:
+  (none)        start the API server
+  config print  print the effective configuration with secrets masked
+
+Settings come from the defaults, then the YAML or TOML file given with
+-config or CONFIG_FILE, then environment variables and a .env file.
+`
+
 func main() {
-	// Initialize database connection
-	err := database.Connect()
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
 	if err != nil {
-		log.Fatalf("Could not connect to the database: %v", err)
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	switch args := flag.Args(); {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "config" && args[1] == "print":
+		os.Exit(printConfig(cfg))
+	default:
+		flag.Usage()
+		os.Exit(2)
 	}
 
-	// Set up routes
-	router := api.SetupRoutes()
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	// Set up structured logging before anything logs
+	options, _ := cfg.Log.Options()
+	logger := logging.New(os.Stdout, options)
+	slog.SetDefault(logger)
+
+	// Initialize database connection
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		logger.Error("could not connect to the database", "error", err)
+		os.Exit(1)
+	}
+
+	// Set up routes and restore the state saved by the last shutdown
+	server := api.NewServer(cfg)
+	httpServer := &http.Server{
+		Addr:    cfg.Server.Addr(),
+		Handler: server.Router,
+	}
 
 	// Start the HTTP server
-	log.Println("Starting server on :8080")
-	if err := http.ListenAndServe(":8080", router); err != nil {
-		log.Fatalf("Could not start server: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("starting server", "addr", cfg.Server.Addr())
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		logger.Error("could not start server", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+	// A second signal kills the process right away
+	stop()
+
+	os.Exit(shutdown(server, httpServer, config.Seconds(cfg.Server.ShutdownSeconds), logger))
+}
+
+// maxSaveBudget is the most of the shutdown timeout kept for stopping the
+// background jobs and saving state
+const maxSaveBudget = 10 * time.Second
+
+// shutdown stops taking queue joins, waits for running requests, stops the
+// background jobs and saves the queue and match rooms, giving up after
+// timeout. It returns the exit code.
+func shutdown(server *api.Server, httpServer *http.Server, timeout time.Duration, logger *slog.Logger) int {
+	// Slow requests must not use up the time needed to save the queue and
+	// match rooms, so saving gets its own share of the timeout
+	saveBudget := min(maxSaveBudget, timeout/2)
+	logger.Info("shutting down", "timeout", timeout, "save_budget", saveBudget)
+
+	code := 0
+	server.Drain()
+	requestsCtx, cancelRequests := context.WithTimeout(context.Background(), timeout-saveBudget)
+	defer cancelRequests()
+	if err := httpServer.Shutdown(requestsCtx); err != nil {
+		logger.Error("requests did not finish in time", "error", err)
+		code = 1
+	}
+
+	saveCtx, cancelSave := context.WithTimeout(context.Background(), saveBudget)
+	defer cancelSave()
+	if err := server.Shutdown(saveCtx); err != nil {
+		logger.Error("could not save state", "error", err)
+		code = 1
+	}
+	if err := database.DB.Close(); err != nil {
+		logger.Warn("could not close the database", "error", err)
+	}
+
+	logger.Info("server stopped")
+	return code
+}
+
+// printConfig writes the effective configuration as YAML and reports
+// invalid settings, returning the exit code
+func printConfig(cfg *config.Config) int {
+	out, err := yaml.Marshal(cfg.Masked())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not print configuration: %v\n", err)
+		return 1
+	}
+	os.Stdout.Write(out)
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "\ninvalid configuration:\n%v\n", err)
+		return 1
 	}
+	return 0
 }
@@ -0,0 +1,229 @@
+// Package config loads the backend configuration from defaults, an optional
+// YAML or TOML file and environment variables, in that order of precedence,
+// and validates it before the server starts.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is every setting of the backend. Each field can be set in the
+// config file under its yaml/toml key or with the environment variable in
+// its env tag. Fields tagged secret are masked when printed.
+type Config struct {
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Database    DatabaseConfig    `yaml:"database" toml:"database"`
+	Auth        AuthConfig        `yaml:"auth" toml:"auth"`
+	Log         LogConfig         `yaml:"log" toml:"log"`
+	Rating      RatingConfig      `yaml:"rating" toml:"rating"`
+	Season      SeasonConfig      `yaml:"season" toml:"season"`
+	Tiers       TierConfig        `yaml:"tiers" toml:"tiers"`
+	Decay       DecayConfig       `yaml:"decay" toml:"decay"`
+	Match       MatchConfig       `yaml:"match" toml:"match"`
+	Leaderboard LeaderboardConfig `yaml:"leaderboard" toml:"leaderboard"`
+	Riot        RiotConfig        `yaml:"riot" toml:"riot"`
+	Cluster     ClusterConfig     `yaml:"cluster" toml:"cluster"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency"`
+}
+
+type ServerConfig struct {
+	Port        int      `yaml:"port" toml:"port" env:"PORT"`
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins" env:"CORS_ORIGINS"` // "*" allows any origin
+	// Time allowed to finish requests and save state after SIGTERM
+	ShutdownSeconds int `yaml:"shutdown_seconds" toml:"shutdown_seconds" env:"SHUTDOWN_TIMEOUT_SECONDS"`
+}
+
+type DatabaseConfig struct {
+	URL string `yaml:"url" toml:"url" env:"DATABASE_URL" secret:"true"`
+}
+
+type AuthConfig struct {
+	JWTSecret  string `yaml:"jwt_secret" toml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	AdminToken string `yaml:"admin_token" toml:"admin_token" env:"ADMIN_TOKEN" secret:"true"` // Empty disables the admin endpoints
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" toml:"level" env:"LOG_LEVEL"`    // debug, info, warn or error
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT"` // text or json
+	Levels string `yaml:"levels" toml:"levels" env:"LOG_LEVELS"` // Per component, e.g. "queue=debug,http=warn"
+}
+
+type RatingConfig struct {
+	Engine           string  `yaml:"engine" toml:"engine" env:"RATING_ENGINE"` // elo or glicko2
+	Mode             string  `yaml:"mode" toml:"mode" env:"RATING_MODE"`       // individual or team
+	KFactor          float64 `yaml:"k_factor" toml:"k_factor" env:"RATING_K_FACTOR"`
+	MarginFactor     float64 `yaml:"mov_factor" toml:"mov_factor" env:"RATING_MOV_FACTOR"` // 0 disables the margin of victory multiplier
+	PlacementMatches int     `yaml:"placement_matches" toml:"placement_matches" env:"PLACEMENT_MATCHES"`
+	SubstituteStakes float64 `yaml:"substitute_stakes" toml:"substitute_stakes" env:"SUBSTITUTE_STAKES"`
+}
+
+type SeasonConfig struct {
+	LengthDays int     `yaml:"length_days" toml:"length_days" env:"SEASON_LENGTH_DAYS"`
+	SoftReset  float64 `yaml:"soft_reset" toml:"soft_reset" env:"SEASON_SOFT_RESET"` // 0 keeps ratings, 1 resets them fully
+}
+
+type TierConfig struct {
+	Table               string `yaml:"table" toml:"table" env:"RANK_TIERS"` // JSON tier table, empty for the default tiers
+	RadiantSlots        int    `yaml:"radiant_slots" toml:"radiant_slots" env:"RADIANT_SLOTS"`
+	DemotionShieldGames int    `yaml:"demotion_shield_games" toml:"demotion_shield_games" env:"DEMOTION_SHIELD_GAMES"`
+}
+
+type DecayConfig struct {
+	MinRating       int     `yaml:"min_rating" toml:"min_rating" env:"DECAY_MIN_RATING"`
+	InactiveDays    int     `yaml:"inactive_days" toml:"inactive_days" env:"DECAY_INACTIVE_DAYS"`
+	WeeklyAmount    int     `yaml:"weekly_amount" toml:"weekly_amount" env:"DECAY_WEEKLY_AMOUNT"`
+	WeeklyDeviation float64 `yaml:"weekly_deviation" toml:"weekly_deviation" env:"DECAY_WEEKLY_DEVIATION"`
+	Floor           int     `yaml:"floor" toml:"floor" env:"DECAY_FLOOR"`
+	WarningDays     int     `yaml:"warning_days" toml:"warning_days" env:"DECAY_WARNING_DAYS"`
+}
+
+type MatchConfig struct {
+	AcceptSeconds          int `yaml:"accept_seconds" toml:"accept_seconds" env:"MATCH_ACCEPT_SECONDS"`
+	LobbyJoinSeconds       int `yaml:"lobby_join_seconds" toml:"lobby_join_seconds" env:"LOBBY_JOIN_SECONDS"`
+	SubstituteOfferSeconds int `yaml:"substitute_offer_seconds" toml:"substitute_offer_seconds" env:"SUBSTITUTE_OFFER_SECONDS"`
+}
+
+type LeaderboardConfig struct {
+	ReconcileSeconds int `yaml:"reconcile_seconds" toml:"reconcile_seconds" env:"LEADERBOARD_RECONCILE_SECONDS"` // 0 disables reconciling
+}
+
+type RiotConfig struct {
+	FixtureDir string `yaml:"fixture_dir" toml:"fixture_dir" env:"RIOT_FIXTURE_DIR"` // Riot API responses are served from local fixtures
+}
+
+type ClusterConfig struct {
+	Coordination string `yaml:"coordination" toml:"coordination" env:"COORDINATION_BACKEND"` // memory, or postgres to share the queue and match rooms between instances
+}
+
+type IdempotencyConfig struct {
+	Store         string `yaml:"store" toml:"store" env:"IDEMPOTENCY_STORE"` // memory, or postgres to recognise retries reaching another instance
+	WindowSeconds int    `yaml:"window_seconds" toml:"window_seconds" env:"IDEMPOTENCY_WINDOW_SECONDS"`
+}
+
+// Addr is the address the HTTP server listens on
+func (sc ServerConfig) Addr() string {
+	return fmt.Sprintf(":%d", sc.Port)
+}
+
+// Seconds converts a setting in seconds to a duration
+func Seconds(seconds int) time.Duration {
+	return time.Duration(seconds) * time.Second
+}
+
+// Days converts a setting in days to a duration
+func Days(days int) time.Duration {
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Load reads the config file at path, if any, then the environment,
+// including a .env file in the working directory. The result still has to
+// be checked with Validate.
+func Load(path string) (*Config, error) {
+	// A missing .env file is fine, the environment may be set directly
+	_ = godotenv.Load()
+
+	cfg := Default()
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		// An empty file decodes to io.EOF and changes nothing
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// applyEnv sets every field whose env tag names a non-empty variable
+func applyEnv(value reflect.Value) error {
+	var errs []error
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		fieldType := value.Type().Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		name := fieldType.Tag.Get("env")
+		raw := os.Getenv(name)
+		if name == "" || raw == "" {
+			continue
+		}
+		if err := setField(field, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func setField(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		field.SetInt(int64(parsed))
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		field.SetFloat(parsed)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"valorant-mobile-web/backend/internal/logging"
+	"valorant-mobile-web/backend/internal/services"
+)
+
+// Default returns the configuration used for settings that are not set
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            8080,
+			CORSOrigins:     []string{"*"},
+			ShutdownSeconds: 30,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Rating: RatingConfig{
+			Engine:           services.RatingEngineELO,
+			Mode:             string(services.RatingModeIndividual),
+			KFactor:          services.DefaultKFactor,
+			PlacementMatches: services.DefaultPlacementMatches,
+			SubstituteStakes: services.DefaultSubstituteStakes,
+		},
+		Season: SeasonConfig{
+			LengthDays: int(services.DefaultSeasonLength / (24 * time.Hour)),
+			SoftReset:  services.DefaultSeasonSoftReset,
+		},
+		Tiers: TierConfig{
+			RadiantSlots:        services.DefaultRadiantSlots,
+			DemotionShieldGames: services.DefaultDemotionShieldGames,
+		},
+		Decay: DecayConfig{
+			MinRating:       services.DefaultDecayMinRating,
+			InactiveDays:    services.DefaultDecayInactiveDays,
+			WeeklyAmount:    services.DefaultDecayWeeklyAmount,
+			WeeklyDeviation: services.DefaultDecayWeeklyDeviation,
+			WarningDays:     services.DefaultDecayWarningDays,
+		},
+		Match: MatchConfig{
+			AcceptSeconds:          int(services.DefaultAcceptTimeout / time.Second),
+			LobbyJoinSeconds:       int(services.DefaultLobbyJoinTimeout / time.Second),
+			SubstituteOfferSeconds: int(services.DefaultSubstituteOfferTimeout / time.Second),
+		},
+		Leaderboard: LeaderboardConfig{
+			ReconcileSeconds: int(services.DefaultLeaderboardReconcileInterval / time.Second),
+		},
+		Riot: RiotConfig{
+			FixtureDir: services.DefaultRiotFixtureDir,
+		},
+		Cluster: ClusterConfig{
+			Coordination: services.CoordinationMemory,
+		},
+		Idempotency: IdempotencyConfig{
+			Store:         services.IdempotencyStoreMemory,
+			WindowSeconds: int(services.DefaultIdempotencyWindow / time.Second),
+		},
+	}
+}
+
+// Validate reports every invalid setting at once, naming both its config
+// file key and its environment variable
+func (cfg *Config) Validate() error {
+	v := validator{names: settingNames(reflect.TypeOf(*cfg), "")}
+
+	v.check(cfg.Server.Port > 0 && cfg.Server.Port <= 65535, "server.port", "must be between 1 and 65535, got %d", cfg.Server.Port)
+	v.check(len(cfg.Server.CORSOrigins) > 0, "server.cors_origins", "needs at least one origin, or \"*\"")
+	v.check(cfg.Server.ShutdownSeconds > 0, "server.shutdown_seconds", "must be greater than 0, got %d", cfg.Server.ShutdownSeconds)
+	v.check(cfg.Database.URL != "", "database.url", "is required")
+	v.check(cfg.Auth.JWTSecret != "", "auth.jwt_secret", "is required")
+	v.check(len(cfg.Auth.JWTSecret) == 0 || len(cfg.Auth.JWTSecret) >= 32, "auth.jwt_secret", "must be at least 32 characters long")
+
+	if _, err := cfg.Log.Options(); err != nil {
+		v.fail("log", err.Error())
+	}
+
+	if _, err := services.NewRatingEngine(cfg.Rating.Engine, cfg.Rating.PlacementMatches, cfg.Rating.KFactor); err != nil {
+		v.fail("rating.engine", err.Error())
+	}
+	if _, err := services.ParseRatingMode(cfg.Rating.Mode); err != nil {
+		v.fail("rating.mode", err.Error())
+	}
+	v.check(cfg.Rating.KFactor > 0, "rating.k_factor", "must be greater than 0, got %g", cfg.Rating.KFactor)
+	v.check(cfg.Rating.MarginFactor >= 0, "rating.mov_factor", "must not be negative, got %g", cfg.Rating.MarginFactor)
+	v.check(cfg.Rating.PlacementMatches >= 0, "rating.placement_matches", "must not be negative, got %d", cfg.Rating.PlacementMatches)
+	v.check(cfg.Rating.SubstituteStakes >= 0 && cfg.Rating.SubstituteStakes <= 1, "rating.substitute_stakes", "must be between 0 and 1, got %g", cfg.Rating.SubstituteStakes)
+
+	v.check(cfg.Season.LengthDays > 0, "season.length_days", "must be greater than 0, got %d", cfg.Season.LengthDays)
+	v.check(cfg.Season.SoftReset >= 0 && cfg.Season.SoftReset <= 1, "season.soft_reset", "must be between 0 and 1, got %g", cfg.Season.SoftReset)
+
+	if cfg.Tiers.Table != "" {
+		if _, err := services.ParseTierTable([]byte(cfg.Tiers.Table)); err != nil {
+			v.fail("tiers.table", err.Error())
+		}
+	}
+	v.check(cfg.Tiers.RadiantSlots >= 0, "tiers.radiant_slots", "must not be negative, got %d", cfg.Tiers.RadiantSlots)
+	v.check(cfg.Tiers.DemotionShieldGames >= 0, "tiers.demotion_shield_games", "must not be negative, got %d", cfg.Tiers.DemotionShieldGames)
+
+	v.check(cfg.Decay.MinRating >= 0, "decay.min_rating", "must not be negative, got %d", cfg.Decay.MinRating)
+	v.check(cfg.Decay.InactiveDays > 0, "decay.inactive_days", "must be greater than 0, got %d", cfg.Decay.InactiveDays)
+	v.check(cfg.Decay.WeeklyAmount >= 0, "decay.weekly_amount", "must not be negative, got %d", cfg.Decay.WeeklyAmount)
+	v.check(cfg.Decay.WeeklyDeviation >= 0, "decay.weekly_deviation", "must not be negative, got %g", cfg.Decay.WeeklyDeviation)
+	v.check(cfg.Decay.Floor >= 0, "decay.floor", "must not be negative, got %d", cfg.Decay.Floor)
+	v.check(cfg.Decay.WarningDays >= 0, "decay.warning_days", "must not be negative, got %d", cfg.Decay.WarningDays)
+
+	v.check(cfg.Match.AcceptSeconds > 0, "match.accept_seconds", "must be greater than 0, got %d", cfg.Match.AcceptSeconds)
+	v.check(cfg.Match.LobbyJoinSeconds > 0, "match.lobby_join_seconds", "must be greater than 0, got %d", cfg.Match.LobbyJoinSeconds)
+	v.check(cfg.Match.SubstituteOfferSeconds > 0, "match.substitute_offer_seconds", "must be greater than 0, got %d", cfg.Match.SubstituteOfferSeconds)
+
+	v.check(cfg.Leaderboard.ReconcileSeconds >= 0, "leaderboard.reconcile_seconds", "must not be negative, got %d", cfg.Leaderboard.ReconcileSeconds)
+
+	switch cfg.Cluster.Coordination {
+	case services.CoordinationMemory, services.CoordinationPostgres:
+	default:
+		v.fail("cluster.coordination", fmt.Sprintf("must be %s or %s, got %q", services.CoordinationMemory, services.CoordinationPostgres, cfg.Cluster.Coordination))
+	}
+
+	if _, err := services.NewIdempotencyStore(cfg.Idempotency.Store, nil); err != nil {
+		v.fail("idempotency.store", err.Error())
+	}
+	v.check(cfg.Idempotency.WindowSeconds > 0, "idempotency.window_seconds", "must be greater than 0, got %d", cfg.Idempotency.WindowSeconds)
+
+	return errors.Join(v.errs...)
+}
+
+// Options returns the logger options of the log settings
+func (lc LogConfig) Options() (logging.Options, error) {
+	return logging.ParseOptions(lc.Level, lc.Format, lc.Levels)
+}
+
+type validator struct {
+	names map[string]string // Config file key to environment variable
+	errs  []error
+}
+
+func (v *validator) check(ok bool, key, format string, args ...interface{}) {
+	if !ok {
+		v.fail(key, fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) fail(key, message string) {
+	if env, ok := v.names[key]; ok {
+		key = fmt.Sprintf("%s (%s)", key, env)
+	}
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", key, message))
+}
+
+// settingNames maps the config file key of every setting to its
+// environment variable, e.g. "rating.k_factor" to "RATING_K_FACTOR"
+func settingNames(t reflect.Type, prefix string) map[string]string {
+	names := make(map[string]string)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := prefix + field.Tag.Get("yaml")
+		if field.Type.Kind() == reflect.Struct {
+			for nested, env := range settingNames(field.Type, key+".") {
+				names[nested] = env
+			}
+			continue
+		}
+		if env := field.Tag.Get("env"); env != "" {
+			names[key] = env
+		}
+	}
+	return names
+}
+
+// Masked returns a copy of cfg with every secret replaced, for printing
+func (cfg *Config) Masked() *Config {
+	masked := *cfg
+	masked.Server.CORSOrigins = append([]string(nil), cfg.Server.CORSOrigins...)
+	maskSecrets(reflect.ValueOf(&masked).Elem())
+	return &masked
+}
+
+func maskSecrets(value reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if field.Kind() == reflect.Struct {
+			maskSecrets(field)
+			continue
+		}
+		if value.Type().Field(i).Tag.Get("secret") == "true" && field.String() != "" {
+			field.SetString(strings.Repeat("*", 8))
+		}
+	}
+}
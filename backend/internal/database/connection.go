@@ -3,28 +3,21 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"os"
+	"valorant-mobile-web/backend/internal/logging"
 
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 var DB *sql.DB
 
-func Connect() error {
-	// Cargar variables de entorno
-	err := godotenv.Load()
-	if err != nil {
-		fmt.Println("Warning: .env file not found, using environment variables")
-	}
-
-	// Obtener URL de la base de datos desde variables de entorno
-	databaseURL := os.Getenv("DATABASE_URL")
+// Connect opens the database at databaseURL and creates missing tables
+func Connect(databaseURL string) error {
 	if databaseURL == "" {
-		return fmt.Errorf("DATABASE_URL environment variable is not set")
+		return fmt.Errorf("database URL is not set")
 	}
 
 	// Conectar a la base de datos
+	var err error
 	DB, err = sql.Open("postgres", databaseURL)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
@@ -35,7 +28,7 @@ func Connect() error {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	fmt.Println("Database connected successfully")
+	logging.Component("database").Info("database connected")
 	return createTables()
 }
 
@@ -53,6 +46,31 @@ func createTables() error {
             updated_at TIMESTAMP DEFAULT NOW()
         )`,
 
+		// Glicko-2 state, ignored by the ELO rating engine
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS rating_deviation DOUBLE PRECISION DEFAULT 350`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS volatility DOUBLE PRECISION DEFAULT 0.06`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS games_played INTEGER DEFAULT 0`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS last_match_at TIMESTAMP`,
+
+		// Tier kept between matches so a demotion shield can hold it above the rating
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS rank_tier INTEGER`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS demotion_shield INTEGER DEFAULT 0`,
+
+		// Inactivity decay: weekly steps applied since the last match and when the player was warned
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS decay_steps INTEGER DEFAULT 0`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS decay_warned_at TIMESTAMP`,
+
+		// In-game identity, used to match Riot match documents to accounts
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS riot_game_name VARCHAR(16)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS riot_tag_line VARCHAR(5)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS riot_region VARCHAR(8)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS riot_puuid VARCHAR(100)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS riot_verification_code VARCHAR(16)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS riot_verified_at TIMESTAMP`,
+		// A Riot ID can only be verified by one account
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_riot_id ON users (LOWER(riot_game_name), LOWER(riot_tag_line))
+            WHERE riot_verified_at IS NOT NULL`,
+
 		`CREATE TABLE IF NOT EXISTS queue (
             user_id UUID PRIMARY KEY REFERENCES users(id),
             joined_at TIMESTAMP DEFAULT NOW()
@@ -80,6 +98,200 @@ func createTables() error {
             voted_at TIMESTAMP DEFAULT NOW(),
             UNIQUE(match_id, user_id)
         )`,
+
+		// Formula and inputs of every rated match
+		`CREATE TABLE IF NOT EXISTS match_rating_audits (
+            match_id VARCHAR(64) PRIMARY KEY,
+            engine VARCHAR(20) NOT NULL,
+            mode VARCHAR(20) NOT NULL,
+            formula TEXT NOT NULL,
+            team1_score DOUBLE PRECISION NOT NULL,
+            team1_rounds INTEGER DEFAULT 0,
+            team2_rounds INTEGER DEFAULT 0,
+            margin_multiplier DOUBLE PRECISION DEFAULT 1,
+            players JSONB NOT NULL,
+            created_at TIMESTAMP DEFAULT NOW()
+        )`,
+
+		`CREATE TABLE IF NOT EXISTS seasons (
+            id SERIAL PRIMARY KEY,
+            name VARCHAR(50) NOT NULL,
+            starts_at TIMESTAMP NOT NULL,
+            ends_at TIMESTAMP NOT NULL,
+            soft_reset_factor DOUBLE PRECISION DEFAULT 0.5,
+            reset_target INTEGER DEFAULT 1000,
+            status VARCHAR(10) DEFAULT 'active',
+            created_at TIMESTAMP DEFAULT NOW()
+        )`,
+
+		`CREATE TABLE IF NOT EXISTS season_ratings (
+            season_id INTEGER REFERENCES seasons(id),
+            user_id UUID REFERENCES users(id),
+            rating INTEGER NOT NULL,
+            wins INTEGER DEFAULT 0,
+            losses INTEGER DEFAULT 0,
+            games_played INTEGER DEFAULT 0,
+            updated_at TIMESTAMP DEFAULT NOW(),
+            PRIMARY KEY (season_id, user_id)
+        )`,
+
+		// Final ranks, written once when a season ends and never modified
+		`CREATE TABLE IF NOT EXISTS season_standings (
+            season_id INTEGER REFERENCES seasons(id),
+            user_id UUID REFERENCES users(id),
+            final_rank INTEGER NOT NULL,
+            final_rating INTEGER NOT NULL,
+            wins INTEGER DEFAULT 0,
+            losses INTEGER DEFAULT 0,
+            games_played INTEGER DEFAULT 0,
+            PRIMARY KEY (season_id, user_id)
+        )`,
+
+		`CREATE TABLE IF NOT EXISTS tier_events (
+            id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+            user_id UUID REFERENCES users(id),
+            match_id VARCHAR(64),
+            event_type VARCHAR(20) NOT NULL,
+            from_tier VARCHAR(30),
+            to_tier VARCHAR(30),
+            shield_games_left INTEGER DEFAULT 0,
+            created_at TIMESTAMP DEFAULT NOW()
+        )`,
+
+		`CREATE TABLE IF NOT EXISTS rating_history (
+            id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+            user_id UUID REFERENCES users(id),
+            reason VARCHAR(20) NOT NULL,
+            rating_before INTEGER NOT NULL,
+            rating_after INTEGER NOT NULL,
+            delta INTEGER NOT NULL,
+            deviation_before DOUBLE PRECISION,
+            deviation_after DOUBLE PRECISION,
+            match_id VARCHAR(64),
+            created_at TIMESTAMP DEFAULT NOW()
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_rating_history_user ON rating_history (user_id, created_at)`,
+		`ALTER TABLE rating_history ADD COLUMN IF NOT EXISTS note TEXT`,
+
+		// Completed matches, kept after their match room is removed
+		`CREATE TABLE IF NOT EXISTS match_archive (
+            match_id VARCHAR(64) PRIMARY KEY,
+            season_id INTEGER REFERENCES seasons(id),
+            selected_map VARCHAR(50),
+            banned_maps TEXT[],
+            captain1 VARCHAR(64),
+            captain2 VARCHAR(64),
+            captain_selection_method VARCHAR(20),
+            winner VARCHAR(10) NOT NULL,
+            team1_rounds INTEGER DEFAULT 0,
+            team2_rounds INTEGER DEFAULT 0,
+            reported_by VARCHAR(64),
+            team1_win_probability DOUBLE PRECISION DEFAULT 0,
+            started_at TIMESTAMP,
+            completed_at TIMESTAMP NOT NULL
+        )`,
+		`CREATE TABLE IF NOT EXISTS match_archive_players (
+            match_id VARCHAR(64) REFERENCES match_archive(match_id),
+            user_id UUID REFERENCES users(id),
+            username VARCHAR(50),
+            team VARCHAR(10) NOT NULL,
+            role VARCHAR(20),
+            outcome VARCHAR(10) NOT NULL,
+            rating_before INTEGER DEFAULT 0,
+            rating_after INTEGER DEFAULT 0,
+            rating_delta INTEGER DEFAULT 0,
+            PRIMARY KEY (match_id, user_id)
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_match_archive_players_user ON match_archive_players (user_id)`,
+
+		// Scoreboard line of each player of an archived match
+		`CREATE TABLE IF NOT EXISTS match_player_stats (
+            match_id VARCHAR(64) REFERENCES match_archive(match_id),
+            user_id UUID REFERENCES users(id),
+            agent VARCHAR(20),
+            kills INTEGER DEFAULT 0,
+            deaths INTEGER DEFAULT 0,
+            assists INTEGER DEFAULT 0,
+            acs DOUBLE PRECISION DEFAULT 0,
+            headshot_percent DOUBLE PRECISION DEFAULT 0,
+            first_bloods INTEGER DEFAULT 0,
+            submitted_by VARCHAR(64),
+            submitted_at TIMESTAMP DEFAULT NOW(),
+            PRIMARY KEY (match_id, user_id)
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_match_player_stats_user ON match_player_stats (user_id)`,
+
+		`CREATE TABLE IF NOT EXISTS notifications (
+            id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+            user_id UUID REFERENCES users(id),
+            type VARCHAR(30) NOT NULL,
+            message TEXT NOT NULL,
+            created_at TIMESTAMP DEFAULT NOW(),
+            read_at TIMESTAMP
+        )`,
+
+		// Players who left a match and were replaced by a substitute
+		`CREATE TABLE IF NOT EXISTS match_abandons (
+            id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+            match_id VARCHAR(64) NOT NULL,
+            user_id UUID REFERENCES users(id),
+            substitute_id VARCHAR(64),
+            requested_by VARCHAR(64),
+            created_at TIMESTAMP DEFAULT NOW()
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_match_abandons_user ON match_abandons (user_id, created_at)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS abandons INTEGER DEFAULT 0`,
+
+		// One-way friends lists, used by the friends leaderboard
+		`CREATE TABLE IF NOT EXISTS friends (
+            user_id UUID REFERENCES users(id),
+            friend_id UUID REFERENCES users(id),
+            created_at TIMESTAMP DEFAULT NOW(),
+            PRIMARY KEY (user_id, friend_id)
+        )`,
+
+		// Responses replayed for retried requests, see IDEMPOTENCY_STORE
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+            key VARCHAR(400) PRIMARY KEY,
+            fingerprint VARCHAR(64) NOT NULL,
+            status INTEGER,
+            content_type VARCHAR(100),
+            body BYTEA,
+            reserved_at TIMESTAMP DEFAULT NOW(),
+            expires_at TIMESTAMP NOT NULL
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys (expires_at)`,
+
+		// Queue and match rooms shared by every instance when COORDINATION_BACKEND=postgres
+		`CREATE TABLE IF NOT EXISTS shared_state (
+            name VARCHAR(50) PRIMARY KEY,
+            revision BIGINT NOT NULL,
+            data JSONB NOT NULL,
+            updated_at TIMESTAMP DEFAULT NOW()
+        )`,
+
+		// Queue and match rooms saved on shutdown, restored and deleted by the next start
+		`CREATE TABLE IF NOT EXISTS runtime_state (
+            key VARCHAR(50) PRIMARY KEY,
+            data JSONB NOT NULL,
+            saved_at TIMESTAMP DEFAULT NOW()
+        )`,
+
+		// Completed matches whose archive failed, archived again by a background job
+		`CREATE TABLE IF NOT EXISTS pending_match_archives (
+            match_id VARCHAR(64) PRIMARY KEY,
+            data JSONB NOT NULL,
+            attempts INTEGER DEFAULT 1,
+            last_error TEXT,
+            created_at TIMESTAMP DEFAULT NOW(),
+            updated_at TIMESTAMP DEFAULT NOW()
+        )`,
+
+		// One-time migrations already run, see runMigrations
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+            name VARCHAR(100) PRIMARY KEY,
+            applied_at TIMESTAMP DEFAULT NOW()
+        )`,
 	}
 
 	for _, query := range queries {
@@ -88,7 +300,52 @@ func createTables() error {
 		}
 	}
 
-	fmt.Println("Database tables created successfully")
+	logging.Component("database").Info("database tables created")
+	return runMigrations()
+}
+
+// migrations change existing data once, in order, after the tables are
+// created. Never rename or remove one: its name records that it ran.
+var migrations = []struct {
+	name  string
+	query string
+}{
+	// Accounts older than the games_played column played wins + losses rated
+	// games; without this they would count as provisional and drop off the
+	// leaderboard
+	{"backfill_games_played", `UPDATE users SET games_played = wins + losses
+        WHERE COALESCE(games_played, 0) = 0 AND wins + losses > 0`},
+}
+
+// runMigrations runs every migration not recorded in schema_migrations, each
+// in a transaction with its record. Instances starting together wait for
+// each other on the record, so each migration runs once.
+func runMigrations() error {
+	for _, migration := range migrations {
+		tx, err := DB.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to start migration %s: %w", migration.name, err)
+		}
+
+		result, err := tx.Exec(`INSERT INTO schema_migrations (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, migration.name)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %w", migration.name, err)
+		}
+		if recorded, _ := result.RowsAffected(); recorded == 0 {
+			tx.Rollback()
+			continue
+		}
+
+		if _, err := tx.Exec(migration.query); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to run migration %s: %w", migration.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %w", migration.name, err)
+		}
+		logging.Component("database").Info("migration applied", "migration", migration.name)
+	}
 	return nil
 }
 
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
@@ -15,11 +16,13 @@ var userMutex sync.RWMutex
 
 type AuthHandler struct {
 	AuthService *services.AuthService
+	logger      *slog.Logger
 }
 
-func NewAuthHandler() *AuthHandler {
-	authService := services.NewAuthService("your-secret-key-here")
-	return &AuthHandler{AuthService: authService}
+// NewAuthHandler creates an AuthHandler that signs tokens with jwtSecret
+func NewAuthHandler(jwtSecret string, logger *slog.Logger) *AuthHandler {
+	authService := services.NewAuthService(jwtSecret)
+	return &AuthHandler{AuthService: authService, logger: logger}
 }
 
 type RegisterRequest struct {
@@ -34,25 +37,14 @@ type LoginRequest struct {
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
-	// Log the request
-	println("=== REGISTER REQUEST RECEIVED ===")
-	println("Method:", r.Method)
-	println("URL:", r.URL.String())
-	println("Content-Type:", r.Header.Get("Content-Type"))
-
 	var req RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		println("ERROR: Failed to decode request body:", err.Error())
+		h.logger.DebugContext(r.Context(), "invalid register request body", "error", err)
 		utils.ErrorResponse(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	println("Username:", req.Username)
-	println("Email:", req.Email)
-	println("Password length:", len(req.Password))
-
 	if req.Username == "" || req.Email == "" || req.Password == "" {
-		println("ERROR: Missing required fields")
 		utils.ErrorResponse(w, "Username, email and password are required", http.StatusBadRequest)
 		return
 	}
@@ -60,7 +52,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	// Hash password (for development, we'll just verify it works)
 	hashedPassword, err := h.AuthService.HashPassword(req.Password)
 	if err != nil {
-		println("ERROR: Failed to hash password:", err.Error())
+		h.logger.ErrorContext(r.Context(), "could not hash password", "error", err)
 		utils.ErrorResponse(w, "Failed to process password", http.StatusInternalServerError)
 		return
 	}
@@ -70,7 +62,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	userStore[req.Email] = hashedPassword
 	userMutex.Unlock()
 
-	println("SUCCESS: User registration successful and stored") // TODO: Save user to database
+	h.logger.InfoContext(r.Context(), "user registered", "username", req.Username, "email", req.Email) // TODO: Save user to database
 	// For now, just return success with mock user data
 	response := map[string]interface{}{
 		"success": true,
@@ -82,28 +74,18 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	println("Sending response:", response)
 	utils.SuccessResponse(w, response)
 }
 
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-	// Log the request
-	println("=== LOGIN REQUEST RECEIVED ===")
-	println("Method:", r.Method)
-	println("URL:", r.URL.String())
-
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		println("ERROR: Failed to decode login request body:", err.Error())
+		h.logger.DebugContext(r.Context(), "invalid login request body", "error", err)
 		utils.ErrorResponse(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	println("Email:", req.Email)
-	println("Password length:", len(req.Password))
-
 	if req.Email == "" || req.Password == "" {
-		println("ERROR: Missing email or password")
 		utils.ErrorResponse(w, "Email and password are required", http.StatusBadRequest)
 		return
 	}
@@ -114,14 +96,14 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	userMutex.RUnlock()
 
 	if !exists {
-		println("ERROR: User not found:", req.Email)
+		h.logger.InfoContext(r.Context(), "login failed, unknown user", "email", req.Email)
 		utils.ErrorResponse(w, "Invalid email or password", http.StatusUnauthorized)
 		return
 	}
 
 	// Verify password
 	if err := h.AuthService.VerifyPassword(storedHash, req.Password); err != nil {
-		println("ERROR: Invalid password for:", req.Email)
+		h.logger.InfoContext(r.Context(), "login failed, invalid password", "email", req.Email)
 		utils.ErrorResponse(w, "Invalid email or password", http.StatusUnauthorized)
 		return
 	}
@@ -129,12 +111,12 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Generate token for authenticated user
 	token, err := h.AuthService.GenerateToken("dummy-user-id")
 	if err != nil {
-		println("ERROR: Failed to generate token:", err.Error())
+		h.logger.ErrorContext(r.Context(), "could not generate token", "error", err)
 		utils.ErrorResponse(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
-	println("SUCCESS: Login successful for:", req.Email)
+	h.logger.InfoContext(r.Context(), "login succeeded", "email", req.Email)
 
 	response := map[string]interface{}{
 		"success": true,
@@ -147,6 +129,5 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	println("Sending login response")
 	utils.SuccessResponse(w, response)
 }
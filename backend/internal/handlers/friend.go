@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"valorant-mobile-web/backend/internal/services"
+	"valorant-mobile-web/backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type FriendHandler struct {
+	friendService *services.FriendService
+}
+
+// NewFriendHandlerWithService creates a FriendHandler with a shared service instance
+func NewFriendHandlerWithService(friendService *services.FriendService) *FriendHandler {
+	return &FriendHandler{
+		friendService: friendService,
+	}
+}
+
+type AddFriendRequest struct {
+	FriendID string `json:"friend_id"`
+}
+
+// GetFriends returns the caller's friends list
+func (fh *FriendHandler) GetFriends(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "Unauthorized - User ID required", http.StatusUnauthorized)
+		return
+	}
+
+	friends, err := fh.friendService.ListFriends(userID)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"user_id": userID,
+		"friends": friends,
+	}
+	utils.SuccessResponse(w, response)
+}
+
+// AddFriend adds a player to the caller's friends list
+func (fh *FriendHandler) AddFriend(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "Unauthorized - User ID required", http.StatusUnauthorized)
+		return
+	}
+
+	var req AddFriendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.FriendID == "" {
+		utils.ErrorResponse(w, "friend_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := fh.friendService.AddFriend(userID, req.FriendID); err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.MessageResponse(w, "Friend added")
+}
+
+// RemoveFriend removes a player from the caller's friends list
+func (fh *FriendHandler) RemoveFriend(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "Unauthorized - User ID required", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	if err := fh.friendService.RemoveFriend(userID, vars["id"]); err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.MessageResponse(w, "Friend removed")
+}
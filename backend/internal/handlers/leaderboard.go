@@ -1,168 +1,645 @@
 package handlers
 
 import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"valorant-mobile-web/backend/internal/database"
+	"valorant-mobile-web/backend/internal/logging"
 	"valorant-mobile-web/backend/internal/models"
+	"valorant-mobile-web/backend/internal/services"
 	"valorant-mobile-web/backend/pkg/utils"
+
+	"github.com/lib/pq"
 )
 
-type LeaderboardHandler struct{}
+type LeaderboardHandler struct {
+	ratingService *services.RatingService
+	seasonService *services.SeasonService
+	tierService   *services.TierService
+	statsService  *services.MatchStatsService
+	index         *services.LeaderboardIndex
+	logger        *slog.Logger
+}
+
+// leaderboardSorts maps the sort query parameter to the rating players are
+// ranked by, highest first. Ties are broken by user ID.
+var leaderboardSorts = map[string]string{
+	"elo":          "elo",
+	"conservative": "(elo - 2 * COALESCE(rating_deviation, 0))",
+}
+
+// maxAroundRange bounds how many players above and below a player the
+// around-me mode returns
+const maxAroundRange = 50
 
 func NewLeaderboardHandler() *LeaderboardHandler {
-	return &LeaderboardHandler{}
+	return &LeaderboardHandler{logger: logging.Component("leaderboard")}
 }
 
-func (lh *LeaderboardHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
-	// Obtener parámetros de consulta
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
+// NewLeaderboardHandlerWithServices creates a LeaderboardHandler that defaults
+// to the sort order that fits the configured rating engine, can serve
+// per-season leaderboards, shows rank tiers and adds career stats to a
+// single player's rank. Once index is built, the all-time leaderboard and
+// ranks are served from it instead of ranking the users table.
+func NewLeaderboardHandlerWithServices(ratingService *services.RatingService, seasonService *services.SeasonService, tierService *services.TierService, statsService *services.MatchStatsService, index *services.LeaderboardIndex, logger *slog.Logger) *LeaderboardHandler {
+	return &LeaderboardHandler{
+		ratingService: ratingService,
+		seasonService: seasonService,
+		tierService:   tierService,
+		statsService:  statsService,
+		index:         index,
+		logger:        logger,
+	}
+}
+
+// useIndex reports whether the leaderboard index can serve requests
+func (lh *LeaderboardHandler) useIndex() bool {
+	return lh.index != nil && lh.index.Ready()
+}
+
+// placementMatches is the number of games a player needs before being ranked
+func (lh *LeaderboardHandler) placementMatches() int {
+	if lh.ratingService != nil {
+		return lh.ratingService.PlacementMatches()
+	}
+	return services.DefaultPlacementMatches
+}
+
+// defaultSort ranks by conservative rating when ratings carry a deviation
+func (lh *LeaderboardHandler) defaultSort() string {
+	if lh.ratingService != nil && lh.ratingService.Engine().Name() == services.RatingEngineGlicko2 {
+		return "conservative"
+	}
+	return "elo"
+}
 
+// rankedUsersCTE ranks every player who finished their placement matches
+// ($1) by sortKey as the "ranked" CTE. sort_key is kept so pages can continue
+// from a cursor.
+func rankedUsersCTE(sortKey string) string {
+	return `
+        WITH ranked AS (
+            SELECT 
+                id, username, email, elo, wins, losses,
+                COALESCE(rating_deviation, 0) as rating_deviation, COALESCE(volatility, 0) as volatility,
+                COALESCE(games_played, 0) as games_played, last_match_at,
+                created_at, updated_at,
+                CASE 
+                    WHEN (wins + losses) > 0 THEN ROUND((wins::float / (wins + losses)::float) * 100, 2)
+                    ELSE 0 
+                END as win_rate,
+                (wins + losses) as games_total,
+                ROW_NUMBER() OVER (ORDER BY ` + sortKey + ` DESC, id) as rank,
+                elo - 2 * COALESCE(rating_deviation, 0) as conservative_rating,
+                COALESCE(rank_tier, -1) as rank_tier,
+                ` + sortKey + ` as sort_key
+            FROM users 
+            WHERE COALESCE(games_played, 0) >= $1
+        )`
+}
+
+// rankedUsersSelect reads the ranked CTE in the column order of scanUserStats
+const rankedUsersSelect = `
+        SELECT id, username, email, elo, wins, losses, rating_deviation, volatility, games_played, last_match_at,
+               created_at, updated_at, win_rate, games_total, rank, conservative_rating, rank_tier
+        FROM ranked`
+
+// leaderboardCursor is the position after the last player of a page
+type leaderboardCursor struct {
+	sort  string
+	value float64
+	id    string
+}
+
+func (c leaderboardCursor) encode() string {
+	raw := c.sort + "|" + strconv.FormatFloat(c.value, 'g', -1, 64) + "|" + c.id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeLeaderboardCursor(cursor string) (leaderboardCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return leaderboardCursor{}, fmt.Errorf("invalid cursor")
+	}
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return leaderboardCursor{}, fmt.Errorf("invalid cursor")
+	}
+	value, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return leaderboardCursor{}, fmt.Errorf("invalid cursor")
+	}
+	return leaderboardCursor{sort: parts[0], value: value, id: parts[2]}, nil
+}
+
+// leaderboardPage reads the limit (1-100, 50 by default) and offset query
+// parameters, ignoring invalid values
+func leaderboardPage(r *http.Request) (int, int) {
 	limit := 50 // default
 	offset := 0 // default
 
-	if limitStr != "" {
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
 		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
 			limit = l
 		}
 	}
 
-	if offsetStr != "" {
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
 		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
 			offset = o
 		}
 	}
+	return limit, offset
+}
+
+// leaderboardCursorParam reads the cursor query parameter, which must have
+// been issued for sortBy. Returns nil when there is none.
+func leaderboardCursorParam(r *http.Request, sortBy string) (*leaderboardCursor, error) {
+	cursorStr := r.URL.Query().Get("cursor")
+	if cursorStr == "" {
+		return nil, nil
+	}
+	cursor, err := decodeLeaderboardCursor(cursorStr)
+	if err != nil || cursor.sort != sortBy {
+		return nil, fmt.Errorf("invalid cursor for this sort")
+	}
+	return &cursor, nil
+}
+
+// aroundRangeParam reads how many players above and below to show in
+// around-me mode, 5 by default
+func aroundRangeParam(r *http.Request) (int, error) {
+	rangeStr := r.URL.Query().Get("range")
+	if rangeStr == "" {
+		return 5, nil
+	}
+	parsed, err := strconv.Atoi(rangeStr)
+	if err != nil || parsed < 0 || parsed > maxAroundRange {
+		return 0, fmt.Errorf("range must be between 0 and %d", maxAroundRange)
+	}
+	return parsed, nil
+}
+
+// checkETag sets the ETag of a response and reports whether the client's
+// If-None-Match already has it, in which case 304 Not Modified was written
+func checkETag(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// sortValue returns the rating a player is ranked by under sortBy
+func sortValue(sortBy string, user models.UserStats) float64 {
+	if sortBy == "conservative" {
+		return user.ConservativeRating
+	}
+	return float64(user.ELO)
+}
+
+// GetLeaderboard returns a page of ranked players. Pages continue from the
+// next_cursor of the previous page (keyset pagination on rating and user
+// ID, so players moving between requests never cause skips or repeats);
+// offset is still accepted for the first page. With around=<user ID> it
+// instead returns the range players above and below that player.
+func (lh *LeaderboardHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	// Obtener parámetros de consulta
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = lh.defaultSort()
+	}
+
+	sortKey, ok := leaderboardSorts[sortBy]
+	if !ok {
+		utils.ErrorResponse(w, "sort must be 'elo' or 'conservative'", http.StatusBadRequest)
+		return
+	}
+
+	limit, offset := leaderboardPage(r)
+
+	if seasonStr := r.URL.Query().Get("season"); seasonStr != "" {
+		lh.getSeasonLeaderboard(w, seasonStr, limit, offset)
+		return
+	}
+
+	if lh.useIndex() {
+		lh.getIndexedLeaderboard(w, r, sortBy, limit, offset)
+		return
+	}
+
+	// Provisional players are hidden until they finish their placement matches
+	placementMatches := lh.placementMatches()
+	var total int
+	err := database.DB.QueryRow(`SELECT COUNT(*) FROM users WHERE COALESCE(games_played, 0) >= $1`, placementMatches).Scan(&total)
+	if err != nil {
+		lh.logger.ErrorContext(r.Context(), "could not count leaderboard", "error", err)
+		utils.ErrorResponse(w, "Failed to load leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	if around := r.URL.Query().Get("around"); around != "" {
+		lh.getLeaderboardAround(w, r, around, sortBy, sortKey, total)
+		return
+	}
+
+	query := rankedUsersCTE(sortKey) + rankedUsersSelect
+	args := []interface{}{placementMatches, limit + 1}
+	cursor, err := leaderboardCursorParam(r, sortBy)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if cursor != nil {
+		query += ` WHERE sort_key < $3 OR (sort_key = $3 AND id > $4::uuid) ORDER BY rank LIMIT $2`
+		args = append(args, cursor.value, cursor.id)
+		offset = 0
+	} else {
+		query += ` ORDER BY rank LIMIT $2 OFFSET $3`
+		args = append(args, offset)
+	}
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		lh.logger.ErrorContext(r.Context(), "could not load leaderboard", "error", err)
+		utils.ErrorResponse(w, "Failed to load leaderboard", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	leaderboard := []models.UserStats{}
+	for rows.Next() {
+		user, err := lh.scanUserStats(rows)
+		if err != nil {
+			utils.ErrorResponse(w, "Failed to scan leaderboard data", http.StatusInternalServerError)
+			return
+		}
+		leaderboard = append(leaderboard, user)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ErrorResponse(w, "Failed to load leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	// One extra row was loaded to know whether there is a next page
+	nextCursor := ""
+	if len(leaderboard) > limit {
+		leaderboard = leaderboard[:limit]
+		last := leaderboard[limit-1]
+		nextCursor = leaderboardCursor{sort: sortBy, value: sortValue(sortBy, last), id: last.ID}.encode()
+	}
+
+	response := map[string]interface{}{
+		"leaderboard": leaderboard,
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+		"sort":        sortBy,
+		"next_cursor": nextCursor,
+	}
+
+	utils.SuccessResponse(w, response)
+}
+
+// getLeaderboardAround serves GetLeaderboard in around-me mode: the player
+// and up to range players ranked directly above and below them
+func (lh *LeaderboardHandler) getLeaderboardAround(w http.ResponseWriter, r *http.Request, userID, sortBy, sortKey string, total int) {
+	aroundRange, err := aroundRangeParam(r)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	placementMatches := lh.placementMatches()
+	var rank int
+	err = database.DB.QueryRow(rankedUsersCTE(sortKey)+` SELECT rank FROM ranked WHERE id::text = $2`,
+		placementMatches, userID).Scan(&rank)
+	if err == sql.ErrNoRows {
+		utils.ErrorResponse(w, "User is not ranked", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		lh.logger.ErrorContext(r.Context(), "could not locate player on the leaderboard", "rank_user_id", userID, "error", err)
+		utils.ErrorResponse(w, "Failed to load leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := database.DB.Query(rankedUsersCTE(sortKey)+rankedUsersSelect+` WHERE rank BETWEEN $2 AND $3 ORDER BY rank`,
+		placementMatches, rank-aroundRange, rank+aroundRange)
+	if err != nil {
+		lh.logger.ErrorContext(r.Context(), "could not load leaderboard around player", "rank_user_id", userID, "error", err)
+		utils.ErrorResponse(w, "Failed to load leaderboard", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	leaderboard := []models.UserStats{}
+	for rows.Next() {
+		user, err := lh.scanUserStats(rows)
+		if err != nil {
+			utils.ErrorResponse(w, "Failed to scan leaderboard data", http.StatusInternalServerError)
+			return
+		}
+		leaderboard = append(leaderboard, user)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ErrorResponse(w, "Failed to load leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"leaderboard": leaderboard,
+		"total":       total,
+		"sort":        sortBy,
+		"around":      userID,
+		"rank":        rank,
+		"range":       aroundRange,
+	}
+
+	utils.SuccessResponse(w, response)
+}
 
-	query := `
+// getIndexedLeaderboard serves GetLeaderboard from the leaderboard index.
+// Only the players on the page are read from the database.
+func (lh *LeaderboardHandler) getIndexedLeaderboard(w http.ResponseWriter, r *http.Request, sortBy string, limit, offset int) {
+	if checkETag(w, r, lh.index.ETag()) {
+		return
+	}
+
+	response := map[string]interface{}{
+		"total": lh.index.Total(),
+		"sort":  sortBy,
+	}
+
+	var positions []services.LeaderboardPosition
+	if around := r.URL.Query().Get("around"); around != "" {
+		aroundRange, err := aroundRangeParam(r)
+		if err != nil {
+			utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rank, nearby, ranked := lh.index.Around(sortBy, around, aroundRange)
+		if !ranked {
+			utils.ErrorResponse(w, "User is not ranked", http.StatusNotFound)
+			return
+		}
+		positions = nearby
+		response["around"] = around
+		response["rank"] = rank
+		response["range"] = aroundRange
+	} else {
+		cursor, err := leaderboardCursorParam(r, sortBy)
+		if err != nil {
+			utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// One extra player is read to know whether there is a next page
+		if cursor != nil {
+			positions = lh.index.After(sortBy, cursor.value, cursor.id, limit+1)
+			offset = 0
+		} else {
+			positions = lh.index.Page(sortBy, offset, limit+1)
+		}
+
+		nextCursor := ""
+		if len(positions) > limit {
+			positions = positions[:limit]
+			last := positions[limit-1]
+			nextCursor = leaderboardCursor{sort: sortBy, value: last.Value, id: last.UserID}.encode()
+		}
+		response["limit"] = limit
+		response["offset"] = offset
+		response["next_cursor"] = nextCursor
+	}
+
+	leaderboard, err := lh.loadPositions(positions)
+	if err != nil {
+		lh.logger.ErrorContext(r.Context(), "could not load leaderboard", "error", err)
+		utils.ErrorResponse(w, "Failed to load leaderboard", http.StatusInternalServerError)
+		return
+	}
+	response["leaderboard"] = leaderboard
+
+	utils.SuccessResponse(w, response)
+}
+
+// indexedUsersQuery reads players by ID in the column order of scanUserStats.
+// Ranks come from the leaderboard index.
+const indexedUsersQuery = `
         SELECT 
-            id, username, email, elo, wins, losses, created_at, updated_at,
+            id, username, email, elo, wins, losses,
+            COALESCE(rating_deviation, 0), COALESCE(volatility, 0), COALESCE(games_played, 0), last_match_at,
+            created_at, updated_at,
             CASE 
                 WHEN (wins + losses) > 0 THEN ROUND((wins::float / (wins + losses)::float) * 100, 2)
                 ELSE 0 
             END as win_rate,
             (wins + losses) as games_total,
-            ROW_NUMBER() OVER (ORDER BY elo DESC) as rank
-        FROM users 
-        ORDER BY elo DESC 
+            0 as rank,
+            elo - 2 * COALESCE(rating_deviation, 0) as conservative_rating,
+            COALESCE(rank_tier, -1)
+        FROM users
+        WHERE id::text = ANY($1)
+    `
+
+// loadPositions reads the players at positions, in order and with their
+// ranks. Players deleted since the index was built are skipped.
+func (lh *LeaderboardHandler) loadPositions(positions []services.LeaderboardPosition) ([]models.UserStats, error) {
+	leaderboard := []models.UserStats{}
+	if len(positions) == 0 {
+		return leaderboard, nil
+	}
+
+	userIDs := make([]string, len(positions))
+	for i, position := range positions {
+		userIDs[i] = position.UserID
+	}
+
+	rows, err := database.DB.Query(indexedUsersQuery, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make(map[string]models.UserStats, len(positions))
+	for rows.Next() {
+		user, err := lh.scanUserStats(rows)
+		if err != nil {
+			return nil, err
+		}
+		users[user.ID] = user
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, position := range positions {
+		if user, ok := users[position.UserID]; ok {
+			user.Rank = position.Rank
+			leaderboard = append(leaderboard, user)
+		}
+	}
+	return leaderboard, nil
+}
+
+// Season leaderboards read the live season_ratings for the active season and
+// the frozen season_standings snapshot for ended seasons
+const (
+	activeSeasonLeaderboardQuery = `
+        SELECT 
+            u.id, u.username, u.email, sr.rating, sr.wins, sr.losses,
+            COALESCE(u.rating_deviation, 0), COALESCE(u.volatility, 0), sr.games_played, u.last_match_at,
+            u.created_at, u.updated_at,
+            CASE 
+                WHEN (sr.wins + sr.losses) > 0 THEN ROUND((sr.wins::float / (sr.wins + sr.losses)::float) * 100, 2)
+                ELSE 0 
+            END as win_rate,
+            (sr.wins + sr.losses) as games_total,
+            ROW_NUMBER() OVER (ORDER BY sr.rating DESC, sr.user_id) as rank,
+            sr.rating - 2 * COALESCE(u.rating_deviation, 0) as conservative_rating,
+            -1
+        FROM season_ratings sr
+        JOIN users u ON u.id = sr.user_id
+        WHERE sr.season_id = $3 AND COALESCE(u.games_played, 0) >= $4
+        ORDER BY sr.rating DESC, sr.user_id
         LIMIT $1 OFFSET $2
     `
+	endedSeasonLeaderboardQuery = `
+        SELECT 
+            u.id, u.username, u.email, st.final_rating, st.wins, st.losses,
+            COALESCE(u.rating_deviation, 0), COALESCE(u.volatility, 0), st.games_played, u.last_match_at,
+            u.created_at, u.updated_at,
+            CASE 
+                WHEN (st.wins + st.losses) > 0 THEN ROUND((st.wins::float / (st.wins + st.losses)::float) * 100, 2)
+                ELSE 0 
+            END as win_rate,
+            (st.wins + st.losses) as games_total,
+            st.final_rank as rank,
+            st.final_rating - 2 * COALESCE(u.rating_deviation, 0) as conservative_rating,
+            -1
+        FROM season_standings st
+        JOIN users u ON u.id = st.user_id
+        WHERE st.season_id = $3
+        ORDER BY st.final_rank
+        LIMIT $1 OFFSET $2
+    `
+)
+
+// scanUserStats scans one row of the leaderboard queries and fills the
+// derived placement and tier fields. Columns after the usual ones are
+// scanned into extra.
+func (lh *LeaderboardHandler) scanUserStats(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.UserStats, error) {
+	var user models.UserStats
+	var storedTier int
+	dest := []interface{}{
+		&user.ID, &user.Username, &user.Email, &user.ELO,
+		&user.Wins, &user.Losses,
+		&user.RatingDeviation, &user.Volatility, &user.GamesPlayed, &user.LastMatchAt,
+		&user.CreatedAt, &user.UpdatedAt,
+		&user.WinRate, &user.GamesTotal, &user.Rank, &user.ConservativeRating,
+		&storedTier,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return user, err
+	}
+
+	user.Provisional = user.GamesPlayed < lh.placementMatches()
+	if lh.tierService != nil {
+		user.Tier = lh.tierService.TierFor(user.ID, user.ELO, storedTier, user.Provisional)
+	}
+	return user, nil
+}
 
-	rows, err := database.DB.Query(query, limit, offset)
-	if err != nil {
-		// Si hay error con la base de datos, devolver datos de prueba
-		mockData := []map[string]interface{}{
-			{
-				"userId":   "1",
-				"username": "ValorantPro",
-				"elo":      2100,
-				"wins":     45,
-				"losses":   15,
-			},
-			{
-				"userId":   "2",
-				"username": "DiamondPlayer",
-				"elo":      1750,
-				"wins":     38,
-				"losses":   22,
-			},
-			{
-				"userId":   "3",
-				"username": "PlatinumAce",
-				"elo":      1450,
-				"wins":     28,
-				"losses":   18,
-			},
-			{
-				"userId":   "4",
-				"username": "GoldSniper",
-				"elo":      1250,
-				"wins":     22,
-				"losses":   20,
-			},
-			{
-				"userId":   "5",
-				"username": "SilverStrike",
-				"elo":      1050,
-				"wins":     15,
-				"losses":   25,
-			},
-		}
-
-		utils.SuccessResponse(w, mockData)
+// getSeasonLeaderboard serves GetLeaderboard when a season is requested
+func (lh *LeaderboardHandler) getSeasonLeaderboard(w http.ResponseWriter, seasonStr string, limit, offset int) {
+	season, err := lh.resolveSeason(seasonStr)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// The snapshot already decided who was ranked, only live standings hide provisional players
+	query := activeSeasonLeaderboardQuery
+	args := []interface{}{limit, offset, season.ID, lh.placementMatches()}
+	if season.Status == models.SeasonStatusEnded {
+		query = endedSeasonLeaderboardQuery
+		args = args[:3]
+	}
+
+	var total int
+	if season.Status == models.SeasonStatusEnded {
+		err = database.DB.QueryRow(`SELECT COUNT(*) FROM season_standings WHERE season_id = $1`, season.ID).Scan(&total)
+	} else {
+		err = database.DB.QueryRow(`
+            SELECT COUNT(*) FROM season_ratings sr
+            JOIN users u ON u.id = sr.user_id
+            WHERE sr.season_id = $1 AND COALESCE(u.games_played, 0) >= $2
+        `, season.ID, lh.placementMatches()).Scan(&total)
+	}
+	if err != nil {
+		utils.ErrorResponse(w, "Failed to load season leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		utils.ErrorResponse(w, "Failed to load season leaderboard", http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
 
-	var leaderboard []models.UserStats
+	leaderboard := []models.UserStats{}
 	for rows.Next() {
-		var user models.UserStats
-		err := rows.Scan(
-			&user.ID, &user.Username, &user.Email, &user.ELO,
-			&user.Wins, &user.Losses, &user.CreatedAt, &user.UpdatedAt,
-			&user.WinRate, &user.GamesTotal, &user.Rank,
-		)
+		user, err := lh.scanUserStats(rows)
 		if err != nil {
 			utils.ErrorResponse(w, "Failed to scan leaderboard data", http.StatusInternalServerError)
 			return
 		}
+		user.Season = season.ID
 		leaderboard = append(leaderboard, user)
 	}
 
-	// Si no hay datos, devolver datos de prueba
-	if len(leaderboard) == 0 {
-		mockData := []map[string]interface{}{
-			{
-				"userId":   "1",
-				"username": "ValorantPro",
-				"elo":      2100,
-				"wins":     45,
-				"losses":   15,
-			},
-			{
-				"userId":   "2",
-				"username": "DiamondPlayer",
-				"elo":      1750,
-				"wins":     38,
-				"losses":   22,
-			},
-			{
-				"userId":   "3",
-				"username": "PlatinumAce",
-				"elo":      1450,
-				"wins":     28,
-				"losses":   18,
-			},
-			{
-				"userId":   "4",
-				"username": "GoldSniper",
-				"elo":      1250,
-				"wins":     22,
-				"losses":   20,
-			},
-			{
-				"userId":   "5",
-				"username": "SilverStrike",
-				"elo":      1050,
-				"wins":     15,
-				"losses":   25,
-			},
-		}
-
-		utils.SuccessResponse(w, mockData)
-		return
-	}
-
 	response := map[string]interface{}{
 		"leaderboard": leaderboard,
-		"total":       len(leaderboard),
+		"total":       total,
 		"limit":       limit,
 		"offset":      offset,
+		"season":      season,
 	}
 
 	utils.SuccessResponse(w, response)
 }
 
+// resolveSeason accepts a season ID or "current"
+func (lh *LeaderboardHandler) resolveSeason(seasonStr string) (*models.Season, error) {
+	if lh.seasonService == nil {
+		return nil, fmt.Errorf("seasons are not enabled")
+	}
+	if seasonStr == "current" {
+		return lh.seasonService.CurrentSeason()
+	}
+
+	seasonID, err := strconv.Atoi(seasonStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid season %q", seasonStr)
+	}
+	return lh.seasonService.GetSeason(seasonID)
+}
+
+// GetUserRank returns a player's stats and rank under the same ordering as
+// GetLeaderboard, including players still in placement matches
 func (lh *LeaderboardHandler) GetUserRank(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("user_id")
 	if userID == "" {
@@ -170,30 +647,141 @@ func (lh *LeaderboardHandler) GetUserRank(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	query := `
+	if seasonStr := r.URL.Query().Get("season"); seasonStr != "" {
+		lh.getSeasonUserRank(w, userID, seasonStr)
+		return
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = lh.defaultSort()
+	}
+	sortKey, ok := leaderboardSorts[sortBy]
+	if !ok {
+		utils.ErrorResponse(w, "sort must be 'elo' or 'conservative'", http.StatusBadRequest)
+		return
+	}
+
+	if lh.useIndex() {
+		// Career stats change without the index, e.g. when stats are submitted
+		etag := lh.index.ETag()
+		if lh.statsService != nil {
+			version, err := lh.statsService.CareerVersion(userID)
+			if err != nil {
+				lh.logger.WarnContext(r.Context(), "could not load career version", "rank_user_id", userID, "error", err)
+				etag = ""
+			} else {
+				etag = strings.TrimSuffix(etag, `"`) + "-" + version + `"`
+			}
+		}
+		if etag != "" && checkETag(w, r, etag) {
+			return
+		}
+		users, err := lh.loadPositions([]services.LeaderboardPosition{{UserID: userID}})
+		if err != nil || len(users) == 0 {
+			utils.ErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		}
+		user := users[0]
+		user.Rank, _ = lh.index.Rank(sortBy, userID)
+		lh.writeUserRank(w, r, user)
+		return
+	}
+
+	query := rankedUsersCTE(sortKey) + `
         SELECT 
-            id, username, email, elo, wins, losses, created_at, updated_at,
+            id, username, email, elo, wins, losses,
+            COALESCE(rating_deviation, 0), COALESCE(volatility, 0), COALESCE(games_played, 0), last_match_at,
+            created_at, updated_at,
             CASE 
                 WHEN (wins + losses) > 0 THEN ROUND((wins::float / (wins + losses)::float) * 100, 2)
                 ELSE 0 
             END as win_rate,
             (wins + losses) as games_total,
-            (SELECT COUNT(*) + 1 FROM users u2 WHERE u2.elo > u1.elo) as rank
+            COALESCE((SELECT rank FROM ranked WHERE ranked.id = u1.id), 0) as rank,
+            elo - 2 * COALESCE(rating_deviation, 0) as conservative_rating,
+            COALESCE(rank_tier, -1)
         FROM users u1
-        WHERE id = $1
+        WHERE id::text = $2
     `
 
-	var user models.UserStats
-	err := database.DB.QueryRow(query, userID).Scan(
-		&user.ID, &user.Username, &user.Email, &user.ELO,
-		&user.Wins, &user.Losses, &user.CreatedAt, &user.UpdatedAt,
-		&user.WinRate, &user.GamesTotal, &user.Rank,
-	)
+	placementMatches := lh.placementMatches()
+	user, err := lh.scanUserStats(database.DB.QueryRow(query, placementMatches, userID))
 
 	if err != nil {
 		utils.ErrorResponse(w, "User not found", http.StatusNotFound)
 		return
 	}
 
+	lh.writeUserRank(w, r, user)
+}
+
+// writeUserRank adds placement progress and career stats to a player's rank
+func (lh *LeaderboardHandler) writeUserRank(w http.ResponseWriter, r *http.Request, user models.UserStats) {
+	// Provisional players are shown as unranked
+	placementMatches := lh.placementMatches()
+	if user.Provisional {
+		user.Rank = 0
+		user.PlacementMatchesLeft = placementMatches - user.GamesPlayed
+	}
+
+	if lh.statsService != nil {
+		if career, err := lh.statsService.GetCareerStats(user.ID, 0); err == nil {
+			user.Career = career
+		} else {
+			lh.logger.WarnContext(r.Context(), "could not load career stats", "rank_user_id", user.ID, "error", err)
+		}
+	}
+
 	utils.SuccessResponse(w, user)
 }
+
+// getSeasonUserRank serves GetUserRank when a season is requested
+func (lh *LeaderboardHandler) getSeasonUserRank(w http.ResponseWriter, userID, seasonStr string) {
+	season, err := lh.resolveSeason(seasonStr)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	records, err := lh.seasonService.GetUserSeasons(userID)
+	if err != nil {
+		utils.ErrorResponse(w, "Failed to load season record", http.StatusInternalServerError)
+		return
+	}
+
+	for _, record := range records {
+		if record.SeasonID != season.ID {
+			continue
+		}
+
+		// Live seasons rank against everyone above the player right now
+		if record.FinalRank == nil {
+			var rank int
+			err := database.DB.QueryRow(`
+                SELECT COUNT(*) + 1 FROM season_ratings sr
+                JOIN users u ON u.id = sr.user_id
+                WHERE sr.season_id = $1 AND sr.rating > $2 AND COALESCE(u.games_played, 0) >= $3
+            `, season.ID, record.Rating, lh.placementMatches()).Scan(&rank)
+			if err != nil {
+				utils.ErrorResponse(w, "Failed to compute season rank", http.StatusInternalServerError)
+				return
+			}
+			record.FinalRank = &rank
+		}
+
+		response := map[string]interface{}{
+			"season": season,
+			"record": record,
+		}
+		if lh.statsService != nil {
+			if stats, err := lh.statsService.GetCareerStats(userID, season.ID); err == nil {
+				response["stats"] = stats
+			}
+		}
+		utils.SuccessResponse(w, response)
+		return
+	}
+
+	utils.ErrorResponse(w, "User has no games in this season", http.StatusNotFound)
+}
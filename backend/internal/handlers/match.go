@@ -3,7 +3,12 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"valorant-mobile-web/backend/internal/logging"
+	"valorant-mobile-web/backend/internal/metrics"
+	"valorant-mobile-web/backend/internal/models"
 	"valorant-mobile-web/backend/internal/services"
 	"valorant-mobile-web/backend/pkg/utils"
 
@@ -12,18 +17,38 @@ import (
 
 type MatchHandler struct {
 	matchRoomService *services.MatchRoomService
+	ratingService    *services.RatingService
+	tierService      *services.TierService
+	archiveService   *services.MatchArchiveService
+	importService    *services.RiotImportService
+	leaderboardIndex *services.LeaderboardIndex
+	logger           *slog.Logger
 }
 
 func NewMatchHandler() *MatchHandler {
 	return &MatchHandler{
 		matchRoomService: services.NewMatchRoomService(),
+		logger:           logging.Component("match"),
+	}
+}
+
+// NewMatchHandlerWithServices creates a MatchHandler with shared service instances
+func NewMatchHandlerWithServices(matchRoomService *services.MatchRoomService, ratingService *services.RatingService, tierService *services.TierService, archiveService *services.MatchArchiveService, importService *services.RiotImportService, leaderboardIndex *services.LeaderboardIndex, logger *slog.Logger) *MatchHandler {
+	return &MatchHandler{
+		matchRoomService: matchRoomService,
+		ratingService:    ratingService,
+		tierService:      tierService,
+		archiveService:   archiveService,
+		importService:    importService,
+		leaderboardIndex: leaderboardIndex,
+		logger:           logger,
 	}
 }
 
 func (mh *MatchHandler) StartMatch(w http.ResponseWriter, r *http.Request) {
 	match, err := mh.matchRoomService.CreateMatchRoom()
 	if err != nil {
-		utils.ErrorResponse(w, "Failed to start match: "+err.Error(), http.StatusBadRequest)
+		serviceErrorResponse(w, "Failed to start match: "+err.Error(), err, http.StatusBadRequest)
 		return
 	}
 
@@ -75,8 +100,10 @@ func (mh *MatchHandler) SelectMap(w http.ResponseWriter, r *http.Request) {
 }
 
 type ReportResultRequest struct {
-	MatchID string `json:"match_id"`
-	Winner  string `json:"winner"` // "team1", "team2", "tie"
+	MatchID     string `json:"match_id"`
+	Winner      string `json:"winner"` // "team1", "team2", "tie"
+	Team1Rounds int    `json:"team1_rounds"`
+	Team2Rounds int    `json:"team2_rounds"`
 }
 
 func (mh *MatchHandler) ReportResult(w http.ResponseWriter, r *http.Request) {
@@ -102,6 +129,236 @@ func (mh *MatchHandler) ReportResult(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: Implementar lógica de votación
-	utils.MessageResponse(w, "Vote recorded successfully")
+	match, err := mh.matchRoomService.ReportResult(req.MatchID, userID, models.MatchResult{
+		Winner:      req.Winner,
+		Team1Rounds: req.Team1Rounds,
+		Team2Rounds: req.Team2Rounds,
+	})
+	if err != nil {
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
+		return
+	}
+
+	mh.completeMatch(w, r, match, nil, "reported")
+}
+
+// completeMatch rates, tiers and archives a match whose result was just
+// recorded and writes the response. The match is only completed once it is
+// rated; if rating fails the result is released so it can be reported again.
+// source says how the result was reported, for metrics.
+func (mh *MatchHandler) completeMatch(w http.ResponseWriter, r *http.Request, match *models.Match, stats map[string]models.PlayerMatchStats, source string) {
+	// Results are reported with the match ID in the body, not the route
+	ctx := logging.With(r.Context(), "match_id", match.ID)
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Result reported successfully",
+		"match":   match,
+	}
+
+	var audit *models.RatingAudit
+	if mh.ratingService != nil {
+		ratings, err := mh.ratingService.ApplyMatchResult(match, stats)
+		if err != nil {
+			mh.logger.ErrorContext(ctx, "could not apply ratings", "error", err)
+			if err := mh.matchRoomService.ReleaseResult(match.ID); err != nil {
+				mh.logger.ErrorContext(ctx, "could not release match result", "match_id", match.ID, "error", err)
+			}
+			utils.ErrorResponse(w, "Ratings could not be updated, please report the result again", http.StatusInternalServerError)
+			return
+		}
+		audit = &ratings.Audit
+		response["team1_ratings"] = ratings.Team1
+		response["team2_ratings"] = ratings.Team2
+		if len(ratings.Leavers) > 0 {
+			response["leaver_ratings"] = ratings.Leavers
+		}
+
+		// Tiers and leaderboard positions are derived from ratings, so a
+		// failure here does not undo the result
+		changed := append(append(append([]models.Elo{}, ratings.Team1...), ratings.Team2...), ratings.Leavers...)
+		if mh.tierService != nil {
+			events, err := mh.tierService.ApplyRatingChanges(match.ID, changed)
+			if err != nil {
+				mh.logger.ErrorContext(ctx, "could not update tiers", "error", err)
+			}
+			response["tier_events"] = events
+		}
+		if mh.leaderboardIndex != nil {
+			userIDs := make([]string, len(changed))
+			for i, rating := range changed {
+				userIDs[i] = rating.UserID
+			}
+			if err := mh.leaderboardIndex.Refresh(userIDs); err != nil {
+				mh.logger.ErrorContext(ctx, "could not update leaderboard", "error", err)
+			}
+		}
+	}
+
+	// Rated, so the match is done whatever happens to the archive
+	if err := mh.matchRoomService.FinishResult(match.ID); err != nil {
+		mh.logger.WarnContext(ctx, "could not complete match room", "error", err)
+	}
+	match.Status = models.MatchStatusCompleted
+	metrics.Matches.Inc("completed", source)
+
+	if mh.archiveService != nil {
+		if _, err := mh.archiveService.ArchiveMatch(match, audit, stats); err != nil {
+			mh.logger.ErrorContext(ctx, "could not archive match, retrying later", "error", err)
+			mh.archiveService.ArchiveLater(match, audit, stats, err)
+		}
+	}
+
+	utils.SuccessResponse(w, response)
+}
+
+type ImportMatchRequest struct {
+	RiotMatchID string            `json:"riot_match_id"` // Fetched through the Riot API client
+	Match       *models.RiotMatch `json:"match"`         // Or a match document pasted as is
+}
+
+// ImportMatch completes a match from a Riot match document: the result,
+// round score, map and every player's stats are taken from the document,
+// whose roster must match the lobby. Only captains can import.
+func (mh *MatchHandler) ImportMatch(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "Unauthorized - User ID required", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	matchID := vars["id"]
+
+	var req ImportMatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if (req.RiotMatchID == "") == (req.Match == nil) {
+		utils.ErrorResponse(w, "provide either riot_match_id or match", http.StatusBadRequest)
+		return
+	}
+
+	match, err := mh.matchRoomService.GetMatchRoom(matchID)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if userID != match.Captain1 && userID != match.Captain2 {
+		utils.ErrorResponse(w, "only captains can import the result", http.StatusForbidden)
+		return
+	}
+
+	doc := req.Match
+	if doc == nil {
+		doc, err = mh.importService.FetchMatch(req.RiotMatchID)
+		if err != nil {
+			utils.ErrorResponse(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	imported, err := mh.importService.Resolve(match, doc)
+	if err != nil {
+		utils.ErrorResponse(w, "Riot match rejected: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	match, err = mh.matchRoomService.ReportResultOnMap(matchID, userID, imported.Map, imported.Result)
+	if err != nil {
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
+		return
+	}
+
+	for id, line := range imported.Stats {
+		line.SubmittedBy = userID
+		line.SubmittedAt = match.Result.ReportedAt
+		imported.Stats[id] = line
+	}
+
+	mh.logger.InfoContext(r.Context(), "riot match imported", "riot_match_id", imported.RiotMatchID)
+	mh.completeMatch(w, r, match, imported.Stats, "riot_import")
+}
+
+// GetMatch returns the archived record of a completed match
+func (mh *MatchHandler) GetMatch(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	matchID := vars["id"]
+
+	if matchID == "" {
+		utils.ErrorResponse(w, "match_id is required", http.StatusBadRequest)
+		return
+	}
+
+	match, err := mh.archiveService.GetMatch(matchID)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	utils.SuccessResponse(w, match)
+}
+
+// GetUserMatches returns a player's completed matches, newest first.
+// Supports limit/offset pagination and map, result and season filters.
+func (mh *MatchHandler) GetUserMatches(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := services.MatchHistoryFilter{
+		Map:     query.Get("map"),
+		Outcome: models.MatchOutcome(query.Get("result")),
+		Limit:   20,
+	}
+
+	switch filter.Outcome {
+	case "", models.MatchOutcomeWin, models.MatchOutcomeLoss, models.MatchOutcomeDraw:
+	default:
+		utils.ErrorResponse(w, "result must be 'win', 'loss' or 'draw'", http.StatusBadRequest)
+		return
+	}
+
+	if seasonStr := query.Get("season"); seasonStr != "" {
+		seasonID, err := strconv.Atoi(seasonStr)
+		if err != nil || seasonID <= 0 {
+			utils.ErrorResponse(w, "season must be a season ID", http.StatusBadRequest)
+			return
+		}
+		filter.SeasonID = seasonID
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			filter.Limit = l
+		}
+	}
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			filter.Offset = o
+		}
+	}
+
+	matches, total, err := mh.archiveService.GetUserMatches(userID, filter)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"user_id": userID,
+		"matches": matches,
+		"total":   total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+	}
+	utils.SuccessResponse(w, response)
 }
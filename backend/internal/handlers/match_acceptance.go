@@ -2,7 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"valorant-mobile-web/backend/internal/services"
 	"valorant-mobile-web/backend/pkg/utils"
@@ -12,6 +12,7 @@ import (
 
 type MatchAcceptanceHandler struct {
 	acceptanceService *services.MatchAcceptanceService
+	logger            *slog.Logger
 }
 
 // Remove default constructor to enforce singleton usage
@@ -22,9 +23,10 @@ type MatchAcceptanceHandler struct {
 // }
 
 // NewMatchAcceptanceHandlerWithService creates a MatchAcceptanceHandler with shared service instance
-func NewMatchAcceptanceHandlerWithService(acceptanceService *services.MatchAcceptanceService) *MatchAcceptanceHandler {
+func NewMatchAcceptanceHandlerWithService(acceptanceService *services.MatchAcceptanceService, logger *slog.Logger) *MatchAcceptanceHandler {
 	return &MatchAcceptanceHandler{
 		acceptanceService: acceptanceService,
+		logger:            logger,
 	}
 }
 
@@ -33,14 +35,12 @@ type AcceptMatchRequest struct {
 }
 
 func (mah *MatchAcceptanceHandler) AcceptMatch(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("=== ACCEPT MATCH HANDLER ===\n")
-
 	vars := mux.Vars(r)
 	matchID := vars["id"]
 
 	var req AcceptMatchRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		fmt.Printf("Error decoding request: %v\n", err)
+		mah.logger.DebugContext(r.Context(), "invalid request body", "error", err)
 		utils.ErrorResponse(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
@@ -55,12 +55,12 @@ func (mah *MatchAcceptanceHandler) AcceptMatch(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	fmt.Printf("User %s accepting match %s\n", req.UserID, matchID)
+	mah.logger.DebugContext(r.Context(), "accepting match", "user_id", req.UserID)
 
 	err := mah.acceptanceService.AcceptMatch(matchID, req.UserID)
 	if err != nil {
-		fmt.Printf("Error accepting match: %v\n", err)
-		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		mah.logger.InfoContext(r.Context(), "could not accept match", "user_id", req.UserID, "error", err)
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
 		return
 	}
 
@@ -73,14 +73,12 @@ func (mah *MatchAcceptanceHandler) AcceptMatch(w http.ResponseWriter, r *http.Re
 }
 
 func (mah *MatchAcceptanceHandler) DeclineMatch(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("=== DECLINE MATCH HANDLER ===\n")
-
 	vars := mux.Vars(r)
 	matchID := vars["id"]
 
 	var req AcceptMatchRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		fmt.Printf("Error decoding request: %v\n", err)
+		mah.logger.DebugContext(r.Context(), "invalid request body", "error", err)
 		utils.ErrorResponse(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
@@ -95,12 +93,12 @@ func (mah *MatchAcceptanceHandler) DeclineMatch(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	fmt.Printf("User %s declining match %s\n", req.UserID, matchID)
+	mah.logger.DebugContext(r.Context(), "declining match", "user_id", req.UserID)
 
 	err := mah.acceptanceService.DeclineMatch(matchID, req.UserID)
 	if err != nil {
-		fmt.Printf("Error declining match: %v\n", err)
-		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		mah.logger.InfoContext(r.Context(), "could not decline match", "user_id", req.UserID, "error", err)
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
 		return
 	}
 
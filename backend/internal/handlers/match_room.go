@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 	"valorant-mobile-web/backend/internal/models"
@@ -15,10 +16,17 @@ import (
 type MatchRoomHandler struct {
 	matchRoomService *services.MatchRoomService
 	queueService     *services.QueueService
+	abandonService   *services.AbandonService
+	logger           *slog.Logger
 }
 
 type SetCaptainSelectionRequest struct {
-	Method string `json:"method"` // "voting" or "random"
+	Method string `json:"method"` // "voting", "random" or "auto_balance"
+
+	// Optional voting rules, defaults come from models.DefaultCaptainVotingRules
+	VotingDurationSeconds int   `json:"voting_duration_seconds"`
+	AllowSelfVote         *bool `json:"allow_self_vote"`
+	MaxCandidates         int   `json:"max_candidates"`
 }
 
 type VoteCaptainRequest struct {
@@ -34,60 +42,50 @@ type VoteCaptainRequest struct {
 // }
 
 // NewMatchRoomHandlerWithServices creates a MatchRoomHandler with shared service instances
-func NewMatchRoomHandlerWithServices(matchRoomService *services.MatchRoomService, queueService *services.QueueService) *MatchRoomHandler {
+func NewMatchRoomHandlerWithServices(matchRoomService *services.MatchRoomService, queueService *services.QueueService, abandonService *services.AbandonService, logger *slog.Logger) *MatchRoomHandler {
 	return &MatchRoomHandler{
 		matchRoomService: matchRoomService,
 		queueService:     queueService,
+		abandonService:   abandonService,
+		logger:           logger,
 	}
 }
 
 // CreateMatchRoom creates a new match room when queue is full
 func (mrh *MatchRoomHandler) CreateMatchRoom(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("=== CREATE MATCH ROOM REQUEST ===\n")
-
 	// Check if queue is ready for match
 	status, err := mrh.queueService.GetQueueStatus()
 	if err != nil {
-		fmt.Printf("ERROR getting queue status: %v\n", err)
+		mrh.logger.ErrorContext(r.Context(), "could not get queue status", "error", err)
 		utils.ErrorResponse(w, "Failed to get queue status", http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Printf("Queue status - Players: %d, CanStart: %v, ShouldCreate: %v\n",
-		status.PlayersInQueue, status.CanStartMatch, status.ShouldCreateMatch)
-
-	if !status.CanStartMatch {
-		fmt.Printf("ERROR: Not enough players. Need 2, have %d\n", status.PlayersInQueue) // TEMPORARY: Changed from 10 to 2
+	if !status.CanStartMatch { // TEMPORARY: Changed from 10 to 2
 		utils.ErrorResponse(w, fmt.Sprintf("Not enough players in queue to create match. Need 2, have %d", status.PlayersInQueue), http.StatusBadRequest)
 		return
 	}
 
-	fmt.Printf("Starting match room creation...\n")
-
 	// Create match room
 	match, err := mrh.matchRoomService.CreateMatchRoom()
 	if err != nil {
-		fmt.Printf("ERROR creating match room: %v\n", err)
-		utils.ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		mrh.logger.ErrorContext(r.Context(), "could not create match room", "error", err)
+		serviceErrorResponse(w, err.Error(), err, http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Printf("SUCCESS: Match room created with ID: %s\n", match.ID)
+	mrh.logger.InfoContext(r.Context(), "match room created", "match_id", match.ID,
+		"status", match.Status, "players", len(match.Players))
 
 	response := map[string]interface{}{
 		"match": match,
 	}
 
-	fmt.Printf("SENDING RESPONSE: %+v\n", response)
-	fmt.Printf("MATCH OBJECT: ID=%s, Status=%s, Players=%d\n", match.ID, match.Status, len(match.Players))
-
 	utils.SuccessResponse(w, response)
 }
 
 // DebugMatchRoom provides debug information about the current queue state
 func (mrh *MatchRoomHandler) DebugMatchRoom(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("=== DEBUG MATCH ROOM ===\n")
-
 	// Check queue status
 	status, err := mrh.queueService.GetQueueStatus()
 	if err != nil {
@@ -137,26 +135,18 @@ func (mrh *MatchRoomHandler) GetMatchRoom(w http.ResponseWriter, r *http.Request
 
 // GetPlayerMatchRoom gets the match room that a player is currently in
 func (mrh *MatchRoomHandler) GetPlayerMatchRoom(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("=== GET PLAYER MATCH ROOM REQUEST ===\n")
-
 	userID := r.Header.Get("X-User-ID")
-	fmt.Printf("User ID from header: '%s'\n", userID)
-
 	if userID == "" {
-		fmt.Printf("ERROR: User ID is missing\n")
 		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
 		return
 	}
 
-	fmt.Printf("Looking for match room for user: %s\n", userID)
 	match, err := mrh.matchRoomService.GetPlayerMatchRoom(userID)
 	if err != nil {
-		fmt.Printf("ERROR: %v\n", err)
 		utils.ErrorResponse(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	fmt.Printf("SUCCESS: Found match room %s for user %s\n", match.ID, userID)
 	response := map[string]interface{}{
 		"match": match,
 	}
@@ -185,14 +175,29 @@ func (mrh *MatchRoomHandler) SetCaptainSelectionMethod(w http.ResponseWriter, r
 		method = models.CaptainSelectionVoting
 	case "random":
 		method = models.CaptainSelectionRandom
+	case "auto_balance":
+		method = models.CaptainSelectionAuto
 	default:
 		utils.ErrorResponse(w, "Invalid captain selection method", http.StatusBadRequest)
 		return
 	}
 
-	err := mrh.matchRoomService.SetCaptainSelectionMethod(matchID, method)
+	rules := models.DefaultCaptainVotingRules
+	if req.VotingDurationSeconds > 0 {
+		rules.DurationSeconds = req.VotingDurationSeconds
+	}
+	if req.AllowSelfVote != nil {
+		rules.AllowSelfVote = *req.AllowSelfVote
+	}
+	if req.MaxCandidates < 0 || req.MaxCandidates == 1 {
+		utils.ErrorResponse(w, "max_candidates must be 0 or at least 2", http.StatusBadRequest)
+		return
+	}
+	rules.MaxCandidates = req.MaxCandidates
+
+	err := mrh.matchRoomService.SetCaptainSelectionMethod(matchID, method, rules)
 	if err != nil {
-		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
 		return
 	}
 
@@ -236,7 +241,7 @@ func (mrh *MatchRoomHandler) VoteForCaptain(w http.ResponseWriter, r *http.Reque
 
 	err := mrh.matchRoomService.VoteForCaptain(matchID, userID, req.CandidateID)
 	if err != nil {
-		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
 		return
 	}
 
@@ -255,3 +260,231 @@ func (mrh *MatchRoomHandler) VoteForCaptain(w http.ResponseWriter, r *http.Reque
 
 	utils.SuccessResponse(w, response)
 }
+
+type SetLobbyHostRequest struct {
+	HostID string `json:"host_id"`
+}
+
+type PostPartyCodeRequest struct {
+	PartyCode string `json:"party_code"`
+}
+
+// GetLobby returns the in-game lobby of a match with its party code. Only
+// players of the match can see it.
+func (mrh *MatchRoomHandler) GetLobby(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	matchID := vars["matchId"]
+
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	lobby, err := mrh.matchRoomService.GetLobby(matchID, userID)
+	if err != nil {
+		serviceErrorResponse(w, err.Error(), err, http.StatusForbidden)
+		return
+	}
+
+	response := map[string]interface{}{
+		"lobby":      lobby,
+		"party_code": lobby.PartyCode,
+	}
+	utils.SuccessResponse(w, response)
+}
+
+// SetLobbyHost hands hosting the in-game lobby to another player
+func (mrh *MatchRoomHandler) SetLobbyHost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	matchID := vars["matchId"]
+
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var req SetLobbyHostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.HostID == "" {
+		utils.ErrorResponse(w, "host_id is required", http.StatusBadRequest)
+		return
+	}
+
+	match, err := mrh.matchRoomService.SetLobbyHost(matchID, userID, req.HostID)
+	if err != nil {
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("%s is now hosting the lobby", req.HostID),
+		"match":   match,
+	}
+	utils.SuccessResponse(w, response)
+}
+
+// PostPartyCode lets the host share the party code of the in-game lobby
+func (mrh *MatchRoomHandler) PostPartyCode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	matchID := vars["matchId"]
+
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var req PostPartyCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	match, err := mrh.matchRoomService.PostPartyCode(matchID, userID, req.PartyCode)
+	if err != nil {
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Party code posted",
+		"match":   match,
+	}
+	utils.SuccessResponse(w, response)
+}
+
+// CheckInLobby records that a player joined the in-game lobby
+func (mrh *MatchRoomHandler) CheckInLobby(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	matchID := vars["matchId"]
+
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	match, err := mrh.matchRoomService.CheckInLobby(matchID, userID)
+	if err != nil {
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Checked in to the lobby",
+		"match":   match,
+	}
+	utils.SuccessResponse(w, response)
+}
+
+type RequestSubstituteRequest struct {
+	LeaverID string `json:"leaver_id"`
+}
+
+// RequestSubstitute lets a captain replace a player who left the match
+func (mrh *MatchRoomHandler) RequestSubstitute(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	matchID := vars["matchId"]
+
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var req RequestSubstituteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LeaverID == "" {
+		utils.ErrorResponse(w, "leaver_id is required", http.StatusBadRequest)
+		return
+	}
+
+	match, err := mrh.matchRoomService.RequestSubstitute(matchID, userID, req.LeaverID)
+	if err != nil {
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Substitute requested",
+		"match":   match,
+	}
+	utils.SuccessResponse(w, response)
+}
+
+// GetSubstituteOffer returns the substitute offer waiting for the calling player
+func (mrh *MatchRoomHandler) GetSubstituteOffer(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	match, offer, err := mrh.matchRoomService.GetSubstituteOffer(userID)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"match": match,
+		"offer": offer,
+	}
+	utils.SuccessResponse(w, response)
+}
+
+// AcceptSubstitute swaps the calling player into the match they were offered
+// and records the abandon against the player they replace
+func (mrh *MatchRoomHandler) AcceptSubstitute(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	matchID := vars["matchId"]
+
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	match, substitution, err := mrh.matchRoomService.AcceptSubstitute(matchID, userID)
+	if err != nil {
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
+		return
+	}
+
+	if mrh.abandonService != nil {
+		if err := mrh.abandonService.RecordAbandon(matchID, *substitution); err != nil {
+			mrh.logger.ErrorContext(r.Context(), "could not record abandon", "leaver_id", substitution.LeaverID, "error", err)
+		}
+	}
+
+	response := map[string]interface{}{
+		"success":      true,
+		"message":      "Joined the match as a substitute",
+		"match":        match,
+		"substitution": substitution,
+	}
+	utils.SuccessResponse(w, response)
+}
+
+// DeclineSubstitute turns down a substitute offer
+func (mrh *MatchRoomHandler) DeclineSubstitute(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	matchID := vars["matchId"]
+
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := mrh.matchRoomService.DeclineSubstitute(matchID, userID); err != nil {
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
+		return
+	}
+
+	utils.MessageResponse(w, "Substitute offer declined")
+}
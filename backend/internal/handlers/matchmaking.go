@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"valorant-mobile-web/backend/internal/logging"
 	"valorant-mobile-web/backend/internal/services"
 	"valorant-mobile-web/backend/pkg/utils"
 )
@@ -14,7 +15,7 @@ type MatchmakingHandler struct {
 
 func NewMatchmakingHandler() *MatchmakingHandler {
 	return &MatchmakingHandler{
-		queueService: services.NewQueueService(),
+		queueService: services.NewQueueService(logging.Component("queue")),
 	}
 }
 
@@ -42,7 +43,7 @@ func (h *MatchmakingHandler) JoinQueue(w http.ResponseWriter, r *http.Request) {
 
 	err := h.queueService.JoinQueue(userID, username, elo)
 	if err != nil {
-		utils.ErrorResponse(w, "Failed to join queue", http.StatusInternalServerError)
+		serviceErrorResponse(w, "Failed to join queue", err, http.StatusInternalServerError)
 		return
 	}
 
@@ -58,7 +59,7 @@ func (h *MatchmakingHandler) LeaveQueue(w http.ResponseWriter, r *http.Request)
 
 	err := h.queueService.LeaveQueue(userID)
 	if err != nil {
-		utils.ErrorResponse(w, "Failed to leave queue", http.StatusInternalServerError)
+		serviceErrorResponse(w, "Failed to leave queue", err, http.StatusInternalServerError)
 		return
 	}
 
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"valorant-mobile-web/backend/internal/database"
+	"valorant-mobile-web/backend/internal/models"
+	"valorant-mobile-web/backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// Defaults of the named leaderboard parameters
+const (
+	defaultLeaderboardMinGames = 5
+	defaultImprovedDays        = 30
+)
+
+// namedLeaderboard ranks players by a single metric, highest first. query
+// reads the leaderboard's parameters from the request and returns SQL
+// selecting user_id, value and games for every player on it, with its
+// arguments.
+type namedLeaderboard struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Metric      string   `json:"metric"`
+	Params      []string `json:"params,omitempty"`
+	query       func(lh *LeaderboardHandler, r *http.Request) (string, []interface{}, error)
+}
+
+// winRateSQL is a player's win rate in percent over the rows of a group
+const winRateSQL = `ROUND(COUNT(*) FILTER (WHERE p.outcome = 'win') * 100.0 / COUNT(*), 2)::float8`
+
+var namedLeaderboards = []namedLeaderboard{
+	{
+		Name:        "region",
+		Description: "Ranked players with a verified Riot ID in a region",
+		Metric:      "rating",
+		Params:      []string{"region"},
+		query: func(lh *LeaderboardHandler, r *http.Request) (string, []interface{}, error) {
+			region := strings.ToLower(r.URL.Query().Get("region"))
+			if !contains(models.RiotRegions, region) {
+				return "", nil, fmt.Errorf("region must be one of %s", strings.Join(models.RiotRegions, ", "))
+			}
+			return `
+                SELECT id as user_id, elo::float8 as value, COALESCE(games_played, 0) as games
+                FROM users
+                WHERE riot_region = $1 AND riot_verified_at IS NOT NULL AND COALESCE(games_played, 0) >= $2
+            `, []interface{}{region, lh.placementMatches()}, nil
+		},
+	},
+	{
+		Name:        "season",
+		Description: "Season rating, live for the current season and final for ended ones",
+		Metric:      "season_rating",
+		Params:      []string{"season"},
+		query: func(lh *LeaderboardHandler, r *http.Request) (string, []interface{}, error) {
+			seasonStr := r.URL.Query().Get("season")
+			if seasonStr == "" {
+				seasonStr = "current"
+			}
+			season, err := lh.resolveSeason(seasonStr)
+			if err != nil {
+				return "", nil, err
+			}
+			if season.Status == models.SeasonStatusEnded {
+				return `
+                    SELECT user_id, final_rating::float8 as value, games_played as games
+                    FROM season_standings
+                    WHERE season_id = $1
+                `, []interface{}{season.ID}, nil
+			}
+			return `
+                SELECT sr.user_id, sr.rating::float8 as value, sr.games_played as games
+                FROM season_ratings sr
+                JOIN users u ON u.id = sr.user_id
+                WHERE sr.season_id = $1 AND COALESCE(u.games_played, 0) >= $2
+            `, []interface{}{season.ID, lh.placementMatches()}, nil
+		},
+	},
+	{
+		Name:        "map",
+		Description: "Win rate on a map, for players with at least min_games games on it",
+		Metric:      "map_win_rate",
+		Params:      []string{"map", "min_games"},
+		query: func(lh *LeaderboardHandler, r *http.Request) (string, []interface{}, error) {
+			mapName := strings.ToLower(r.URL.Query().Get("map"))
+			if !contains(models.ValorantMaps, mapName) {
+				return "", nil, fmt.Errorf("map must be one of %s", strings.Join(models.ValorantMaps, ", "))
+			}
+			minGames, err := intParam(r, "min_games", defaultLeaderboardMinGames, 1, 1000)
+			if err != nil {
+				return "", nil, err
+			}
+			return `
+                SELECT p.user_id, ` + winRateSQL + ` as value, COUNT(*) as games
+                FROM match_archive_players p
+                JOIN match_archive a ON a.match_id = p.match_id
+                WHERE a.selected_map = $1
+                GROUP BY p.user_id
+                HAVING COUNT(*) >= $2
+            `, []interface{}{mapName, minGames}, nil
+		},
+	},
+	{
+		Name:        "agent",
+		Description: "Win rate on an agent, for players with at least min_games games on it",
+		Metric:      "agent_win_rate",
+		Params:      []string{"agent", "min_games"},
+		query: func(lh *LeaderboardHandler, r *http.Request) (string, []interface{}, error) {
+			agent := strings.ToLower(r.URL.Query().Get("agent"))
+			if !contains(models.ValorantAgents, agent) {
+				return "", nil, fmt.Errorf("unknown agent %q", agent)
+			}
+			minGames, err := intParam(r, "min_games", defaultLeaderboardMinGames, 1, 1000)
+			if err != nil {
+				return "", nil, err
+			}
+			return `
+                SELECT p.user_id, ` + winRateSQL + ` as value, COUNT(*) as games
+                FROM match_player_stats s
+                JOIN match_archive_players p ON p.match_id = s.match_id AND p.user_id = s.user_id
+                WHERE s.agent = $1
+                GROUP BY p.user_id
+                HAVING COUNT(*) >= $2
+            `, []interface{}{agent, minGames}, nil
+		},
+	},
+	{
+		Name:        "most-improved",
+		Description: "Rating gained over the last days days, counting decay and adjustments but not the season reset",
+		Metric:      "rating_gain",
+		Params:      []string{"days"},
+		query: func(lh *LeaderboardHandler, r *http.Request) (string, []interface{}, error) {
+			days, err := intParam(r, "days", defaultImprovedDays, 1, 365)
+			if err != nil {
+				return "", nil, err
+			}
+			return `
+                SELECT user_id, SUM(delta)::float8 as value, COUNT(match_id) as games
+                FROM rating_history
+                WHERE created_at >= NOW() - make_interval(days => $1::int) AND reason <> $2
+                GROUP BY user_id
+                HAVING SUM(delta) > 0
+            `, []interface{}{days, models.RatingChangeSeasonReset}, nil
+		},
+	},
+	{
+		Name:        "win-streak",
+		Description: "Wins in a row up to the player's latest match",
+		Metric:      "win_streak",
+		query: func(lh *LeaderboardHandler, r *http.Request) (string, []interface{}, error) {
+			// A streak is every match newer than the player's latest non-win
+			return `
+                SELECT user_id, COUNT(*)::float8 as value, COUNT(*) as games
+                FROM (
+                    SELECT p.user_id,
+                        COUNT(*) FILTER (WHERE p.outcome <> 'win') OVER (
+                            PARTITION BY p.user_id ORDER BY a.completed_at DESC
+                            ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+                        ) as non_wins
+                    FROM match_archive_players p
+                    JOIN match_archive a ON a.match_id = p.match_id
+                ) history
+                WHERE non_wins = 0
+                GROUP BY user_id
+            `, nil, nil
+		},
+	},
+	{
+		Name:        "friends",
+		Description: "The caller and their friends, ranked or not",
+		Metric:      "rating",
+		query: func(lh *LeaderboardHandler, r *http.Request) (string, []interface{}, error) {
+			userID := r.Header.Get("X-User-ID")
+			if userID == "" {
+				return "", nil, fmt.Errorf("X-User-ID header is required")
+			}
+			return `
+                SELECT id as user_id, elo::float8 as value, COALESCE(games_played, 0) as games
+                FROM users
+                WHERE id::text = $1 OR id IN (SELECT friend_id FROM friends WHERE user_id::text = $1)
+            `, []interface{}{userID}, nil
+		},
+	},
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// intParam reads an optional integer query parameter between min and max
+func intParam(r *http.Request, name string, fallback, min, max int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < min || parsed > max {
+		return 0, fmt.Errorf("%s must be between %d and %d", name, min, max)
+	}
+	return parsed, nil
+}
+
+// ListLeaderboards describes the named leaderboards and their parameters
+func (lh *LeaderboardHandler) ListLeaderboards(w http.ResponseWriter, r *http.Request) {
+	utils.SuccessResponse(w, map[string]interface{}{
+		"leaderboards": namedLeaderboards,
+	})
+}
+
+// GetNamedLeaderboard returns a page of a named leaderboard. Every entry has
+// the usual player stats plus the metric the leaderboard ranks by; ties go
+// to the player with more games.
+func (lh *LeaderboardHandler) GetNamedLeaderboard(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var definition *namedLeaderboard
+	for i := range namedLeaderboards {
+		if namedLeaderboards[i].Name == name {
+			definition = &namedLeaderboards[i]
+		}
+	}
+	if definition == nil {
+		utils.ErrorResponse(w, fmt.Sprintf("unknown leaderboard %q", name), http.StatusNotFound)
+		return
+	}
+
+	metricQuery, args, err := definition.query(lh, r)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, offset := leaderboardPage(r)
+
+	var total int
+	err = database.DB.QueryRow(`WITH metric AS (`+metricQuery+`) SELECT COUNT(*) FROM metric`, args...).Scan(&total)
+	if err != nil {
+		lh.logger.ErrorContext(r.Context(), "could not count leaderboard", "leaderboard", name, "error", err)
+		utils.ErrorResponse(w, "Failed to load leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	query := `
+        WITH metric AS (` + metricQuery + `)
+        SELECT 
+            u.id, u.username, u.email, u.elo, u.wins, u.losses,
+            COALESCE(u.rating_deviation, 0), COALESCE(u.volatility, 0), COALESCE(u.games_played, 0), u.last_match_at,
+            u.created_at, u.updated_at,
+            CASE 
+                WHEN (u.wins + u.losses) > 0 THEN ROUND((u.wins::float / (u.wins + u.losses)::float) * 100, 2)
+                ELSE 0 
+            END as win_rate,
+            (u.wins + u.losses) as games_total,
+            ranked.rank,
+            u.elo - 2 * COALESCE(u.rating_deviation, 0) as conservative_rating,
+            COALESCE(u.rank_tier, -1),
+            ranked.value, ranked.games
+        FROM (
+            SELECT user_id, value, games,
+                ROW_NUMBER() OVER (ORDER BY value DESC, games DESC, user_id) as rank
+            FROM metric
+        ) ranked
+        JOIN users u ON u.id = ranked.user_id
+        ORDER BY ranked.rank
+        LIMIT $` + strconv.Itoa(len(args)+1) + ` OFFSET $` + strconv.Itoa(len(args)+2)
+
+	rows, err := database.DB.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		lh.logger.ErrorContext(r.Context(), "could not load leaderboard", "leaderboard", name, "error", err)
+		utils.ErrorResponse(w, "Failed to load leaderboard", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	leaderboard := []models.UserStats{}
+	for rows.Next() {
+		metric := &models.LeaderboardMetric{Name: definition.Metric}
+		user, err := lh.scanUserStats(rows, &metric.Value, &metric.Games)
+		if err != nil {
+			utils.ErrorResponse(w, "Failed to scan leaderboard data", http.StatusInternalServerError)
+			return
+		}
+		user.Metric = metric
+		leaderboard = append(leaderboard, user)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ErrorResponse(w, "Failed to load leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	filters := make(map[string]string)
+	for _, param := range definition.Params {
+		if value := r.URL.Query().Get(param); value != "" {
+			filters[param] = value
+		}
+	}
+
+	response := map[string]interface{}{
+		"name":        definition.Name,
+		"metric":      definition.Metric,
+		"filters":     filters,
+		"leaderboard": leaderboard,
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+	}
+
+	utils.SuccessResponse(w, response)
+}
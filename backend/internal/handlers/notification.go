@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"valorant-mobile-web/backend/internal/services"
+	"valorant-mobile-web/backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type NotificationHandler struct {
+	notificationService *services.NotificationService
+}
+
+// NewNotificationHandlerWithService creates a NotificationHandler with a shared service instance
+func NewNotificationHandlerWithService(notificationService *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// GetUserNotifications returns a player's most recent notifications
+func (nh *NotificationHandler) GetUserNotifications(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 100 {
+			limit = parsedLimit
+		}
+	}
+
+	notifications, err := nh.notificationService.GetUserNotifications(userID, limit)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"user_id":       userID,
+		"notifications": notifications,
+	}
+	utils.SuccessResponse(w, response)
+}
@@ -2,22 +2,32 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math/rand"
 	"net/http"
 	"strconv"
 	"time"
+	"valorant-mobile-web/backend/internal/logging"
+	"valorant-mobile-web/backend/internal/models"
 	"valorant-mobile-web/backend/internal/services"
 	"valorant-mobile-web/backend/pkg/utils"
 )
 
 type QueueHandler struct {
-	queueService *services.QueueService
+	queueService  *services.QueueService
+	ratingService *services.RatingService
+	tierService   *services.TierService
+	riotService   *services.RiotAccountService
+	logger        *slog.Logger
 }
 
 type JoinQueueRequest struct {
-	Username string `json:"username"`
-	ELO      int    `json:"elo"`
+	Username   string `json:"username"`
+	ELO        int    `json:"elo"`
+	PartyID    string `json:"party_id"`
+	Substitute bool   `json:"substitute"` // Only wait to replace players who left a match
 }
 
 // Remove default constructor to enforce singleton usage
@@ -31,6 +41,20 @@ type JoinQueueRequest struct {
 func NewQueueHandlerWithService(queueService *services.QueueService) *QueueHandler {
 	return &QueueHandler{
 		queueService: queueService,
+		logger:       logging.Component("queue"),
+	}
+}
+
+// NewQueueHandlerWithServices creates a QueueHandler that also looks up
+// whether joining players are still in placement matches, their rank tier
+// and their verified Riot ID
+func NewQueueHandlerWithServices(queueService *services.QueueService, ratingService *services.RatingService, tierService *services.TierService, riotService *services.RiotAccountService, logger *slog.Logger) *QueueHandler {
+	return &QueueHandler{
+		queueService:  queueService,
+		ratingService: ratingService,
+		tierService:   tierService,
+		riotService:   riotService,
+		logger:        logger,
 	}
 }
 
@@ -40,27 +64,21 @@ func generateUserID() string {
 }
 
 func (qh *QueueHandler) JoinQueue(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("=== JOIN QUEUE REQUEST ===\n")
-	fmt.Printf("Method: %s\n", r.Method)
-	fmt.Printf("URL: %s\n", r.URL.String())
-	fmt.Printf("Headers: %v\n", r.Header)
+	qh.logger.DebugContext(r.Context(), "join queue request", "headers", logging.Headers(r.Header))
 
 	userID := r.Header.Get("X-User-ID")
 	username := r.Header.Get("X-Username")
 	eloStr := r.Header.Get("X-User-ELO")
 
-	fmt.Printf("Received userID: '%s'\n", userID)
-	fmt.Printf("Received username: '%s'\n", username)
-	fmt.Printf("Received ELO: '%s'\n", eloStr)
-
 	if userID == "" || userID == "temp-user-id" {
 		// Generate a unique user ID for development
 		userID = generateUserID()
-		fmt.Printf("Generated new userID: %s\n", userID)
+		qh.logger.DebugContext(r.Context(), "generated user ID", "user_id", userID)
 	}
 
 	// Parse request body for additional user data
 	var reqBody JoinQueueRequest
+	partyID := ""
 	if r.Body != nil {
 		if err := json.NewDecoder(r.Body).Decode(&reqBody); err == nil {
 			if reqBody.Username != "" {
@@ -69,6 +87,7 @@ func (qh *QueueHandler) JoinQueue(w http.ResponseWriter, r *http.Request) {
 			if reqBody.ELO > 0 {
 				eloStr = strconv.Itoa(reqBody.ELO)
 			}
+			partyID = reqBody.PartyID
 		}
 	}
 
@@ -84,23 +103,70 @@ func (qh *QueueHandler) JoinQueue(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	fmt.Printf("Final userID: %s, username: %s, ELO: %d\n", userID, username, elo)
+	provisional := false
+	if qh.ratingService != nil {
+		if isProvisional, err := qh.ratingService.IsProvisional(userID); err == nil {
+			provisional = isProvisional
+		} else {
+			qh.logger.WarnContext(r.Context(), "could not check placement status", "user_id", userID, "error", err)
+		}
+	}
+
+	tier := models.PlayerTier{Tier: models.TierUnranked, Label: models.TierUnranked}
+	if qh.tierService != nil {
+		playerTier, err := qh.tierService.GetPlayerTier(userID, provisional)
+		if err != nil {
+			qh.logger.WarnContext(r.Context(), "could not load tier", "user_id", userID, "error", err)
+		}
+		tier = playerTier
+	}
+
+	riotID := ""
+	if qh.riotService != nil {
+		if linked, err := qh.riotService.VerifiedRiotID(userID); err == nil {
+			riotID = linked
+		} else {
+			qh.logger.WarnContext(r.Context(), "could not load Riot ID", "user_id", userID, "error", err)
+		}
+	}
 
-	err := qh.queueService.JoinQueue(userID, username, elo)
+	entry := models.QueueEntry{
+		UserID:      userID,
+		Username:    username,
+		ELO:         elo,
+		PartyID:     partyID,
+		Provisional: provisional,
+		Tier:        tier,
+		RiotID:      riotID,
+	}
+	var err error
+	if reqBody.Substitute {
+		err = qh.queueService.JoinSubstituteQueue(entry)
+	} else {
+		err = qh.queueService.JoinQueueEntry(entry)
+	}
+	if errors.Is(err, services.ErrQueueClosed) {
+		// The server is shutting down, the client should retry against the next one
+		w.Header().Set("Retry-After", "5")
+		utils.ErrorResponse(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
-		fmt.Printf("ERROR joining queue: %v\n", err)
-		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		qh.logger.InfoContext(r.Context(), "could not join queue", "user_id", userID, "error", err)
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
 		return
 	}
 
-	fmt.Printf("SUCCESS: User %s (%s) joined queue\n", username, userID)
-
 	response := map[string]interface{}{
-		"success":  true,
-		"message":  "Successfully joined queue",
-		"userID":   userID,
-		"username": username,
-		"elo":      elo,
+		"success":     true,
+		"message":     "Successfully joined queue",
+		"userID":      userID,
+		"username":    username,
+		"elo":         elo,
+		"party_id":    partyID,
+		"provisional": provisional,
+		"tier":        tier,
+		"substitute":  reqBody.Substitute,
 	}
 
 	utils.SuccessResponse(w, response)
@@ -113,17 +179,13 @@ func (qh *QueueHandler) LeaveQueue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fmt.Printf("LEAVE QUEUE REQUEST: userID=%s\n", userID)
-
 	err := qh.queueService.LeaveQueue(userID)
 	if err != nil {
-		fmt.Printf("ERROR leaving queue: %v\n", err)
-		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		qh.logger.InfoContext(r.Context(), "could not leave queue", "error", err)
+		serviceErrorResponse(w, err.Error(), err, http.StatusBadRequest)
 		return
 	}
 
-	fmt.Printf("SUCCESS: User %s left queue\n", userID)
-
 	response := map[string]interface{}{
 		"success": true,
 		"message": "Successfully left queue",
@@ -142,3 +204,15 @@ func (qh *QueueHandler) GetQueueStatus(w http.ResponseWriter, r *http.Request) {
 
 	utils.SuccessResponse(w, status)
 }
+
+// serviceErrorResponse reports an error from the queue or match room
+// services with status, unless their shared state was unavailable: the
+// request did nothing and should be retried, so that is a 503.
+func serviceErrorResponse(w http.ResponseWriter, message string, err error, status int) {
+	if errors.Is(err, services.ErrSharedStateUnavailable) {
+		w.Header().Set("Retry-After", "1")
+		utils.ErrorResponse(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	utils.ErrorResponse(w, message, status)
+}
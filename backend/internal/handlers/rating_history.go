@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"valorant-mobile-web/backend/internal/services"
+	"valorant-mobile-web/backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type RatingHistoryHandler struct {
+	historyService   *services.RatingHistoryService
+	leaderboardIndex *services.LeaderboardIndex
+	adminToken       string
+	logger           *slog.Logger
+}
+
+// NewRatingHistoryHandlerWithServices creates a RatingHistoryHandler. Rating
+// adjustments require the X-Admin-Token header to match adminToken and are
+// disabled when it is empty.
+func NewRatingHistoryHandlerWithServices(historyService *services.RatingHistoryService, leaderboardIndex *services.LeaderboardIndex, adminToken string, logger *slog.Logger) *RatingHistoryHandler {
+	return &RatingHistoryHandler{
+		historyService:   historyService,
+		leaderboardIndex: leaderboardIndex,
+		adminToken:       adminToken,
+		logger:           logger,
+	}
+}
+
+// GetUserRatingHistory returns a player's rating changes, oldest first.
+// from and to (RFC 3339) limit the time range.
+func (hh *RatingHistoryHandler) GetUserRatingHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var from, to time.Time
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			utils.ErrorResponse(w, "from must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			utils.ErrorResponse(w, "to must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		utils.ErrorResponse(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	limit := 200
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 1000 {
+			limit = parsedLimit
+		}
+	}
+
+	history, err := hh.historyService.GetUserHistory(userID, from, to, limit)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"user_id": userID,
+		"history": history,
+	}
+	utils.SuccessResponse(w, response)
+}
+
+type AdjustRatingRequest struct {
+	Delta int    `json:"delta"`
+	Note  string `json:"note"`
+}
+
+// AdjustRating changes a player's rating by hand, e.g. to undo a rating
+// gained from a match later found to be rigged
+func (hh *RatingHistoryHandler) AdjustRating(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("X-Admin-Token")
+	if hh.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(hh.adminToken)) != 1 {
+		utils.ErrorResponse(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	var req AdjustRatingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Delta == 0 || req.Note == "" {
+		utils.ErrorResponse(w, "delta and note are required", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := hh.historyService.AdjustRating(userID, req.Delta, req.Note)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if hh.leaderboardIndex != nil {
+		if err := hh.leaderboardIndex.Refresh([]string{userID}); err != nil {
+			hh.logger.ErrorContext(r.Context(), "could not update leaderboard", "rated_user_id", userID, "error", err)
+		}
+	}
+
+	utils.SuccessResponse(w, entry)
+}
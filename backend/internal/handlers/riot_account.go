@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"valorant-mobile-web/backend/internal/services"
+	"valorant-mobile-web/backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type RiotAccountHandler struct {
+	riotAccountService *services.RiotAccountService
+}
+
+// NewRiotAccountHandlerWithService creates a RiotAccountHandler with a shared service instance
+func NewRiotAccountHandlerWithService(riotAccountService *services.RiotAccountService) *RiotAccountHandler {
+	return &RiotAccountHandler{
+		riotAccountService: riotAccountService,
+	}
+}
+
+type LinkRiotIDRequest struct {
+	RiotID string `json:"riot_id"` // "name#tag"
+	Region string `json:"region"`
+}
+
+// LinkRiotID starts linking a Riot ID and returns the code the player must
+// set on their Riot profile before calling VerifyRiotID
+func (rh *RiotAccountHandler) LinkRiotID(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "Unauthorized - User ID required", http.StatusUnauthorized)
+		return
+	}
+
+	var req LinkRiotIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.RiotID == "" || req.Region == "" {
+		utils.ErrorResponse(w, "riot_id and region are required", http.StatusBadRequest)
+		return
+	}
+
+	link, err := rh.riotAccountService.StartLink(userID, req.RiotID, req.Region)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"link":         link,
+		"instructions": "Set " + link.VerificationCode + " as your Riot profile verification field, then verify.",
+	}
+	utils.SuccessResponse(w, response)
+}
+
+// VerifyRiotID checks the verification code on the Riot profile and completes the link
+func (rh *RiotAccountHandler) VerifyRiotID(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "Unauthorized - User ID required", http.StatusUnauthorized)
+		return
+	}
+
+	link, err := rh.riotAccountService.Verify(userID)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, link)
+}
+
+// UnlinkRiotID removes the caller's Riot ID
+func (rh *RiotAccountHandler) UnlinkRiotID(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "Unauthorized - User ID required", http.StatusUnauthorized)
+		return
+	}
+
+	if err := rh.riotAccountService.Unlink(userID); err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.MessageResponse(w, "Riot ID unlinked")
+}
+
+// GetUserRiotID returns a user's Riot ID. Other players only see verified
+// links, the owner also sees a pending link and its code.
+func (rh *RiotAccountHandler) GetUserRiotID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	link, err := rh.riotAccountService.GetLink(userID)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if r.Header.Get("X-User-ID") != userID {
+		if !link.Verified {
+			utils.ErrorResponse(w, "no riot id linked", http.StatusNotFound)
+			return
+		}
+		link.VerificationCode = ""
+	}
+
+	utils.SuccessResponse(w, link)
+}
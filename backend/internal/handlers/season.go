@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"valorant-mobile-web/backend/internal/services"
+	"valorant-mobile-web/backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type SeasonHandler struct {
+	seasonService *services.SeasonService
+}
+
+// NewSeasonHandlerWithService creates a SeasonHandler with a shared service instance
+func NewSeasonHandlerWithService(seasonService *services.SeasonService) *SeasonHandler {
+	return &SeasonHandler{
+		seasonService: seasonService,
+	}
+}
+
+// ListSeasons returns every season, newest first
+func (sh *SeasonHandler) ListSeasons(w http.ResponseWriter, r *http.Request) {
+	seasons, err := sh.seasonService.ListSeasons()
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"seasons": seasons,
+	}
+	utils.SuccessResponse(w, response)
+}
+
+// GetCurrentSeason returns the active season
+func (sh *SeasonHandler) GetCurrentSeason(w http.ResponseWriter, r *http.Request) {
+	season, err := sh.seasonService.CurrentSeason()
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"season": season,
+	}
+	utils.SuccessResponse(w, response)
+}
+
+// GetUserSeasons returns a player's record and final rank in every season they played
+func (sh *SeasonHandler) GetUserSeasons(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	seasons, err := sh.seasonService.GetUserSeasons(userID)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"user_id": userID,
+		"seasons": seasons,
+	}
+	utils.SuccessResponse(w, response)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"valorant-mobile-web/backend/internal/models"
+	"valorant-mobile-web/backend/internal/services"
+	"valorant-mobile-web/backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type StatsHandler struct {
+	statsService *services.MatchStatsService
+}
+
+// NewStatsHandlerWithService creates a StatsHandler with a shared service instance
+func NewStatsHandlerWithService(statsService *services.MatchStatsService) *StatsHandler {
+	return &StatsHandler{
+		statsService: statsService,
+	}
+}
+
+type SubmitStatsRequest struct {
+	Stats []models.PlayerMatchStats `json:"stats"`
+}
+
+// SubmitMatchStats stores scoreboard lines for a completed match. A player
+// submits their own line, a captain may submit the lines of their team. The
+// lines count towards stats only, the match's ratings don't change.
+func (sh *StatsHandler) SubmitMatchStats(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		utils.ErrorResponse(w, "Unauthorized - User ID required", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	matchID := vars["id"]
+
+	var req SubmitStatsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := sh.statsService.SubmitStats(matchID, userID, req.Stats)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"match_id": matchID,
+		"stats":    stats,
+	}
+	utils.SuccessResponse(w, response)
+}
+
+// GetUserStats returns a player's aggregated stats over their career or,
+// with ?season=<id>, a single season
+func (sh *StatsHandler) GetUserStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	if userID == "" {
+		utils.ErrorResponse(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	seasonID := 0
+	if seasonStr := r.URL.Query().Get("season"); seasonStr != "" {
+		parsed, err := strconv.Atoi(seasonStr)
+		if err != nil || parsed <= 0 {
+			utils.ErrorResponse(w, "season must be a season ID", http.StatusBadRequest)
+			return
+		}
+		seasonID = parsed
+	}
+
+	career, err := sh.statsService.GetCareerStats(userID, seasonID)
+	if err != nil {
+		utils.ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, career)
+}
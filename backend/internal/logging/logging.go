@@ -0,0 +1,216 @@
+// Package logging sets up the structured logger of the backend: leveled
+// log/slog records as text or JSON, a level per component, fields carried
+// by request contexts and redaction of secrets and personal data.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Options configures the logger
+type Options struct {
+	Level      slog.Level            // Level of components without their own
+	JSON       bool                  // One JSON object per line instead of key=value text
+	Components map[string]slog.Level // Per-component levels, e.g. "queue" at debug
+}
+
+// ParseOptions parses a level (debug, info, warn or error), a format (text
+// or json) and a comma separated list of component=level
+func ParseOptions(level, format, componentLevels string) (Options, error) {
+	options := Options{Level: slog.LevelInfo}
+
+	if level != "" {
+		if err := options.Level.UnmarshalText([]byte(level)); err != nil {
+			return options, fmt.Errorf("invalid log level %q, must be debug, info, warn or error", level)
+		}
+	}
+
+	switch strings.ToLower(format) {
+	case "", "text":
+	case "json":
+		options.JSON = true
+	default:
+		return options, fmt.Errorf("invalid log format %q, must be text or json", format)
+	}
+
+	components, err := ParseComponentLevels(componentLevels)
+	if err != nil {
+		return options, err
+	}
+	options.Components = components
+	return options, nil
+}
+
+// ParseComponentLevels parses "queue=debug,match_room=warn"
+func ParseComponentLevels(value string) (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		component, levelName, ok := strings.Cut(pair, "=")
+		var level slog.Level
+		if !ok || component == "" || level.UnmarshalText([]byte(levelName)) != nil {
+			return nil, fmt.Errorf("invalid log level %q, must look like component=level", pair)
+		}
+		levels[strings.TrimSpace(component)] = level
+	}
+	return levels, nil
+}
+
+// New returns a logger writing to w. Loggers for a component are derived
+// from it with Component.
+func New(w io.Writer, options Options) *slog.Logger {
+	handlerOptions := &slog.HandlerOptions{
+		// Levels are checked per component by handler
+		Level:       slog.Level(-8),
+		ReplaceAttr: redact,
+	}
+
+	var inner slog.Handler
+	if options.JSON {
+		inner = slog.NewJSONHandler(w, handlerOptions)
+	} else {
+		inner = slog.NewTextHandler(w, handlerOptions)
+	}
+
+	components := make(map[string]slog.Level, len(options.Components))
+	for component, level := range options.Components {
+		components[component] = level
+	}
+	return slog.New(&handler{
+		inner:      inner,
+		level:      options.Level,
+		components: components,
+	})
+}
+
+// Component returns the default logger for one part of the backend, whose
+// level can be set on its own with LOG_LEVELS
+func Component(name string) *slog.Logger {
+	return slog.Default().With("component", name)
+}
+
+// handler applies component levels and adds the fields of the record's context
+type handler struct {
+	inner      slog.Handler
+	level      slog.Level
+	components map[string]slog.Level
+	component  string
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if componentLevel, ok := h.components[h.component]; ok {
+		return level >= componentLevel
+	}
+	return level >= h.level
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs := contextAttrs(ctx); len(attrs) > 0 {
+		record = record.Clone()
+		record.AddAttrs(attrs...)
+	}
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := *h
+	derived.inner = h.inner.WithAttrs(attrs)
+	for _, attr := range attrs {
+		if attr.Key == "component" {
+			derived.component = attr.Value.String()
+		}
+	}
+	return &derived
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	derived := *h
+	derived.inner = h.inner.WithGroup(name)
+	return &derived
+}
+
+type contextKey struct{}
+
+// With returns a copy of ctx whose log records carry the given key-value
+// pairs, e.g. logging.With(ctx, "match_id", matchID). A key the context
+// already carries is replaced.
+func With(ctx context.Context, args ...any) context.Context {
+	added := slog.Group("", args...).Value.Group()
+	var attrs []slog.Attr
+	for _, attr := range contextAttrs(ctx) {
+		if !hasKey(added, attr.Key) {
+			attrs = append(attrs, attr)
+		}
+	}
+	return context.WithValue(ctx, contextKey{}, append(attrs, added...))
+}
+
+func hasKey(attrs []slog.Attr, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func contextAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	// Copied so callers appending to the result never share a backing array
+	return append([]slog.Attr(nil), attrs...)
+}
+
+// secretKeys are attribute keys whose values are never written
+var secretKeys = map[string]bool{
+	"password":          true,
+	"token":             true,
+	"authorization":     true,
+	"cookie":            true,
+	"set-cookie":        true,
+	"x-admin-token":     true,
+	"admin_token":       true,
+	"party_code":        true,
+	"verification_code": true,
+	"database_url":      true,
+}
+
+// redact hides secrets and masks email addresses, at any nesting level
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	switch {
+	case secretKeys[key]:
+		return slog.String(attr.Key, "[REDACTED]")
+	case key == "email" || key == "x-user-email":
+		return slog.String(attr.Key, MaskEmail(attr.Value.String()))
+	}
+	return attr
+}
+
+// MaskEmail keeps the first letter and the domain of an email address
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return "[REDACTED]"
+	}
+	return local[:1] + "***@" + domain
+}
+
+// Headers logs request headers as a group, with secrets redacted
+func Headers(header http.Header) slog.Value {
+	attrs := make([]slog.Attr, 0, len(header))
+	for name, values := range header {
+		attrs = append(attrs, slog.String(strings.ToLower(name), strings.Join(values, ",")))
+	}
+	return slog.GroupValue(attrs...)
+}
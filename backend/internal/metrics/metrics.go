@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"database/sql"
+)
+
+// Matchmaking and API metrics. The acceptance rate of ready checks is
+//
+//	sum(rate(valorant_ready_check_responses_total{response="accepted"}[1h]))
+//	  / sum(rate(valorant_ready_check_responses_total[1h]))
+var (
+	QueueWait = NewHistogram("valorant_queue_wait_seconds",
+		"Time players waited in a queue, by how they left it.",
+		[]float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+		"queue", "outcome")
+
+	Matches = NewCounter("valorant_matches_total",
+		"Matches created, cancelled and completed, by reason.",
+		"event", "reason")
+
+	ReadyChecks = NewCounter("valorant_ready_check_responses_total",
+		"Player responses to match ready checks.",
+		"response")
+
+	CaptainVoteDuration = NewHistogram("valorant_captain_vote_duration_seconds",
+		"Time from the start of a captain vote until it closed, by whether every player voted.",
+		[]float64{5, 10, 15, 20, 30, 45, 60, 90, 120},
+		"outcome")
+
+	RatingChange = NewHistogram("valorant_rating_change",
+		"Rating points gained or lost per player and match.",
+		[]float64{-50, -40, -30, -20, -10, -5, 0, 5, 10, 20, 30, 40, 50},
+		"engine")
+
+	HTTPRequests = NewCounter("valorant_http_requests_total",
+		"HTTP requests served, by route template and status code.",
+		"method", "route", "status")
+
+	HTTPDuration = NewHistogram("valorant_http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by route template.",
+		[]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		"method", "route")
+)
+
+// Queues reported in queue metrics
+const (
+	QueueMain       = "main"
+	QueueSubstitute = "substitute"
+)
+
+// RegisterDBStats exports the connection pool statistics of db
+func RegisterDBStats(db *sql.DB) {
+	gauge := func(name, help string, value func(sql.DBStats) float64) {
+		NewGaugeFunc(name, help, func() []Sample {
+			return []Sample{{Value: value(db.Stats())}}
+		})
+	}
+	counter := func(name, help string, value func(sql.DBStats) float64) {
+		NewCounterFunc(name, help, func() []Sample {
+			return []Sample{{Value: value(db.Stats())}}
+		})
+	}
+
+	gauge("valorant_db_max_open_connections", "Maximum number of open database connections.",
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })
+	gauge("valorant_db_open_connections", "Open database connections.",
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) })
+	gauge("valorant_db_in_use_connections", "Database connections in use.",
+		func(s sql.DBStats) float64 { return float64(s.InUse) })
+	gauge("valorant_db_idle_connections", "Idle database connections.",
+		func(s sql.DBStats) float64 { return float64(s.Idle) })
+	counter("valorant_db_wait_count_total", "Times a query waited for a free database connection.",
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) })
+	counter("valorant_db_wait_duration_seconds_total", "Time spent waiting for free database connections.",
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })
+	counter("valorant_db_closed_max_idle_total", "Connections closed because of the idle connection limit.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) })
+	counter("valorant_db_closed_max_lifetime_total", "Connections closed because they reached their maximum lifetime.",
+		func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) })
+}
@@ -0,0 +1,294 @@
+// Package metrics exposes counters, gauges and histograms in the Prometheus
+// text exposition format, so the backend can be scraped without pulling in
+// a client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds the metrics served by Handler
+type Registry struct {
+	metrics map[string]metric
+	mutex   sync.RWMutex
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// Default is the registry the metrics of this package are registered with
+var Default = NewRegistry()
+
+func (r *Registry) register(name string, m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, exists := r.metrics[name]; exists {
+		panic(fmt.Sprintf("metric %s registered twice", name))
+	}
+	r.metrics[name] = m
+}
+
+// replace registers m, replacing any metric registered under name before
+func (r *Registry) replace(name string, m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.metrics[name] = m
+}
+
+// write writes every metric, sorted by name
+func (r *Registry) write(w *bufio.Writer) {
+	r.mutex.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics := make([]metric, len(names))
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.mutex.RUnlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the metrics of the registry to Prometheus
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		buffered := bufio.NewWriter(w)
+		r.write(buffered)
+		buffered.Flush()
+	})
+}
+
+// Handler serves the Default registry
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// series is the values of one metric for one combination of label values
+type series struct {
+	labelValues []string
+	value       float64
+	buckets     []uint64 // Histograms only, not cumulative
+	count       uint64
+}
+
+// vec keeps the series of a metric by label values
+type vec struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	series     map[string]*series
+	mutex      sync.Mutex
+}
+
+func newVec(name, help, kind string, labelNames []string) vec {
+	return vec{name: name, help: help, kind: kind, labelNames: labelNames, series: make(map[string]*series)}
+}
+
+// get must be called with v.mutex held
+func (v *vec) get(labelValues []string) *series {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s takes %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted must be called with v.mutex held
+func (v *vec) sorted() []*series {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sorted := make([]*series, len(keys))
+	for i, key := range keys {
+		sorted[i] = v.series[key]
+	}
+	return sorted
+}
+
+func (v *vec) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.kind)
+}
+
+// Counter is a value that only goes up, e.g. matches created
+type Counter struct {
+	vec
+}
+
+// NewCounter registers a counter with the Default registry
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{newVec(name, help, "counter", labelNames)}
+	Default.register(name, c)
+	return c
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.get(labelValues).value += delta
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.writeHeader(w)
+	for _, s := range c.sorted() {
+		writeSample(w, c.name, c.labelNames, s.labelValues, "", "", s.value)
+	}
+}
+
+// Histogram counts observations, e.g. queue wait times, in buckets
+type Histogram struct {
+	vec
+	upperBounds []float64
+}
+
+// NewHistogram registers a histogram with the Default registry. upperBounds
+// must be sorted; the +Inf bucket is added.
+func NewHistogram(name, help string, upperBounds []float64, labelNames ...string) *Histogram {
+	h := &Histogram{vec: newVec(name, help, "histogram", labelNames), upperBounds: upperBounds}
+	Default.register(name, h)
+	return h
+}
+
+// Observe records one value for the series with the given label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s := h.get(labelValues)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(h.upperBounds))
+	}
+	for i, bound := range h.upperBounds {
+		if value <= bound {
+			s.buckets[i]++
+			break
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.writeHeader(w)
+	for _, s := range h.sorted() {
+		var cumulative uint64
+		for i, bound := range h.upperBounds {
+			cumulative += s.buckets[i]
+			writeSample(w, h.name+"_bucket", h.labelNames, s.labelValues, "le", formatFloat(bound), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labelNames, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labelNames, s.labelValues, "", "", s.value)
+		writeSample(w, h.name+"_count", h.labelNames, s.labelValues, "", "", float64(s.count))
+	}
+}
+
+// Sample is one value reported by a gauge or counter function
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// funcMetric reads its values when scraped, e.g. the current queue depth
+type funcMetric struct {
+	vec
+	collect func() []Sample
+}
+
+// NewGaugeFunc registers a gauge whose values are read by collect on every
+// scrape. Registering the name again replaces collect, e.g. when the server
+// reading it is built again.
+func NewGaugeFunc(name, help string, collect func() []Sample, labelNames ...string) {
+	Default.replace(name, &funcMetric{newVec(name, help, "gauge", labelNames), collect})
+}
+
+// NewCounterFunc registers a counter kept elsewhere, e.g. by database/sql.
+// Like NewGaugeFunc, registering the name again replaces collect.
+func NewCounterFunc(name, help string, collect func() []Sample, labelNames ...string) {
+	Default.replace(name, &funcMetric{newVec(name, help, "counter", labelNames), collect})
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	f.writeHeader(w)
+	for _, sample := range f.collect() {
+		writeSample(w, f.name, f.labelNames, sample.LabelValues, "", "", sample.Value)
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, extraName, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labelNames) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, labelName := range labelNames {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, labelName, escapeLabel(labelValues[i]))
+		}
+		if extraName != "" {
+			if len(labelNames) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// scrape returns what Prometheus gets from r
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	r.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if got := recorder.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("content type = %q", got)
+	}
+	return recorder.Body.String()
+}
+
+func TestCounterExposition(t *testing.T) {
+	r := NewRegistry()
+	c := &Counter{newVec("test_matches_total", "Matches.\nBy status.", "counter", []string{"status", "source"})}
+	r.register(c.name, c)
+
+	c.Inc("completed", "reported")
+	c.Add(2, "completed", "reported")
+	c.Inc("cancelled", `lobby "no\show"`)
+
+	want := `# HELP test_matches_total Matches.\nBy status.
+# TYPE test_matches_total counter
+test_matches_total{status="cancelled",source="lobby \"no\\show\""} 1
+test_matches_total{status="completed",source="reported"} 3
+`
+	if got := scrape(t, r); got != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHistogramExposition(t *testing.T) {
+	r := NewRegistry()
+	h := &Histogram{vec: newVec("test_wait_seconds", "Wait.", "histogram", nil), upperBounds: []float64{1, 2.5}}
+	r.register(h.name, h)
+
+	for _, value := range []float64{0.5, 1, 2, 10} {
+		h.Observe(value)
+	}
+
+	want := `# HELP test_wait_seconds Wait.
+# TYPE test_wait_seconds histogram
+test_wait_seconds_bucket{le="1"} 2
+test_wait_seconds_bucket{le="2.5"} 3
+test_wait_seconds_bucket{le="+Inf"} 4
+test_wait_seconds_sum 13.5
+test_wait_seconds_count 4
+`
+	if got := scrape(t, r); got != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMetricsSortedByName(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"test_b", "test_a"} {
+		depth := float64(len(name))
+		r.replace(name, &funcMetric{newVec(name, "Depth.", "gauge", []string{"queue"}), func() []Sample {
+			return []Sample{{LabelValues: []string{"main"}, Value: depth}}
+		}})
+	}
+
+	got := scrape(t, r)
+	if a, b := strings.Index(got, "test_a"), strings.Index(got, "test_b"); a < 0 || b < 0 || a > b {
+		t.Errorf("metrics not sorted by name:\n%s", got)
+	}
+	if !strings.Contains(got, "# TYPE test_a gauge\ntest_a{queue=\"main\"} 6\n") {
+		t.Errorf("gauge missing:\n%s", got)
+	}
+}
+
+func TestFuncMetricRegisteredAgainIsReplaced(t *testing.T) {
+	name := "test_queue_depth"
+	for _, depth := range []float64{1, 2} {
+		NewGaugeFunc(name, "Depth.", func() []Sample { return []Sample{{Value: depth}} })
+	}
+	defer func() {
+		Default.mutex.Lock()
+		delete(Default.metrics, name)
+		Default.mutex.Unlock()
+	}()
+
+	got := scrape(t, Default)
+	if !strings.Contains(got, "\ntest_queue_depth 2\n") || strings.Contains(got, "\ntest_queue_depth 1\n") {
+		t.Errorf("gauge registered again was not replaced:\n%s", got)
+	}
+}
+
+func TestCounterRegisteredTwicePanics(t *testing.T) {
+	r := NewRegistry()
+	c := &Counter{newVec("test_total", "Total.", "counter", nil)}
+	r.register(c.name, c)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a counter twice did not panic")
+		}
+	}()
+	r.register(c.name, c)
+}
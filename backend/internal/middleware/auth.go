@@ -8,7 +8,8 @@ import (
     "github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware accepts requests carrying a token signed with jwtSecret
+func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
     return func(c *gin.Context) {
         tokenString := c.Request.Header.Get("Authorization")
         if tokenString == "" {
@@ -21,7 +22,7 @@ func AuthMiddleware() gin.HandlerFunc {
 
         claims := &jwt.StandardClaims{}
         token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-            return []byte("your_secret_key"), nil
+            return []byte(jwtSecret), nil
         })
 
         if err != nil || !token.Valid {
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+	"valorant-mobile-web/backend/internal/services"
+	"valorant-mobile-web/backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBody bounds the request body read to fingerprint a request
+const maxIdempotentBody = 1 << 20
+
+// Idempotency makes retries of a request carrying an Idempotency-Key header
+// safe: the first response for a key is saved in store and replayed, with
+// Idempotent-Replayed: true, for every retry within window. Keys belong to
+// the caller in X-User-ID and to one request, reusing a key for another
+// method, path or body is rejected. Server errors aren't saved so the
+// request can be retried. Requests without the header are served as usual.
+func Idempotency(store services.IdempotencyStore, window time.Duration, logger *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				utils.ErrorResponse(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+			if err != nil {
+				utils.ErrorResponse(w, "could not read request body", http.StatusBadRequest)
+				return
+			}
+			if len(body) > maxIdempotentBody {
+				utils.ErrorResponse(w, "request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			hash := sha256.New()
+			io.WriteString(hash, r.Method+" "+r.URL.Path+"\n")
+			hash.Write(body)
+			fingerprint := hex.EncodeToString(hash.Sum(nil))
+			scopedKey := r.Header.Get("X-User-ID") + ":" + key
+
+			ctx := r.Context()
+			saved, err := store.Reserve(ctx, scopedKey, fingerprint, window)
+			switch {
+			case errors.Is(err, services.ErrIdempotencyKeyInUse):
+				w.Header().Set("Retry-After", "1")
+				utils.ErrorResponse(w, err.Error(), http.StatusConflict)
+				return
+			case errors.Is(err, services.ErrIdempotencyKeyMismatch):
+				utils.ErrorResponse(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			case err != nil:
+				// Serving the request unprotected beats failing it
+				logger.ErrorContext(ctx, "could not check idempotency key, serving request", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			case saved != nil:
+				logger.DebugContext(ctx, "replaying response for idempotency key", "status", saved.Status)
+				if saved.ContentType != "" {
+					w.Header().Set("Content-Type", saved.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(saved.Status)
+				w.Write(saved.Body)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status >= 500 {
+				if err := store.Release(ctx, scopedKey); err != nil {
+					logger.ErrorContext(ctx, "could not release idempotency key", "error", err)
+				}
+				return
+			}
+			err = store.Save(ctx, scopedKey, services.IdempotentResponse{
+				Status:      recorder.status,
+				ContentType: w.Header().Get("Content-Type"),
+				Body:        recorder.body.Bytes(),
+			})
+			if err != nil {
+				logger.ErrorContext(ctx, "could not save idempotent response", "error", err)
+			}
+		})
+	}
+}
+
+// responseRecorder keeps a copy of the status code and body written by a
+// handler
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"valorant-mobile-web/backend/internal/logging"
+
+	"github.com/gorilla/mux"
+)
+
+// requestIDPattern accepts request IDs set by a proxy in front of the API
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// RequestLogging gives every request an ID, returned in X-Request-ID, and
+// adds it, the caller's user ID and the match the route is about to every
+// record logged with the request's context. Each request is logged once
+// served, at debug level unless it failed with a server error.
+func RequestLogging(logger *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get("X-Request-ID")
+			if !requestIDPattern.MatchString(requestID) {
+				requestID = newRequestID()
+			}
+			w.Header().Set("X-Request-ID", requestID)
+
+			ctx := logging.With(r.Context(), "request_id", requestID)
+			if userID := r.Header.Get("X-User-ID"); userID != "" {
+				ctx = logging.With(ctx, "user_id", userID)
+			}
+			if matchID := routeMatchID(r); matchID != "" {
+				ctx = logging.With(ctx, "match_id", matchID)
+			}
+			r = r.WithContext(ctx)
+
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			level := slog.LevelDebug
+			if recorder.status >= 500 {
+				level = slog.LevelError
+			}
+			logger.Log(ctx, level, "request served",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", recorder.status,
+				"duration_ms", time.Since(start).Milliseconds(),
+			)
+		})
+	}
+}
+
+// routeMatchID returns the match ID of match room and match routes
+func routeMatchID(r *http.Request) string {
+	vars := mux.Vars(r)
+	if matchID := vars["matchId"]; matchID != "" {
+		return matchID
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/match/") || strings.HasPrefix(r.URL.Path, "/api/matches/") {
+		return vars["id"]
+	}
+	return ""
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"valorant-mobile-web/backend/internal/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// RequestMetrics records the latency and status code of every request by
+// route template, e.g. /api/match-room/{matchId}, so IDs don't multiply
+// the number of series
+func RequestMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		metrics.HTTPRequests.Inc(r.Method, route, strconv.Itoa(recorder.status))
+		metrics.HTTPDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
@@ -1,34 +1,55 @@
 package models
 
 import (
-    "math"
+	"time"
 )
 
+// Elo holds the rating state of a player. Deviation and Volatility are only
+// meaningful for the Glicko-2 engine, the ELO engine leaves them untouched.
 type Elo struct {
-    UserID    string  `json:"user_id"`
-    Rating    float64 `json:"rating"`
-    Wins      int     `json:"wins"`
-    Losses    int     `json:"losses"`
-    LastMatch int64   `json:"last_match"` // Timestamp of the last match
+	UserID      string    `json:"user_id"`
+	Rating      float64   `json:"rating"`
+	Deviation   float64   `json:"deviation"`
+	Volatility  float64   `json:"volatility"`
+	Wins        int       `json:"wins"`
+	Losses      int       `json:"losses"`
+	GamesPlayed int       `json:"games_played"`
+	LastMatch   time.Time `json:"last_match"` // Time of the last rated match, zero if never played
 }
 
-// CalculateNewRating calculates the new ELO rating based on the outcome of a match.
-func (e *Elo) CalculateNewRating(opponentRating float64, outcome float64) float64 {
-    k := 32.0 // K-factor
-    expectedScore := 1 / (1 + math.Pow(10, (opponentRating-e.Rating)/400))
-    newRating := e.Rating + k*(outcome-expectedScore)
-    return newRating
+// ConservativeRating is the rating the player is almost certainly above (rating - 2·RD)
+func (e *Elo) ConservativeRating() float64 {
+	return e.Rating - 2*e.Deviation
 }
 
-// UpdateElo updates the ELO rating after a match.
-func (e *Elo) UpdateElo(won bool, opponentRating float64) {
-    outcome := 0.0
-    if won {
-        outcome = 1.0
-        e.Wins++
-    } else {
-        outcome = 0.0
-        e.Losses++
-    }
-    e.Rating = e.CalculateNewRating(opponentRating, outcome)
-}
\ No newline at end of file
+// IsProvisional reports whether the player is still playing placement matches
+func (e *Elo) IsProvisional(placementMatches int) bool {
+	return e.GamesPlayed < placementMatches
+}
+
+// RatingAudit records the formula and inputs used to rate a match so any
+// rating change can be recomputed later
+type RatingAudit struct {
+	MatchID          string              `json:"match_id" db:"match_id"`
+	Engine           string              `json:"engine" db:"engine"`
+	Mode             string              `json:"mode" db:"mode"`
+	Formula          string              `json:"formula" db:"formula"`
+	Team1Score       float64             `json:"team1_score" db:"team1_score"`
+	Team1Rounds      int                 `json:"team1_rounds" db:"team1_rounds"`
+	Team2Rounds      int                 `json:"team2_rounds" db:"team2_rounds"`
+	MarginMultiplier float64             `json:"margin_multiplier" db:"margin_multiplier"`
+	Players          []RatingAuditPlayer `json:"players" db:"players"`
+	CreatedAt        time.Time           `json:"created_at" db:"created_at"`
+}
+
+// RatingAuditPlayer is one player's rating before and after a match
+type RatingAuditPlayer struct {
+	UserID          string  `json:"user_id"`
+	Team            string  `json:"team"` // "team1" or "team2"
+	RatingBefore    float64 `json:"rating_before"`
+	RatingAfter     float64 `json:"rating_after"`
+	DeviationBefore float64 `json:"deviation_before"`
+	DeviationAfter  float64 `json:"deviation_after"`
+	Abandoned       bool    `json:"abandoned,omitempty"`  // Left the match and was rated as a loss
+	Substitute      bool    `json:"substitute,omitempty"` // Joined as a substitute at reduced stakes
+}
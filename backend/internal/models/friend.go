@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// Friend is a player on someone's friends list. Friends lists are one-way:
+// adding a player does not add you to theirs.
+type Friend struct {
+	UserID   string    `json:"user_id" db:"friend_id"`
+	Username string    `json:"username" db:"username"`
+	ELO      int       `json:"elo" db:"elo"`
+	Since    time.Time `json:"since" db:"created_at"`
+}
@@ -26,15 +26,34 @@ type CaptainSelectionMethod string
 const (
 	CaptainSelectionVoting CaptainSelectionMethod = "voting"
 	CaptainSelectionRandom CaptainSelectionMethod = "random"
+	CaptainSelectionAuto   CaptainSelectionMethod = "auto_balance" // Teams split by ELO, no captains draft
 )
 
+// CaptainVotingRules configures how a captain vote is run
+type CaptainVotingRules struct {
+	DurationSeconds int  `json:"duration_seconds"` // Time players have to vote before missing votes are ignored
+	AllowSelfVote   bool `json:"allow_self_vote"`
+	MaxCandidates   int  `json:"max_candidates"` // Only the top-N ELO players are eligible, 0 means everyone
+}
+
+// DefaultCaptainVotingRules are used when a lobby doesn't provide its own
+var DefaultCaptainVotingRules = CaptainVotingRules{
+	DurationSeconds: 60,
+	AllowSelfVote:   true,
+	MaxCandidates:   0,
+}
+
 type MatchPlayer struct {
-	UserID   string `json:"user_id" db:"user_id"`
-	Username string `json:"username" db:"username"`
-	ELO      int    `json:"elo" db:"elo"`
-	Accepted bool   `json:"accepted" db:"accepted"`
-	Team     string `json:"team,omitempty" db:"team"` // "A" or "B"
-	Role     string `json:"role,omitempty" db:"role"` // "captain" or "player"
+	UserID      string     `json:"user_id" db:"user_id"`
+	Username    string     `json:"username" db:"username"`
+	ELO         int        `json:"elo" db:"elo"`
+	Accepted    bool       `json:"accepted" db:"accepted"`
+	PartyID     string     `json:"party_id,omitempty" db:"party_id"` // Players sharing a party always end up on the same team
+	Provisional bool       `json:"provisional" db:"provisional"`
+	Tier        PlayerTier `json:"tier" db:"-"`
+	RiotID      string     `json:"riot_id,omitempty" db:"-"` // Verified Riot ID, for in-game invites
+	Team        string     `json:"team,omitempty" db:"team"` // "A" or "B"
+	Role        string     `json:"role,omitempty" db:"role"` // "captain" or "player"
 }
 
 type Match struct {
@@ -48,15 +67,67 @@ type Match struct {
 	CaptainSelectionMethod CaptainSelectionMethod `json:"captain_selection_method" db:"captain_selection_method"`
 	CaptainVotes           map[string]string      `json:"captain_votes" db:"captain_votes"`           // userID -> voted_for_userID
 	CaptainCandidates      []string               `json:"captain_candidates" db:"captain_candidates"` // List of captain candidates
+	CaptainVotingRules     CaptainVotingRules     `json:"captain_voting_rules" db:"captain_voting_rules"`
+	CaptainVotingDeadline  time.Time              `json:"captain_voting_deadline" db:"captain_voting_deadline"`
+	CaptainVoteSeed        int64                  `json:"captain_vote_seed" db:"captain_vote_seed"`         // Seed used to break ties that survive the ELO comparison
+	Team1WinProbability    float64                `json:"team1_win_probability" db:"team1_win_probability"` // Predicted from team ELO once teams are set
+	Team2WinProbability    float64                `json:"team2_win_probability" db:"team2_win_probability"`
 	SelectedMap            string                 `json:"selected_map" db:"selected_map"`
 	BannedMaps             []string               `json:"banned_maps" db:"banned_maps"`
+	Lobby                  *MatchLobby            `json:"lobby,omitempty" db:"lobby"` // In-game custom lobby, once teams are set
+	SubstituteOffers       []SubstituteOffer      `json:"substitute_offers,omitempty" db:"substitute_offers"`
+	Substitutions          []Substitution         `json:"substitutions,omitempty" db:"substitutions"` // Players replaced after leaving
 	Winner                 *string                `json:"winner" db:"winner"`
+	Result                 *MatchResult           `json:"result,omitempty" db:"result"`
 	StartTime              time.Time              `json:"start_time" db:"start_time"`   // When match was found
 	ExpireTime             time.Time              `json:"expire_time" db:"expire_time"` // When acceptance expires
 	CreatedAt              time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt              time.Time              `json:"updated_at" db:"updated_at"`
 }
 
+// MatchLobby is the in-game custom game a match is played in. The host
+// creates it and posts its party code, then every player checks in once they
+// have joined.
+type MatchLobby struct {
+	HostID       string               `json:"host_id"`
+	PartyCode    string               `json:"-"` // Only shown to match participants
+	CodePostedAt *time.Time           `json:"code_posted_at,omitempty"`
+	JoinDeadline *time.Time           `json:"join_deadline,omitempty"` // Players not checked in by then are no-shows
+	CheckIns     map[string]time.Time `json:"check_ins"`               // userID -> when they joined the in-game lobby
+	NoShows      []string             `json:"no_shows,omitempty"`
+	// SubstitutesRequested is set once substitutes were offered for no-shows,
+	// a second missed deadline abandons the match
+	SubstitutesRequested bool `json:"substitutes_requested,omitempty"`
+}
+
+// Match winners accepted when reporting a result
+const (
+	MatchWinnerTeam1 = "team1"
+	MatchWinnerTeam2 = "team2"
+	MatchWinnerTie   = "tie"
+)
+
+// MatchResult is the reported outcome of a match including the round score
+type MatchResult struct {
+	Winner      string    `json:"winner" db:"winner"` // "team1", "team2", "tie"
+	Team1Rounds int       `json:"team1_rounds" db:"team1_rounds"`
+	Team2Rounds int       `json:"team2_rounds" db:"team2_rounds"`
+	ReportedBy  string    `json:"reported_by" db:"reported_by"`
+	ReportedAt  time.Time `json:"reported_at" db:"reported_at"`
+}
+
+// Team1Score returns the result from team 1's point of view: 1 win, 0.5 draw, 0 loss
+func (r MatchResult) Team1Score() float64 {
+	switch r.Winner {
+	case MatchWinnerTeam1:
+		return 1
+	case MatchWinnerTie:
+		return 0.5
+	default:
+		return 0
+	}
+}
+
 type Vote struct {
 	ID      string    `json:"id" db:"id"`
 	MatchID string    `json:"match_id" db:"match_id"`
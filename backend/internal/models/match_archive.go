@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// MatchOutcome is a completed match from one player's point of view
+type MatchOutcome string
+
+const (
+	MatchOutcomeWin  MatchOutcome = "win"
+	MatchOutcomeLoss MatchOutcome = "loss"
+	MatchOutcomeDraw MatchOutcome = "draw"
+)
+
+// ArchivedMatch is the permanent record of a completed match, kept after the
+// match room is gone
+type ArchivedMatch struct {
+	ID                     string                 `json:"id" db:"match_id"`
+	SeasonID               *int                   `json:"season_id,omitempty" db:"season_id"`
+	SelectedMap            string                 `json:"selected_map" db:"selected_map"`
+	BannedMaps             []string               `json:"banned_maps" db:"banned_maps"`
+	Captain1               string                 `json:"captain1" db:"captain1"`
+	Captain2               string                 `json:"captain2" db:"captain2"`
+	CaptainSelectionMethod CaptainSelectionMethod `json:"captain_selection_method" db:"captain_selection_method"`
+	Result                 MatchResult            `json:"result" db:"result"`
+	Team1WinProbability    float64                `json:"team1_win_probability" db:"team1_win_probability"`
+	Players                []ArchivedMatchPlayer  `json:"players"`
+	StartedAt              time.Time              `json:"started_at" db:"started_at"`
+	CompletedAt            time.Time              `json:"completed_at" db:"completed_at"`
+}
+
+// ArchivedMatchPlayer is one player's line in an archived match
+type ArchivedMatchPlayer struct {
+	UserID       string            `json:"user_id" db:"user_id"`
+	Username     string            `json:"username" db:"username"`
+	Team         string            `json:"team" db:"team"` // "team1" or "team2"
+	Role         string            `json:"role,omitempty" db:"role"`
+	Outcome      MatchOutcome      `json:"outcome" db:"outcome"`
+	RatingBefore int               `json:"rating_before" db:"rating_before"`
+	RatingAfter  int               `json:"rating_after" db:"rating_after"`
+	RatingDelta  int               `json:"rating_delta" db:"rating_delta"`
+	Stats        *PlayerMatchStats `json:"stats,omitempty" db:"stats"`
+}
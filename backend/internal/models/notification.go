@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+type NotificationType string
+
+const (
+	NotificationDecayWarning NotificationType = "decay_warning" // Rating decay starts soon unless the player plays
+)
+
+// Notification is a message for a player, shown the next time they open the app
+type Notification struct {
+	ID        string           `json:"id" db:"id"`
+	UserID    string           `json:"user_id" db:"user_id"`
+	Type      NotificationType `json:"type" db:"type"`
+	Message   string           `json:"message" db:"message"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+	ReadAt    *time.Time       `json:"read_at,omitempty" db:"read_at"`
+}
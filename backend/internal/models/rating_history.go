@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// RatingChangeReason says why a player's rating changed
+type RatingChangeReason string
+
+const (
+	RatingChangeMatch       RatingChangeReason = "match"        // Rated match result
+	RatingChangeDecay       RatingChangeReason = "decay"        // Inactivity decay
+	RatingChangeAdjustment  RatingChangeReason = "adjustment"   // Manual adjustment by an admin
+	RatingChangeSeasonReset RatingChangeReason = "season_reset" // Soft reset at season rollover
+)
+
+// RatingHistoryEntry is one change to a player's rating
+type RatingHistoryEntry struct {
+	ID              string             `json:"id" db:"id"`
+	UserID          string             `json:"user_id" db:"user_id"`
+	Reason          RatingChangeReason `json:"reason" db:"reason"`
+	RatingBefore    int                `json:"rating_before" db:"rating_before"`
+	RatingAfter     int                `json:"rating_after" db:"rating_after"`
+	Delta           int                `json:"delta" db:"delta"`
+	DeviationBefore *float64           `json:"deviation_before,omitempty" db:"deviation_before"`
+	DeviationAfter  *float64           `json:"deviation_after,omitempty" db:"deviation_after"`
+	MatchID         string             `json:"match_id,omitempty" db:"match_id"`
+	Note            string             `json:"note,omitempty" db:"note"` // Why an admin adjusted the rating
+	CreatedAt       time.Time          `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+)
+
+// Riot regions accepted when linking an account
+var RiotRegions = []string{"na", "eu", "ap", "kr", "latam", "br"}
+
+// RiotAccount is a Riot account as returned by the account-v1 API
+type RiotAccount struct {
+	PUUID    string `json:"puuid"`
+	GameName string `json:"gameName"`
+	TagLine  string `json:"tagLine"`
+}
+
+// RiotLink is the Riot ID a user linked to their account. It only counts
+// once verified.
+type RiotLink struct {
+	UserID           string     `json:"user_id" db:"id"`
+	RiotID           string     `json:"riot_id"` // "name#tag"
+	GameName         string     `json:"game_name" db:"riot_game_name"`
+	TagLine          string     `json:"tag_line" db:"riot_tag_line"`
+	Region           string     `json:"region" db:"riot_region"`
+	Verified         bool       `json:"verified"`
+	VerifiedAt       *time.Time `json:"verified_at,omitempty" db:"riot_verified_at"`
+	VerificationCode string     `json:"verification_code,omitempty" db:"riot_verification_code"` // Only shown to the owner while pending
+}
+
+// RiotMatch is a match document in the shape returned by Riot's
+// val-match-v1 API. Only the fields used for imports are decoded.
+type RiotMatch struct {
+	MatchInfo    RiotMatchInfo     `json:"matchInfo"`
+	Players      []RiotPlayer      `json:"players"`
+	Teams        []RiotTeam        `json:"teams"`
+	RoundResults []RiotRoundResult `json:"roundResults"`
+}
+
+type RiotMatchInfo struct {
+	MatchID          string `json:"matchId"`
+	MapID            string `json:"mapId"` // e.g. "/Game/Maps/Ascent/Ascent"
+	GameLengthMillis int64  `json:"gameLengthMillis"`
+	GameStartMillis  int64  `json:"gameStartMillis"`
+	IsCompleted      bool   `json:"isCompleted"`
+	QueueID          string `json:"queueId"`
+}
+
+type RiotPlayer struct {
+	PUUID       string           `json:"puuid"`
+	GameName    string           `json:"gameName"`
+	TagLine     string           `json:"tagLine"`
+	TeamID      string           `json:"teamId"` // "Red" or "Blue"
+	CharacterID string           `json:"characterId"`
+	Stats       *RiotPlayerStats `json:"stats"`
+}
+
+type RiotPlayerStats struct {
+	Score        int `json:"score"`
+	RoundsPlayed int `json:"roundsPlayed"`
+	Kills        int `json:"kills"`
+	Deaths       int `json:"deaths"`
+	Assists      int `json:"assists"`
+}
+
+type RiotTeam struct {
+	TeamID       string `json:"teamId"`
+	Won          bool   `json:"won"`
+	RoundsPlayed int    `json:"roundsPlayed"`
+	RoundsWon    int    `json:"roundsWon"`
+}
+
+type RiotRoundResult struct {
+	RoundNum    int                    `json:"roundNum"`
+	WinningTeam string                 `json:"winningTeam"`
+	PlayerStats []RiotPlayerRoundStats `json:"playerStats"`
+}
+
+type RiotPlayerRoundStats struct {
+	PUUID  string       `json:"puuid"`
+	Kills  []RiotKill   `json:"kills"`
+	Damage []RiotDamage `json:"damage"`
+}
+
+type RiotKill struct {
+	TimeSinceRoundStartMillis int64  `json:"timeSinceRoundStartMillis"`
+	Killer                    string `json:"killer"`
+	Victim                    string `json:"victim"`
+}
+
+type RiotDamage struct {
+	Receiver  string `json:"receiver"`
+	Damage    int    `json:"damage"`
+	Legshots  int    `json:"legshots"`
+	Bodyshots int    `json:"bodyshots"`
+	Headshots int    `json:"headshots"`
+}
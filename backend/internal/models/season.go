@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+type SeasonStatus string
+
+const (
+	SeasonStatusActive SeasonStatus = "active"
+	SeasonStatusEnded  SeasonStatus = "ended"
+)
+
+type Season struct {
+	ID              int          `json:"id" db:"id"`
+	Name            string       `json:"name" db:"name"`
+	StartsAt        time.Time    `json:"starts_at" db:"starts_at"`
+	EndsAt          time.Time    `json:"ends_at" db:"ends_at"`
+	SoftResetFactor float64      `json:"soft_reset_factor" db:"soft_reset_factor"` // How far ratings are pulled toward ResetTarget when this season ends (0.5 = halfway)
+	ResetTarget     int          `json:"reset_target" db:"reset_target"`
+	Status          SeasonStatus `json:"status" db:"status"`
+	CreatedAt       time.Time    `json:"created_at" db:"created_at"`
+}
+
+// SeasonRating is a player's record in one season. For ended seasons
+// FinalRank comes from the end-of-season snapshot.
+type SeasonRating struct {
+	SeasonID    int     `json:"season_id" db:"season_id"`
+	SeasonName  string  `json:"season_name" db:"season_name"`
+	UserID      string  `json:"user_id" db:"user_id"`
+	Rating      int     `json:"rating" db:"rating"`
+	Wins        int     `json:"wins" db:"wins"`
+	Losses      int     `json:"losses" db:"losses"`
+	GamesPlayed int     `json:"games_played" db:"games_played"`
+	FinalRank   *int    `json:"final_rank,omitempty" db:"final_rank"`
+	WinRate     float64 `json:"win_rate"`
+}
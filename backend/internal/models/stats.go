@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// PlayerMatchStats is a player's scoreboard line for a finished match
+type PlayerMatchStats struct {
+	UserID          string    `json:"user_id" db:"user_id"`
+	Agent           string    `json:"agent,omitempty" db:"agent"`
+	Kills           int       `json:"kills" db:"kills"`
+	Deaths          int       `json:"deaths" db:"deaths"`
+	Assists         int       `json:"assists" db:"assists"`
+	ACS             float64   `json:"acs" db:"acs"`                           // Average combat score
+	HeadshotPercent float64   `json:"headshot_percent" db:"headshot_percent"` // 0-100
+	FirstBloods     int       `json:"first_bloods" db:"first_bloods"`
+	SubmittedBy     string    `json:"submitted_by,omitempty" db:"submitted_by"`
+	SubmittedAt     time.Time `json:"submitted_at,omitempty" db:"submitted_at"`
+}
+
+// KDA returns (kills + assists) / deaths, counting zero deaths as one
+func (s PlayerMatchStats) KDA() float64 {
+	deaths := s.Deaths
+	if deaths < 1 {
+		deaths = 1
+	}
+	return float64(s.Kills+s.Assists) / float64(deaths)
+}
+
+// CareerStats aggregates a player's submitted scoreboards and archived
+// results, over their whole career or a single season
+type CareerStats struct {
+	SeasonID         int        `json:"season_id,omitempty"`
+	MatchesPlayed    int        `json:"matches_played"`
+	MatchesWithStats int        `json:"matches_with_stats"` // Averages only count matches with a submitted scoreboard
+	Kills            int        `json:"kills"`
+	Deaths           int        `json:"deaths"`
+	Assists          int        `json:"assists"`
+	KD               float64    `json:"kd"`
+	AverageACS       float64    `json:"average_acs"`
+	HeadshotPercent  float64    `json:"headshot_percent"`
+	FirstBloods      int        `json:"first_bloods"`
+	MostPlayedAgent  string     `json:"most_played_agent,omitempty"`
+	Maps             []MapStats `json:"maps"`
+}
+
+// MapStats is a player's record on one map
+type MapStats struct {
+	Map     string  `json:"map"`
+	Played  int     `json:"played"`
+	Wins    int     `json:"wins"`
+	Losses  int     `json:"losses"`
+	Draws   int     `json:"draws"`
+	WinRate float64 `json:"win_rate"`
+}
+
+// Valorant agents accepted in submitted stats
+var ValorantAgents = []string{
+	"astra", "breach", "brimstone", "chamber", "clove", "cypher", "deadlock",
+	"fade", "gekko", "harbor", "iso", "jett", "kayo", "killjoy", "neon",
+	"omen", "phoenix", "raze", "reyna", "sage", "skye", "sova", "tejo",
+	"viper", "vyse", "waylay", "yoru",
+}
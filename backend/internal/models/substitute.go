@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// SubstituteOffer asks a queued player to replace a player who left a match.
+// The candidate has until ExpiresAt to accept before the next best player is asked.
+type SubstituteOffer struct {
+	LeaverID    string    `json:"leaver_id"`
+	CandidateID string    `json:"candidate_id"`
+	RequestedBy string    `json:"requested_by,omitempty"` // Empty when requested for lobby no-shows
+	OfferedAt   time.Time `json:"offered_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Declined    []string  `json:"declined,omitempty"` // Candidates who declined or let the offer expire
+}
+
+// Substitution records a player replaced during a match
+type Substitution struct {
+	LeaverID       string    `json:"leaver_id"`
+	LeaverUsername string    `json:"leaver_username"`
+	SubstituteID   string    `json:"substitute_id"`
+	Team           string    `json:"team"` // "team1" or "team2"
+	RequestedBy    string    `json:"requested_by,omitempty"`
+	SubstitutedAt  time.Time `json:"substituted_at"`
+}
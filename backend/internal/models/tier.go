@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tier names shown to players
+const (
+	TierUnranked = "Unranked"
+	TierRadiant  = "Radiant"
+)
+
+// RankTier is one row of the tier table: players at or above MinRating (and
+// below the next row) are in this tier and division
+type RankTier struct {
+	Name      string `json:"name"`
+	Division  int    `json:"division"` // 1-3, 0 for tiers without divisions
+	MinRating int    `json:"min_rating"`
+}
+
+// Label returns the display name, e.g. "Gold 2"
+func (t RankTier) Label() string {
+	if t.Division == 0 {
+		return t.Name
+	}
+	return fmt.Sprintf("%s %d", t.Name, t.Division)
+}
+
+// PlayerTier is the rank shown for a player
+type PlayerTier struct {
+	Tier     string `json:"tier"`
+	Division int    `json:"division,omitempty"`
+	Label    string `json:"label"`
+}
+
+type TierEventType string
+
+const (
+	TierEventPlaced    TierEventType = "placed"    // First tier after placement matches
+	TierEventPromotion TierEventType = "promotion" // Moved up at least one division
+	TierEventDemotion  TierEventType = "demotion"  // Moved down at least one division
+	TierEventShielded  TierEventType = "shielded"  // Would have been demoted but the shield held
+)
+
+// TierEvent is emitted when a rated match changes (or nearly changes) a player's tier
+type TierEvent struct {
+	UserID          string        `json:"user_id" db:"user_id"`
+	MatchID         string        `json:"match_id" db:"match_id"`
+	Type            TierEventType `json:"type" db:"event_type"`
+	FromTier        string        `json:"from_tier" db:"from_tier"`
+	ToTier          string        `json:"to_tier" db:"to_tier"`
+	ShieldGamesLeft int           `json:"shield_games_left" db:"shield_games_left"`
+	CreatedAt       time.Time     `json:"created_at" db:"created_at"`
+}
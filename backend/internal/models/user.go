@@ -5,20 +5,40 @@ import (
 )
 
 type User struct {
-	ID        string    `json:"id" db:"id"`
-	Username  string    `json:"username" db:"username"`
-	Email     string    `json:"email" db:"email"`
-	Password  string    `json:"-" db:"password"` // No incluir en JSON response
-	ELO       int       `json:"elo" db:"elo"`
-	Wins      int       `json:"wins" db:"wins"`
-	Losses    int       `json:"losses" db:"losses"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID              string     `json:"id" db:"id"`
+	Username        string     `json:"username" db:"username"`
+	Email           string     `json:"email" db:"email"`
+	Password        string     `json:"-" db:"password"` // No incluir en JSON response
+	ELO             int        `json:"elo" db:"elo"`
+	Wins            int        `json:"wins" db:"wins"`
+	Losses          int        `json:"losses" db:"losses"`
+	RatingDeviation float64    `json:"rating_deviation" db:"rating_deviation"`
+	Volatility      float64    `json:"volatility" db:"volatility"`
+	GamesPlayed     int        `json:"games_played" db:"games_played"`
+	LastMatchAt     *time.Time `json:"last_match_at,omitempty" db:"last_match_at"`
+	RiotID          string     `json:"riot_id,omitempty" db:"-"` // Verified "name#tag"
+	RiotRegion      string     `json:"riot_region,omitempty" db:"riot_region"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type UserStats struct {
 	User
-	WinRate    float64 `json:"win_rate"`
-	GamesTotal int     `json:"games_total"`
-	Rank       int     `json:"rank"`
+	WinRate              float64            `json:"win_rate"`
+	GamesTotal           int                `json:"games_total"`
+	Rank                 int                `json:"rank"`
+	ConservativeRating   float64            `json:"conservative_rating"` // elo - 2·rating_deviation
+	Provisional          bool               `json:"provisional"`         // Still in placement matches, shown as "unranked"
+	PlacementMatchesLeft int                `json:"placement_matches_left,omitempty"`
+	Season               int                `json:"season,omitempty"` // Set when stats are for a single season
+	Tier                 PlayerTier         `json:"tier"`
+	Career               *CareerStats       `json:"career,omitempty"` // Set when a single player's stats are requested
+	Metric               *LeaderboardMetric `json:"metric,omitempty"` // Set on named leaderboards
+}
+
+// LeaderboardMetric is what a player is ranked by on a named leaderboard
+type LeaderboardMetric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Games int     `json:"games"` // Games the value is computed from
 }
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// AbandonService keeps track of players who left matches
+type AbandonService struct {
+	db *sql.DB
+}
+
+func NewAbandonService(db *sql.DB) *AbandonService {
+	return &AbandonService{
+		db: db,
+	}
+}
+
+// RecordAbandon stores a substitution against the player who left and
+// increments their abandon count. Lobby no-shows of an abandoned match are
+// recorded without a substitute.
+func (as *AbandonService) RecordAbandon(matchID string, substitution models.Substitution) error {
+	if as.db == nil {
+		return nil
+	}
+
+	tx, err := as.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start abandon transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        INSERT INTO match_abandons (match_id, user_id, substitute_id, requested_by, created_at)
+        SELECT $1, id, NULLIF($3, ''), NULLIF($4, ''), $5 FROM users WHERE id::text = $2
+    `, matchID, substitution.LeaverID, substitution.SubstituteID, substitution.RequestedBy, substitution.SubstitutedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record abandon of %s: %w", substitution.LeaverID, err)
+	}
+
+	_, err = tx.Exec(`UPDATE users SET abandons = abandons + 1, updated_at = NOW() WHERE id::text = $1`, substitution.LeaverID)
+	if err != nil {
+		return fmt.Errorf("failed to count abandon of %s: %w", substitution.LeaverID, err)
+	}
+
+	return tx.Commit()
+}
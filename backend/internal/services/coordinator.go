@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Coordination backends accepted by NewCoordinator (COORDINATION_BACKEND env var)
+const (
+	CoordinationMemory   = "memory"
+	CoordinationPostgres = "postgres"
+)
+
+// Coordinator lets several backend instances share state. Each piece of
+// shared state has a name, e.g. "queue", and is changed by one instance at a
+// time while it holds the lock of that name. Implementations must be safe
+// for concurrent use.
+type Coordinator interface {
+	// Lock blocks until this instance holds the cluster-wide lock of name
+	// and returns the function releasing it
+	Lock(ctx context.Context, name string) (unlock func(), err error)
+	// Load returns the state stored under name and its revision, or nil if
+	// nothing newer than revision after was stored
+	Load(ctx context.Context, name string, after int64) (data []byte, revision int64, err error)
+	// Store saves states, by name, in one transaction, tells every instance
+	// watching them and returns their new revisions. Either every state is
+	// stored or none is.
+	Store(ctx context.Context, states map[string][]byte) (revisions map[string]int64, err error)
+	// Watch calls changed whenever state is stored under name, by any
+	// instance, and after reconnecting when changes may have been missed
+	Watch(name string, changed func())
+	Close() error
+}
+
+// NewCoordinator returns the coordination backend registered under name.
+// The memory backend, also selected by an empty name, returns a nil
+// Coordinator: the instance keeps its state to itself.
+func NewCoordinator(name string, db *sql.DB, databaseURL string, logger *slog.Logger) (Coordinator, error) {
+	switch name {
+	case "", CoordinationMemory:
+		return nil, nil
+	case CoordinationPostgres:
+		return NewPostgresCoordinator(db, databaseURL, logger)
+	default:
+		return nil, fmt.Errorf("unknown coordination backend %q", name)
+	}
+}
+
+// sharedStateChannel is the LISTEN/NOTIFY channel announcing stored state,
+// its payload is the name of the state
+const sharedStateChannel = "shared_state"
+
+// PostgresCoordinator coordinates instances through the database they
+// share: session advisory locks for Lock, the shared_state table for Load
+// and Store, and LISTEN/NOTIFY for Watch
+type PostgresCoordinator struct {
+	db       *sql.DB
+	listener *pq.Listener
+	logger   *slog.Logger
+
+	mutex    sync.Mutex
+	watchers map[string][]func()
+}
+
+func NewPostgresCoordinator(db *sql.DB, databaseURL string, logger *slog.Logger) (*PostgresCoordinator, error) {
+	pc := &PostgresCoordinator{
+		db:       db,
+		logger:   logger,
+		watchers: make(map[string][]func()),
+	}
+	pc.listener = pq.NewListener(databaseURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			pc.logger.Warn("shared state listener", "event", event, "error", err)
+		}
+	})
+	if err := pc.listener.Listen(sharedStateChannel); err != nil {
+		pc.listener.Close()
+		return nil, fmt.Errorf("failed to listen for shared state changes: %w", err)
+	}
+
+	go pc.listen()
+	return pc, nil
+}
+
+func (pc *PostgresCoordinator) listen() {
+	for notification := range pc.listener.Notify {
+		pc.mutex.Lock()
+		var watchers []func()
+		if notification == nil {
+			// The connection was re-established and notifications sent in
+			// the meantime are lost, so every watcher has to check
+			pc.logger.Info("shared state listener reconnected")
+			for _, named := range pc.watchers {
+				watchers = append(watchers, named...)
+			}
+		} else {
+			watchers = append(watchers, pc.watchers[notification.Extra]...)
+		}
+		pc.mutex.Unlock()
+
+		for _, changed := range watchers {
+			changed()
+		}
+	}
+}
+
+func (pc *PostgresCoordinator) Lock(ctx context.Context, name string) (func(), error) {
+	// Session advisory locks belong to a connection, so the same one has
+	// to release it
+	conn, err := pc.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", name, err)
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(hashtext($1))`, name); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", name, err)
+	}
+
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, name); err != nil {
+			pc.logger.Error("could not unlock shared state, dropping the connection", "name", name, "error", err)
+			// Closing the session is the only other way to release the lock
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, nil
+}
+
+func (pc *PostgresCoordinator) Load(ctx context.Context, name string, after int64) ([]byte, int64, error) {
+	var data []byte
+	var revision int64
+	err := pc.db.QueryRowContext(ctx, `
+        SELECT data, revision FROM shared_state WHERE name = $1 AND revision > $2
+    `, name, after).Scan(&data, &revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, after, nil
+	}
+	if err != nil {
+		return nil, after, fmt.Errorf("failed to load %s: %w", name, err)
+	}
+	return data, revision, nil
+}
+
+func (pc *PostgresCoordinator) Store(ctx context.Context, states map[string][]byte) (map[string]int64, error) {
+	tx, err := pc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start storing shared state: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Notifications are only delivered once the transaction commits
+	revisions := make(map[string]int64, len(states))
+	for name, data := range states {
+		var revision int64
+		err := tx.QueryRowContext(ctx, `
+            WITH stored AS (
+                INSERT INTO shared_state (name, revision, data, updated_at) VALUES ($1, 1, $2, NOW())
+                ON CONFLICT (name) DO UPDATE
+                SET revision = shared_state.revision + 1, data = EXCLUDED.data, updated_at = EXCLUDED.updated_at
+                RETURNING revision
+            )
+            SELECT revision FROM stored, pg_notify($3, $1)
+        `, name, data, sharedStateChannel).Scan(&revision)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store %s: %w", name, err)
+		}
+		revisions[name] = revision
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit shared state: %w", err)
+	}
+	return revisions, nil
+}
+
+func (pc *PostgresCoordinator) Watch(name string, changed func()) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.watchers[name] = append(pc.watchers[name], changed)
+}
+
+// Close stops listening for changes. Locks still held stay valid until
+// they are released.
+func (pc *PostgresCoordinator) Close() error {
+	return pc.listener.Close()
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// Decay defaults, overridable with the DECAY_* environment variables
+const (
+	DefaultDecayMinRating       = 1600
+	DefaultDecayInactiveDays    = 14
+	DefaultDecayWeeklyAmount    = 25
+	DefaultDecayWeeklyDeviation = 30.0
+	DefaultDecayWarningDays     = 3
+)
+
+const decayInterval = 7 * 24 * time.Hour
+
+// DecayOptions configures inactivity decay
+type DecayOptions struct {
+	MinRating       int           // Only players at or above this rating start decaying
+	Inactive        time.Duration // Time without a match before decay starts
+	WeeklyAmount    int           // Rating lost per week (ELO engine)
+	WeeklyDeviation float64       // Deviation added per week (Glicko-2 engine)
+	Floor           int           // Decay never takes a rating below this, defaults to MinRating
+	Warning         time.Duration // How long before decay starts the player is notified
+}
+
+// DecayResult summarises one decay run
+type DecayResult struct {
+	Warned  int `json:"warned"`
+	Decayed int `json:"decayed"`
+}
+
+// DecayService lowers the rating of high-rated players who stop playing.
+// Under ELO the rating itself drops, under Glicko-2 the deviation grows so the
+// conservative rating drops while the skill estimate is kept.
+type DecayService struct {
+	db            *sql.DB
+	engine        RatingEngine
+	notifications *NotificationService
+	history       *RatingHistoryService
+	tiers         *TierService
+	options       DecayOptions
+	logger        *slog.Logger
+}
+
+func NewDecayService(db *sql.DB, engine RatingEngine, notifications *NotificationService, history *RatingHistoryService, tiers *TierService, options DecayOptions, logger *slog.Logger) *DecayService {
+	if options.Inactive <= 0 {
+		options.Inactive = DefaultDecayInactiveDays * 24 * time.Hour
+	}
+	if options.Floor == 0 {
+		options.Floor = options.MinRating
+	}
+	return &DecayService{
+		db:            db,
+		engine:        engine,
+		notifications: notifications,
+		history:       history,
+		tiers:         tiers,
+		options:       options,
+		logger:        logger,
+	}
+}
+
+// Run warns players whose decay starts within the warning window and applies
+// every weekly decay step that is due. Steps are counted from the player's
+// last match, so running it more than once a week (or after downtime) applies
+// each step exactly once.
+func (ds *DecayService) Run(now time.Time) (DecayResult, error) {
+	var result DecayResult
+	if ds.db == nil {
+		return result, nil
+	}
+
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to start decay transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	warned, err := ds.warnPlayers(tx, now)
+	if err != nil {
+		return result, err
+	}
+	result.Warned = warned
+
+	decayed, err := ds.decayPlayers(tx, now)
+	if err != nil {
+		return result, err
+	}
+	result.Decayed = decayed
+
+	if err := tx.Commit(); err != nil {
+		return DecayResult{}, fmt.Errorf("failed to commit decay: %w", err)
+	}
+	if ds.tiers != nil && result.Decayed > 0 {
+		if err := ds.tiers.RefreshRadiant(); err != nil {
+			ds.logger.Warn("could not refresh radiant players", "error", err)
+		}
+	}
+	return result, nil
+}
+
+func (ds *DecayService) warnPlayers(tx *sql.Tx, now time.Time) (int, error) {
+	if ds.options.Warning <= 0 || ds.notifications == nil {
+		return 0, nil
+	}
+
+	rows, err := tx.Query(`
+        SELECT id::text, last_match_at FROM users
+        WHERE elo >= $1 AND last_match_at IS NOT NULL
+          AND last_match_at <= $2 AND last_match_at > $3
+          AND (decay_warned_at IS NULL OR decay_warned_at < last_match_at)
+    `, ds.options.MinRating, now.Add(-(ds.options.Inactive - ds.options.Warning)), now.Add(-ds.options.Inactive))
+	if err != nil {
+		return 0, fmt.Errorf("failed to load players to warn: %w", err)
+	}
+
+	type warning struct {
+		userID string
+		starts time.Time
+	}
+	var warnings []warning
+	for rows.Next() {
+		var userID string
+		var lastMatch time.Time
+		if err := rows.Scan(&userID, &lastMatch); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan player to warn: %w", err)
+		}
+		warnings = append(warnings, warning{userID: userID, starts: lastMatch.Add(ds.options.Inactive)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, w := range warnings {
+		message := fmt.Sprintf("Your rating starts decaying on %s unless you play a ranked match.", w.starts.Format("Jan 2 15:04 MST"))
+		if err := ds.notifications.Notify(tx, w.userID, models.NotificationDecayWarning, message, now); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE users SET decay_warned_at = $2 WHERE id::text = $1`, w.userID, now); err != nil {
+			return 0, fmt.Errorf("failed to mark decay warning for %s: %w", w.userID, err)
+		}
+	}
+	return len(warnings), nil
+}
+
+func (ds *DecayService) decayPlayers(tx *sql.Tx, now time.Time) (int, error) {
+	// Players already decaying keep decaying after they drop below MinRating,
+	// down to the floor. The rows stay locked until the transaction commits
+	// so a match result can't be rated in between and then overwritten.
+	rows, err := tx.Query(`
+        SELECT id::text, elo, rating_deviation, last_match_at, COALESCE(decay_steps, 0) FROM users
+        WHERE last_match_at IS NOT NULL AND last_match_at <= $1
+          AND (elo >= $2 OR COALESCE(decay_steps, 0) > 0)
+        FOR UPDATE
+    `, now.Add(-ds.options.Inactive), ds.options.MinRating)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load inactive players: %w", err)
+	}
+
+	type candidate struct {
+		userID    string
+		rating    int
+		deviation float64
+		lastMatch time.Time
+		steps     int
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.userID, &c.rating, &c.deviation, &c.lastMatch, &c.steps); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan inactive player: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	decayed := 0
+	var lowered []string // Players whose rating, and so maybe tier, dropped
+	for _, c := range candidates {
+		due := int(now.Sub(c.lastMatch.Add(ds.options.Inactive))/decayInterval) + 1
+		steps := due - c.steps
+		if steps <= 0 {
+			continue
+		}
+
+		rating, deviation := ds.decay(c.rating, c.deviation, steps)
+		// Only decay the rating that was read, in case the player was rated
+		// or decayed by another instance since
+		result, err := tx.Exec(`
+            UPDATE users SET elo = $2, rating_deviation = $3, decay_steps = $4, updated_at = NOW()
+            WHERE id::text = $1 AND elo = $5 AND COALESCE(decay_steps, 0) = $6 AND last_match_at = $7
+        `, c.userID, rating, deviation, due, c.rating, c.steps, c.lastMatch)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decay %s: %w", c.userID, err)
+		}
+		if updated, err := result.RowsAffected(); err != nil {
+			return 0, fmt.Errorf("failed to decay %s: %w", c.userID, err)
+		} else if updated == 0 {
+			ds.logger.Info("skipping decay, player changed meanwhile", "user_id", c.userID)
+			continue
+		}
+
+		if rating == c.rating && deviation == c.deviation {
+			continue // Already at the floor
+		}
+
+		if ds.history != nil {
+			deviationBefore, deviationAfter := c.deviation, deviation
+			err := ds.history.Record(tx, models.RatingHistoryEntry{
+				UserID:          c.userID,
+				Reason:          models.RatingChangeDecay,
+				RatingBefore:    c.rating,
+				RatingAfter:     rating,
+				DeviationBefore: &deviationBefore,
+				DeviationAfter:  &deviationAfter,
+				CreatedAt:       now,
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		ds.logger.Info("rating decayed", "user_id", c.userID, "rating_before", c.rating, "rating_after", rating,
+			"deviation_before", c.deviation, "deviation_after", deviation, "steps", steps)
+		decayed++
+		if rating != c.rating {
+			lowered = append(lowered, c.userID)
+		}
+	}
+
+	if ds.tiers != nil && len(lowered) > 0 {
+		if err := ds.tiers.ResetTiers(tx, lowered); err != nil {
+			return 0, err
+		}
+	}
+	return decayed, nil
+}
+
+// decay applies steps weekly decays to a rating and deviation
+func (ds *DecayService) decay(rating int, deviation float64, steps int) (int, float64) {
+	if ds.engine != nil && ds.engine.Name() == RatingEngineGlicko2 {
+		return rating, math.Min(glicko2InitialDeviation, deviation+float64(steps)*ds.options.WeeklyDeviation)
+	}
+
+	if rating <= ds.options.Floor {
+		return rating, deviation
+	}
+	decayed := rating - steps*ds.options.WeeklyAmount
+	if decayed < ds.options.Floor {
+		decayed = ds.options.Floor
+	}
+	return decayed, deviation
+}
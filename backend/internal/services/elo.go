@@ -4,37 +4,64 @@ import (
     "math"
 )
 
-type ELOService struct{}
+const (
+    DefaultKFactor = 32.0 // Factor K para ELO
+    minELO         = 100.0
+    maxELO         = 3000.0
+)
+
+type ELOService struct {
+    kFactor float64
+}
 
 func NewELOService() *ELOService {
-    return &ELOService{}
+    return NewELOServiceWithKFactor(DefaultKFactor)
+}
+
+// NewELOServiceWithKFactor creates an ELOService whose games move ratings by
+// at most kFactor points
+func NewELOServiceWithKFactor(kFactor float64) *ELOService {
+    return &ELOService{kFactor: kFactor}
+}
+
+// KFactor returns the maximum rating change of a single game
+func (es *ELOService) KFactor() float64 {
+    return es.kFactor
+}
+
+// ClampELO keeps a rating within the supported range
+func (es *ELOService) ClampELO(elo float64) float64 {
+    return math.Max(minELO, math.Min(maxELO, elo))
 }
 
 // Calcula nuevo ELO basado en resultado y ELO del oponente
 func (es *ELOService) CalculateNewELO(playerELO, opponentAvgELO int, won bool) int {
-    k := 32.0 // Factor K para ELO
-    
-    // Probabilidad esperada de ganar
-    expectedScore := 1.0 / (1.0 + math.Pow(10, float64(opponentAvgELO-playerELO)/400.0))
-    
     // Puntuación real (1 si ganó, 0 si perdió)
     actualScore := 0.0
     if won {
         actualScore = 1.0
     }
     
+    return es.CalculateNewELOWithScore(playerELO, opponentAvgELO, actualScore)
+}
+
+// CalculateNewELOWithScore is CalculateNewELO for any score: 1 win, 0.5 draw, 0 loss
+func (es *ELOService) CalculateNewELOWithScore(playerELO, opponentAvgELO int, actualScore float64) int {
+    k := es.KFactor()
+    
+    // Probabilidad esperada de ganar
+    expectedScore := es.WinProbability(playerELO, opponentAvgELO)
+    
     // Calcular nuevo ELO
     newELO := float64(playerELO) + k*(actualScore-expectedScore)
     
     // Asegurar que no baje de 100 ni suba más de 3000
-    if newELO < 100 {
-        newELO = 100
-    }
-    if newELO > 3000 {
-        newELO = 3000
-    }
-    
-    return int(math.Round(newELO))
+    return int(math.Round(es.ClampELO(newELO)))
+}
+
+// WinProbability returns the expected score of a player (or team average) against an opponent
+func (es *ELOService) WinProbability(playerELO, opponentELO int) float64 {
+    return 1.0 / (1.0 + math.Pow(10, float64(opponentELO-playerELO)/400.0))
 }
 
 func (es *ELOService) CalculateTeamAverageELO(playerELOs []int) int {
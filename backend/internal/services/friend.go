@@ -0,0 +1,93 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// MaxFriends bounds the size of a friends list
+const MaxFriends = 200
+
+// FriendService keeps each player's friends list
+type FriendService struct {
+	db *sql.DB
+}
+
+func NewFriendService(db *sql.DB) *FriendService {
+	return &FriendService{
+		db: db,
+	}
+}
+
+// AddFriend adds friendID to userID's friends list. Adding a friend twice is a no-op.
+func (fs *FriendService) AddFriend(userID, friendID string) error {
+	if userID == friendID {
+		return fmt.Errorf("you cannot add yourself as a friend")
+	}
+
+	var count int
+	if err := fs.db.QueryRow(`SELECT COUNT(*) FROM friends WHERE user_id::text = $1`, userID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count friends: %w", err)
+	}
+	if count >= MaxFriends {
+		return fmt.Errorf("friends lists are limited to %d players", MaxFriends)
+	}
+
+	result, err := fs.db.Exec(`
+        INSERT INTO friends (user_id, friend_id)
+        SELECT u.id, f.id FROM users u, users f
+        WHERE u.id::text = $1 AND f.id::text = $2
+        ON CONFLICT DO NOTHING
+    `, userID, friendID)
+	if err != nil {
+		return fmt.Errorf("failed to add friend: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		var exists bool
+		err := fs.db.QueryRow(`
+            SELECT EXISTS (SELECT 1 FROM friends WHERE user_id::text = $1 AND friend_id::text = $2)
+        `, userID, friendID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to add friend: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("user not found")
+		}
+	}
+	return nil
+}
+
+// RemoveFriend removes friendID from userID's friends list
+func (fs *FriendService) RemoveFriend(userID, friendID string) error {
+	_, err := fs.db.Exec(`DELETE FROM friends WHERE user_id::text = $1 AND friend_id::text = $2`, userID, friendID)
+	if err != nil {
+		return fmt.Errorf("failed to remove friend: %w", err)
+	}
+	return nil
+}
+
+// ListFriends returns a player's friends, most recently added first
+func (fs *FriendService) ListFriends(userID string) ([]models.Friend, error) {
+	rows, err := fs.db.Query(`
+        SELECT f.friend_id::text, u.username, u.elo, f.created_at
+        FROM friends f
+        JOIN users u ON u.id = f.friend_id
+        WHERE f.user_id::text = $1
+        ORDER BY f.created_at DESC
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load friends: %w", err)
+	}
+	defer rows.Close()
+
+	friends := []models.Friend{}
+	for rows.Next() {
+		var friend models.Friend
+		if err := rows.Scan(&friend.UserID, &friend.Username, &friend.ELO, &friend.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan friend: %w", err)
+		}
+		friends = append(friends, friend)
+	}
+	return friends, rows.Err()
+}
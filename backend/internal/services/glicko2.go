@@ -0,0 +1,161 @@
+package services
+
+import (
+	"math"
+	"time"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// Glicko-2 defaults, see http://www.glicko.net/glicko/glicko2.pdf
+const (
+	glicko2Scale              = 173.7178
+	glicko2InitialRating      = 1000.0
+	glicko2InitialDeviation   = 350.0
+	glicko2MinDeviation       = 30.0
+	glicko2PlacementDeviation = 150.0 // Deviation never drops below this during placements
+	glicko2InitialVolatility  = 0.06
+	glicko2Tau                = 0.5
+	glicko2Epsilon            = 0.000001
+	glicko2RatingPeriod       = 24 * time.Hour
+)
+
+// Glicko2Engine implements Glicko-2. Rating deviation shrinks as a player
+// plays and grows again by one volatility step per idle rating period.
+type Glicko2Engine struct {
+	tau              float64
+	ratingPeriod     time.Duration
+	placementMatches int
+	elo              *ELOService
+}
+
+func NewGlicko2Engine(placementMatches int) *Glicko2Engine {
+	return &Glicko2Engine{
+		tau:              glicko2Tau,
+		ratingPeriod:     glicko2RatingPeriod,
+		placementMatches: placementMatches,
+		elo:              NewELOService(),
+	}
+}
+
+func (g *Glicko2Engine) Name() string {
+	return RatingEngineGlicko2
+}
+
+func (g *Glicko2Engine) Initial(userID string) models.Elo {
+	return models.Elo{
+		UserID:     userID,
+		Rating:     glicko2InitialRating,
+		Deviation:  glicko2InitialDeviation,
+		Volatility: glicko2InitialVolatility,
+	}
+}
+
+// ApplyInactivity grows the deviation for every full rating period since the
+// player's last match, up to the deviation of a new player
+func (g *Glicko2Engine) ApplyInactivity(player models.Elo, now time.Time) models.Elo {
+	if player.LastMatch.IsZero() || !now.After(player.LastMatch) {
+		return player
+	}
+
+	periods := float64(now.Sub(player.LastMatch) / g.ratingPeriod)
+	if periods < 1 {
+		return player
+	}
+
+	phi := player.Deviation / glicko2Scale
+	phi = math.Sqrt(phi*phi + periods*player.Volatility*player.Volatility)
+	player.Deviation = math.Min(phi*glicko2Scale, glicko2InitialDeviation)
+	return player
+}
+
+func (g *Glicko2Engine) Update(player models.Elo, results []RatingOpponent, now time.Time) models.Elo {
+	if len(results) == 0 {
+		return player
+	}
+	if player.Volatility <= 0 {
+		player.Volatility = glicko2InitialVolatility
+	}
+	if player.Deviation <= 0 {
+		player.Deviation = glicko2InitialDeviation
+	}
+
+	player = g.ApplyInactivity(player, now)
+
+	mu := (player.Rating - glicko2InitialRating) / glicko2Scale
+	phi := player.Deviation / glicko2Scale
+	sigma := player.Volatility
+
+	// Estimated variance (v) and improvement (delta) from this period's games
+	var invV, improvement float64
+	for _, result := range results {
+		muJ := (result.Rating - glicko2InitialRating) / glicko2Scale
+		phiJ := result.Deviation / glicko2Scale
+		gPhi := glicko2G(phiJ)
+		expected := 1.0 / (1.0 + math.Exp(-gPhi*(mu-muJ)))
+
+		invV += gPhi * gPhi * expected * (1 - expected)
+		improvement += gPhi * (result.Score - expected)
+	}
+	v := 1.0 / invV
+	delta := v * improvement
+
+	sigma = g.newVolatility(sigma, phi, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1.0 / math.Sqrt(1.0/(phiStar*phiStar)+1.0/v)
+	mu = mu + phi*phi*improvement
+
+	minDeviation := glicko2MinDeviation
+	if player.IsProvisional(g.placementMatches) {
+		minDeviation = glicko2PlacementDeviation
+	}
+
+	player.Rating = g.elo.ClampELO(mu*glicko2Scale + glicko2InitialRating)
+	player.Deviation = math.Max(minDeviation, math.Min(phi*glicko2Scale, glicko2InitialDeviation))
+	player.Volatility = sigma
+	player.GamesPlayed += len(results)
+	player.LastMatch = now
+	return player
+}
+
+// newVolatility solves for the new volatility with the Illinois algorithm (step 5 of the paper)
+func (g *Glicko2Engine) newVolatility(sigma, phi, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	tau2 := g.tau * g.tau
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/tau2
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*g.tau) < 0 {
+			k++
+		}
+		B = a - k*g.tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glicko2Epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA = fA / 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
+
+// glicko2G reduces the impact of a result against an opponent with an uncertain rating
+func glicko2G(phi float64) float64 {
+	return 1.0 / math.Sqrt(1.0+3.0*phi*phi/(math.Pi*math.Pi))
+}
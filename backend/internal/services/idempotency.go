@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Idempotency stores accepted by NewIdempotencyStore (IDEMPOTENCY_STORE env var)
+const (
+	IdempotencyStoreMemory   = "memory"
+	IdempotencyStorePostgres = "postgres"
+)
+
+// DefaultIdempotencyWindow is how long the response to a request with an
+// Idempotency-Key is replayed for
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// idempotencyReservationTimeout frees keys whose request never finished,
+// e.g. because the instance serving it crashed
+const idempotencyReservationTimeout = time.Minute
+
+var (
+	// ErrIdempotencyKeyInUse is returned while the first request with a key is still running
+	ErrIdempotencyKeyInUse = errors.New("a request with this Idempotency-Key is still in progress")
+	// ErrIdempotencyKeyMismatch is returned when a key is reused for a different request
+	ErrIdempotencyKeyMismatch = errors.New("this Idempotency-Key was already used for a different request")
+)
+
+// IdempotentResponse is the saved response of a request made with an
+// Idempotency-Key
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyStore keeps the responses of requests made with an
+// Idempotency-Key. Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Reserve claims key for a request identified by fingerprint for
+	// window. It returns the saved response if the key was already used for
+	// the same request, ErrIdempotencyKeyInUse if that request is still
+	// running and ErrIdempotencyKeyMismatch if the key was used for another
+	// request. A nil response and error means the request should run.
+	Reserve(ctx context.Context, key, fingerprint string, window time.Duration) (*IdempotentResponse, error)
+	// Save stores the response of a reserved key
+	Save(ctx context.Context, key string, response IdempotentResponse) error
+	// Release frees a reserved key without a response so the request can be retried
+	Release(ctx context.Context, key string) error
+	// Prune deletes keys whose window has passed and returns how many
+	Prune(ctx context.Context, now time.Time) (int, error)
+}
+
+// NewIdempotencyStore returns the store registered under name. An empty
+// name selects the memory store, which only sees requests served by this
+// instance.
+func NewIdempotencyStore(name string, db *sql.DB) (IdempotencyStore, error) {
+	switch name {
+	case "", IdempotencyStoreMemory:
+		return NewMemoryIdempotencyStore(), nil
+	case IdempotencyStorePostgres:
+		return NewPostgresIdempotencyStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown idempotency store %q", name)
+	}
+}
+
+type idempotencyEntry struct {
+	fingerprint string
+	response    *IdempotentResponse // nil while the request is running
+	reservedAt  time.Time
+	expiresAt   time.Time
+}
+
+// MemoryIdempotencyStore keeps keys in process memory
+type MemoryIdempotencyStore struct {
+	entries map[string]*idempotencyEntry
+	mutex   sync.Mutex
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+func (ms *MemoryIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, window time.Duration) (*IdempotentResponse, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	now := time.Now()
+	entry, exists := ms.entries[key]
+	if exists && !entry.reusable(now) {
+		switch {
+		case entry.fingerprint != fingerprint:
+			return nil, ErrIdempotencyKeyMismatch
+		case entry.response == nil:
+			return nil, ErrIdempotencyKeyInUse
+		default:
+			return entry.response, nil
+		}
+	}
+
+	ms.entries[key] = &idempotencyEntry{
+		fingerprint: fingerprint,
+		reservedAt:  now,
+		expiresAt:   now.Add(window),
+	}
+	return nil, nil
+}
+
+// reusable reports whether the key's window passed or its request was abandoned
+func (entry *idempotencyEntry) reusable(now time.Time) bool {
+	return now.After(entry.expiresAt) ||
+		(entry.response == nil && now.Sub(entry.reservedAt) > idempotencyReservationTimeout)
+}
+
+func (ms *MemoryIdempotencyStore) Save(ctx context.Context, key string, response IdempotentResponse) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	entry, exists := ms.entries[key]
+	if !exists {
+		return fmt.Errorf("idempotency key is not reserved")
+	}
+	entry.response = &response
+	return nil
+}
+
+func (ms *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if entry, exists := ms.entries[key]; exists && entry.response == nil {
+		delete(ms.entries, key)
+	}
+	return nil
+}
+
+func (ms *MemoryIdempotencyStore) Prune(ctx context.Context, now time.Time) (int, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	pruned := 0
+	for key, entry := range ms.entries {
+		if entry.reusable(now) {
+			delete(ms.entries, key)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// PostgresIdempotencyStore keeps keys in the idempotency_keys table, so a
+// retry is recognised whichever instance it reaches
+type PostgresIdempotencyStore struct {
+	db *sql.DB
+}
+
+func NewPostgresIdempotencyStore(db *sql.DB) *PostgresIdempotencyStore {
+	return &PostgresIdempotencyStore{
+		db: db,
+	}
+}
+
+func (ps *PostgresIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, window time.Duration) (*IdempotentResponse, error) {
+	// Take the key if it is new, its window passed or its request was abandoned
+	result, err := ps.db.ExecContext(ctx, `
+        INSERT INTO idempotency_keys (key, fingerprint, reserved_at, expires_at)
+        VALUES ($1, $2, NOW(), NOW() + make_interval(secs => $3))
+        ON CONFLICT (key) DO UPDATE
+        SET fingerprint = EXCLUDED.fingerprint, status = NULL, content_type = NULL, body = NULL,
+            reserved_at = EXCLUDED.reserved_at, expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at < NOW()
+           OR (idempotency_keys.status IS NULL AND idempotency_keys.reserved_at < NOW() - make_interval(secs => $4))
+    `, key, fingerprint, window.Seconds(), idempotencyReservationTimeout.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		return nil, nil
+	}
+
+	var savedFingerprint string
+	var status sql.NullInt64
+	var contentType sql.NullString
+	var body []byte
+	err = ps.db.QueryRowContext(ctx, `
+        SELECT fingerprint, status, content_type, body FROM idempotency_keys WHERE key = $1
+    `, key).Scan(&savedFingerprint, &status, &contentType, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idempotency key: %w", err)
+	}
+
+	switch {
+	case savedFingerprint != fingerprint:
+		return nil, ErrIdempotencyKeyMismatch
+	case !status.Valid:
+		return nil, ErrIdempotencyKeyInUse
+	default:
+		return &IdempotentResponse{
+			Status:      int(status.Int64),
+			ContentType: contentType.String,
+			Body:        body,
+		}, nil
+	}
+}
+
+func (ps *PostgresIdempotencyStore) Save(ctx context.Context, key string, response IdempotentResponse) error {
+	_, err := ps.db.ExecContext(ctx, `
+        UPDATE idempotency_keys SET status = $2, content_type = $3, body = $4 WHERE key = $1
+    `, key, response.Status, response.ContentType, response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to save idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (ps *PostgresIdempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := ps.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = $1 AND status IS NULL`, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (ps *PostgresIdempotencyStore) Prune(ctx context.Context, now time.Time) (int, error) {
+	result, err := ps.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune idempotency keys: %w", err)
+	}
+	pruned, _ := result.RowsAffected()
+	return int(pruned), nil
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Leaderboard sort orders served by LeaderboardIndex
+const (
+	LeaderboardSortELO          = "elo"
+	LeaderboardSortConservative = "conservative"
+)
+
+// DefaultLeaderboardReconcileInterval is how often the leaderboard index is
+// rebuilt from the database to pick up changes it was not told about
+const DefaultLeaderboardReconcileInterval = 5 * time.Minute
+
+// LeaderboardPosition is a ranked player as seen by the leaderboard index
+type LeaderboardPosition struct {
+	UserID string
+	Rank   int
+	Value  float64 // The rating the player is ranked by
+}
+
+// indexedPlayer is what the index keeps per ranked player
+type indexedPlayer struct {
+	elo          float64
+	conservative float64
+}
+
+func (p indexedPlayer) key(sortBy, userID string) rankKey {
+	if sortBy == LeaderboardSortConservative {
+		return rankKey{value: p.conservative, userID: userID}
+	}
+	return rankKey{value: p.elo, userID: userID}
+}
+
+// LeaderboardIndex keeps every ranked player in memory, ordered once per
+// sort, so top-N pages, ranks and around-me ranges are served in
+// logarithmic time instead of ranking the users table on every request.
+// Callers refresh the players whose rating changed; Rebuild reconciles the
+// whole index with the database.
+type LeaderboardIndex struct {
+	db               *sql.DB
+	placementMatches int
+	players          map[string]indexedPlayer
+	trees            map[string]*rankTree
+	ready            bool
+	generation       int64 // Start time of the process, so ETags never repeat across restarts
+	version          uint64
+	refreshes        uint64            // Number of Refresh calls
+	refreshed        map[string]uint64 // userID -> refreshes when last refreshed, kept for running rebuilds
+	mutex            sync.RWMutex
+}
+
+func NewLeaderboardIndex(db *sql.DB, placementMatches int) *LeaderboardIndex {
+	return &LeaderboardIndex{
+		db:               db,
+		placementMatches: placementMatches,
+		players:          make(map[string]indexedPlayer),
+		trees:            newRankTrees(),
+		refreshed:        make(map[string]uint64),
+		generation:       time.Now().Unix(),
+	}
+}
+
+func newRankTrees() map[string]*rankTree {
+	return map[string]*rankTree{
+		LeaderboardSortELO:          {},
+		LeaderboardSortConservative: {},
+	}
+}
+
+// Ready reports whether the index has been built at least once
+func (li *LeaderboardIndex) Ready() bool {
+	li.mutex.RLock()
+	defer li.mutex.RUnlock()
+	return li.ready
+}
+
+// ETag identifies the current state of the index. It changes whenever a
+// player's position or rating changes.
+func (li *LeaderboardIndex) ETag() string {
+	li.mutex.RLock()
+	defer li.mutex.RUnlock()
+	return fmt.Sprintf(`W/"%d-%d"`, li.generation, li.version)
+}
+
+// Rebuild loads every ranked player from the database and replaces the index.
+// Players refreshed while it loads keep their refreshed position, which is
+// newer. Returns how many players were missing, stale or no longer ranked.
+func (li *LeaderboardIndex) Rebuild() (int, error) {
+	li.mutex.RLock()
+	started := li.refreshes
+	li.mutex.RUnlock()
+
+	rows, err := li.db.Query(`
+        SELECT id::text, elo, COALESCE(rating_deviation, 0)
+        FROM users
+        WHERE COALESCE(games_played, 0) >= $1
+    `, li.placementMatches)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	players := make(map[string]indexedPlayer)
+	trees := newRankTrees()
+	for rows.Next() {
+		var userID string
+		var elo int
+		var deviation float64
+		if err := rows.Scan(&userID, &elo, &deviation); err != nil {
+			return 0, fmt.Errorf("failed to scan leaderboard: %w", err)
+		}
+		player := indexedPlayer{elo: float64(elo), conservative: float64(elo) - 2*deviation}
+		players[userID] = player
+		for sortBy, tree := range trees {
+			tree.Insert(player.key(sortBy, userID))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to load leaderboard: %w", err)
+	}
+
+	li.mutex.Lock()
+	defer li.mutex.Unlock()
+
+	for userID, refreshed := range li.refreshed {
+		if refreshed <= started {
+			// Already in what was loaded
+			delete(li.refreshed, userID)
+			continue
+		}
+		if loaded, ok := players[userID]; ok {
+			for sortBy, tree := range trees {
+				tree.Delete(loaded.key(sortBy, userID))
+			}
+			delete(players, userID)
+		}
+		if current, ok := li.players[userID]; ok {
+			for sortBy, tree := range trees {
+				tree.Insert(current.key(sortBy, userID))
+			}
+			players[userID] = current
+		}
+	}
+
+	drift := 0
+	for userID, player := range players {
+		if current, ok := li.players[userID]; !ok || current != player {
+			drift++
+		}
+	}
+	for userID := range li.players {
+		if _, ok := players[userID]; !ok {
+			drift++
+		}
+	}
+
+	li.players = players
+	li.trees = trees
+	if drift > 0 || !li.ready {
+		li.version++
+	}
+	li.ready = true
+	return drift, nil
+}
+
+// Refresh reloads the given players from the database and moves them to
+// their new positions, adding players who finished their placement matches.
+// The index stays locked while they are read so a refresh that read older
+// rows can't be applied after a newer one.
+func (li *LeaderboardIndex) Refresh(userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	li.mutex.Lock()
+	defer li.mutex.Unlock()
+
+	rows, err := li.db.Query(`
+        SELECT id::text, elo, COALESCE(rating_deviation, 0)
+        FROM users
+        WHERE id::text = ANY($1) AND COALESCE(games_played, 0) >= $2
+    `, pq.Array(userIDs), li.placementMatches)
+	if err != nil {
+		return fmt.Errorf("failed to refresh leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	ranked := make(map[string]indexedPlayer, len(userIDs))
+	for rows.Next() {
+		var userID string
+		var elo int
+		var deviation float64
+		if err := rows.Scan(&userID, &elo, &deviation); err != nil {
+			return fmt.Errorf("failed to scan leaderboard: %w", err)
+		}
+		ranked[userID] = indexedPlayer{elo: float64(elo), conservative: float64(elo) - 2*deviation}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to refresh leaderboard: %w", err)
+	}
+
+	li.refreshes++
+	for _, userID := range userIDs {
+		li.refreshed[userID] = li.refreshes
+	}
+	for _, userID := range userIDs {
+		current, indexed := li.players[userID]
+		player, isRanked := ranked[userID]
+		if indexed && isRanked && current == player {
+			continue
+		}
+		if indexed {
+			for sortBy, tree := range li.trees {
+				tree.Delete(current.key(sortBy, userID))
+			}
+			delete(li.players, userID)
+		}
+		if isRanked {
+			for sortBy, tree := range li.trees {
+				tree.Insert(player.key(sortBy, userID))
+			}
+			li.players[userID] = player
+		}
+	}
+	// Wins, losses and stats of the players changed even if their positions did not
+	li.version++
+	return nil
+}
+
+// Total returns how many players are ranked
+func (li *LeaderboardIndex) Total() int {
+	li.mutex.RLock()
+	defer li.mutex.RUnlock()
+	return len(li.players)
+}
+
+// Page returns up to limit players starting at the zero-based offset
+func (li *LeaderboardIndex) Page(sortBy string, offset, limit int) []LeaderboardPosition {
+	li.mutex.RLock()
+	defer li.mutex.RUnlock()
+	return li.positions(sortBy, offset, limit)
+}
+
+// After returns up to limit players ranked after the player with the given
+// value and user ID, who no longer has to be ranked there
+func (li *LeaderboardIndex) After(sortBy string, value float64, userID string, limit int) []LeaderboardPosition {
+	li.mutex.RLock()
+	defer li.mutex.RUnlock()
+
+	tree, ok := li.trees[sortBy]
+	if !ok {
+		return nil
+	}
+	cursor := rankKey{value: value, userID: userID}
+	start := tree.CountBefore(cursor)
+	if player, ok := li.players[userID]; ok && player.key(sortBy, userID) == cursor {
+		start++
+	}
+	return li.positions(sortBy, start, limit)
+}
+
+// Rank returns a player's one-based rank, or false when they are not ranked
+func (li *LeaderboardIndex) Rank(sortBy, userID string) (int, bool) {
+	li.mutex.RLock()
+	defer li.mutex.RUnlock()
+
+	player, ok := li.players[userID]
+	tree, sortOK := li.trees[sortBy]
+	if !ok || !sortOK {
+		return 0, false
+	}
+	return tree.CountBefore(player.key(sortBy, userID)) + 1, true
+}
+
+// Around returns a player's rank and the players up to aroundRange places
+// above and below them
+func (li *LeaderboardIndex) Around(sortBy, userID string, aroundRange int) (int, []LeaderboardPosition, bool) {
+	li.mutex.RLock()
+	defer li.mutex.RUnlock()
+
+	player, ok := li.players[userID]
+	tree, sortOK := li.trees[sortBy]
+	if !ok || !sortOK {
+		return 0, nil, false
+	}
+	rank := tree.CountBefore(player.key(sortBy, userID)) + 1
+	return rank, li.positions(sortBy, rank-1-aroundRange, 2*aroundRange+1), true
+}
+
+// positions must be called with li.mutex held
+func (li *LeaderboardIndex) positions(sortBy string, start, limit int) []LeaderboardPosition {
+	tree, ok := li.trees[sortBy]
+	if !ok {
+		return nil
+	}
+	first := start
+	if first < 0 {
+		first = 0
+	}
+	keys := tree.Slice(start, limit)
+	positions := make([]LeaderboardPosition, len(keys))
+	for i, key := range keys {
+		positions[i] = LeaderboardPosition{UserID: key.userID, Rank: first + i + 1, Value: key.value}
+	}
+	return positions
+}
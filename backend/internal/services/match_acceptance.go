@@ -2,13 +2,16 @@ package services
 
 import (
 	"fmt"
+	"log/slog"
 	"time"
+	"valorant-mobile-web/backend/internal/metrics"
 	"valorant-mobile-web/backend/internal/models"
 )
 
 type MatchAcceptanceService struct {
 	matchRoomService *MatchRoomService
 	queueService     *QueueService
+	logger           *slog.Logger
 }
 
 // Remove default constructor to enforce singleton usage
@@ -19,79 +22,101 @@ type MatchAcceptanceService struct {
 //     }
 // }
 
-func (mas *MatchAcceptanceService) AcceptMatch(matchID, userID string) error {
-	fmt.Printf("=== ACCEPT MATCH: User %s accepting match %s ===\n", userID, matchID)
+// NewMatchAcceptanceServiceWithServices creates a MatchAcceptanceService with shared service instances
+func NewMatchAcceptanceServiceWithServices(matchRoomService *MatchRoomService, queueService *QueueService, logger *slog.Logger) *MatchAcceptanceService {
+	return &MatchAcceptanceService{
+		matchRoomService: matchRoomService,
+		queueService:     queueService,
+		logger:           logger,
+	}
+}
 
-	match, err := mas.matchRoomService.GetMatchRoom(matchID)
-	if err != nil {
+func (mas *MatchAcceptanceService) AcceptMatch(matchID, userID string) error {
+	if _, err := mas.matchRoomService.GetMatchRoom(matchID); err != nil {
 		return fmt.Errorf("match not found: %v", err)
 	}
 
-	if match.Status != models.MatchStatusPending {
-		return fmt.Errorf("match is not in pending state")
-	}
+	// Changes are made under the match room lock so acceptances arriving
+	// at the same time, possibly on other instances, are all kept
+	expired := false
+	_, err := mas.matchRoomService.UpdateMatch(matchID, func(match *models.Match) error {
+		if match.Status != models.MatchStatusPending {
+			return fmt.Errorf("match is not in pending state")
+		}
 
-	// Check if match has expired
-	if time.Now().After(match.ExpireTime) {
-		return mas.cancelExpiredMatch(matchID)
-	}
+		// Check if match has expired
+		if time.Now().After(match.ExpireTime) {
+			expired = true
+			return nil
+		}
 
-	// Find and update player
-	playerFound := false
-	for i, player := range match.Players {
-		if player.UserID == userID {
-			match.Players[i].Accepted = true
-			playerFound = true
-			fmt.Printf("Player %s (%s) accepted match %s\n", player.Username, userID, matchID)
-			break
+		// Find and update player
+		playerFound := false
+		for i, player := range match.Players {
+			if player.UserID == userID {
+				match.Players[i].Accepted = true
+				playerFound = true
+				metrics.ReadyChecks.Inc("accepted")
+				mas.logger.Info("player accepted match", "match_id", matchID, "user_id", userID, "username", player.Username)
+				break
+			}
 		}
-	}
 
-	if !playerFound {
-		return fmt.Errorf("player not found in match")
-	}
+		if !playerFound {
+			return fmt.Errorf("player not found in match")
+		}
 
-	// Check if all players have accepted
-	allAccepted := true
-	acceptedCount := 0
-	for _, player := range match.Players {
-		if player.Accepted {
-			acceptedCount++
-		} else {
-			allAccepted = false
+		// Check if all players have accepted
+		allAccepted := true
+		acceptedCount := 0
+		for _, player := range match.Players {
+			if player.Accepted {
+				acceptedCount++
+			} else {
+				allAccepted = false
+			}
 		}
-	}
 
-	fmt.Printf("Match %s: %d/%d players accepted\n", matchID, acceptedCount, len(match.Players))
+		mas.logger.Debug("match acceptance", "match_id", matchID, "accepted", acceptedCount, "players", len(match.Players))
 
-	// Update match status if all accepted
-	if allAccepted {
-		match.Status = models.MatchStatusReady
-		match.UpdatedAt = time.Now()
-		fmt.Printf("🎉 All players accepted match %s! Moving to captain selection...\n", matchID)
+		// Update match status if all accepted
+		if allAccepted {
+			match.Status = models.MatchStatusReady
+			mas.logger.Info("all players accepted match, moving to captain selection", "match_id", matchID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Save updated match
-	return mas.matchRoomService.UpdateMatchRoom(match)
+	if expired {
+		metrics.ReadyChecks.Inc("late")
+		return mas.cancelExpiredMatch(matchID)
+	}
+	return nil
 }
 
 func (mas *MatchAcceptanceService) DeclineMatch(matchID, userID string) error {
-	fmt.Printf("=== DECLINE MATCH: User %s declining match %s ===\n", userID, matchID)
-
-	match, err := mas.matchRoomService.GetMatchRoom(matchID)
-	if err != nil {
+	if _, err := mas.matchRoomService.GetMatchRoom(matchID); err != nil {
 		return fmt.Errorf("match not found: %v", err)
 	}
 
-	if match.Status != models.MatchStatusPending {
-		return fmt.Errorf("match is not in pending state")
-	}
-
 	// Cancel the match
-	match.Status = models.MatchStatusCancelled
-	match.UpdatedAt = time.Now()
+	match, err := mas.matchRoomService.UpdateMatch(matchID, func(match *models.Match) error {
+		if match.Status != models.MatchStatusPending {
+			return fmt.Errorf("match is not in pending state")
+		}
+		match.Status = models.MatchStatusCancelled
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	metrics.ReadyChecks.Inc("declined")
+	metrics.Matches.Inc("cancelled", "declined")
 
-	fmt.Printf("❌ Match %s cancelled by user %s\n", matchID, userID)
+	mas.logger.Info("match declined and cancelled", "match_id", matchID, "user_id", userID)
 
 	// Return all players to queue
 	return mas.returnPlayersToQueue(match.Players)
@@ -104,28 +129,39 @@ func (mas *MatchAcceptanceService) CheckExpiredMatches() error {
 }
 
 func (mas *MatchAcceptanceService) cancelExpiredMatch(matchID string) error {
-	match, err := mas.matchRoomService.GetMatchRoom(matchID)
+	match, err := mas.matchRoomService.UpdateMatch(matchID, func(match *models.Match) error {
+		// Another player may have cancelled it first
+		if match.Status != models.MatchStatusPending {
+			return fmt.Errorf("match is not in pending state")
+		}
+		match.Status = models.MatchStatusCancelled
+		return nil
+	})
 	if err != nil {
 		return err
 	}
+	metrics.Matches.Inc("cancelled", "ready_check_expired")
 
-	match.Status = models.MatchStatusCancelled
-	match.UpdatedAt = time.Now()
-
-	fmt.Printf("⏰ Match %s expired and was cancelled\n", matchID)
+	mas.logger.Info("match expired and was cancelled", "match_id", matchID)
 
 	return mas.returnPlayersToQueue(match.Players)
 }
 
 func (mas *MatchAcceptanceService) returnPlayersToQueue(players []models.MatchPlayer) error {
-	fmt.Printf("🔄 Returning %d players to queue...\n", len(players))
-
 	for _, player := range players {
-		err := mas.queueService.JoinQueue(player.UserID, player.Username, player.ELO)
+		err := mas.queueService.JoinQueueEntry(models.QueueEntry{
+			UserID:      player.UserID,
+			Username:    player.Username,
+			ELO:         player.ELO,
+			PartyID:     player.PartyID,
+			Provisional: player.Provisional,
+			Tier:        player.Tier,
+			RiotID:      player.RiotID,
+		})
 		if err != nil {
-			fmt.Printf("Warning: Could not return player %s to queue: %v\n", player.Username, err)
+			mas.logger.Warn("could not return player to queue", "user_id", player.UserID, "error", err)
 		} else {
-			fmt.Printf("✅ Player %s returned to queue\n", player.Username)
+			mas.logger.Info("player returned to queue", "user_id", player.UserID, "username", player.Username)
 		}
 	}
 
@@ -0,0 +1,441 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"valorant-mobile-web/backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// MatchHistoryFilter narrows a player's match history
+type MatchHistoryFilter struct {
+	Map      string
+	Outcome  models.MatchOutcome
+	SeasonID int // 0 for every season
+	Limit    int
+	Offset   int
+}
+
+// MatchArchiveService persists completed matches so they outlive their
+// in-memory match room
+type MatchArchiveService struct {
+	db     *sql.DB
+	logger *slog.Logger
+
+	// Archives that could not even be queued in pending_match_archives
+	mutex   sync.Mutex
+	pending []pendingArchive
+}
+
+// pendingArchive is what ArchiveMatch needs to archive a match again
+type pendingArchive struct {
+	Match   *models.Match                      `json:"match"`
+	Ratings *models.RatingAudit                `json:"ratings,omitempty"`
+	Stats   map[string]models.PlayerMatchStats `json:"stats,omitempty"`
+}
+
+func NewMatchArchiveService(db *sql.DB, logger *slog.Logger) *MatchArchiveService {
+	return &MatchArchiveService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// ArchiveMatch stores a completed match with the rating change of every
+// player. ratings may be nil when the match was not rated.
+func (as *MatchArchiveService) ArchiveMatch(match *models.Match, ratings *models.RatingAudit, stats map[string]models.PlayerMatchStats) (*models.ArchivedMatch, error) {
+	if match.Result == nil {
+		return nil, fmt.Errorf("match %s has no result", match.ID)
+	}
+
+	archived := &models.ArchivedMatch{
+		ID:                     match.ID,
+		SelectedMap:            match.SelectedMap,
+		BannedMaps:             match.BannedMaps,
+		Captain1:               match.Captain1,
+		Captain2:               match.Captain2,
+		CaptainSelectionMethod: match.CaptainSelectionMethod,
+		Result:                 *match.Result,
+		Team1WinProbability:    match.Team1WinProbability,
+		StartedAt:              match.StartTime,
+		CompletedAt:            match.Result.ReportedAt,
+	}
+	if archived.BannedMaps == nil {
+		archived.BannedMaps = []string{}
+	}
+	archived.Players = archivedPlayers(match, ratings, stats)
+
+	if as.db == nil {
+		return archived, nil
+	}
+
+	tx, err := as.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start archive transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// A retry of a match that was archived after all leaves it as it is
+	var seasonID sql.NullInt64
+	err = tx.QueryRow(`
+        INSERT INTO match_archive (match_id, season_id, selected_map, banned_maps, captain1, captain2,
+                                   captain_selection_method, winner, team1_rounds, team2_rounds, reported_by,
+                                   team1_win_probability, started_at, completed_at)
+        VALUES ($1, (SELECT id FROM seasons WHERE status = 'active' ORDER BY id DESC LIMIT 1),
+                $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        ON CONFLICT (match_id) DO NOTHING
+        RETURNING season_id
+    `, archived.ID, archived.SelectedMap, pq.Array(archived.BannedMaps), archived.Captain1, archived.Captain2,
+		archived.CaptainSelectionMethod, archived.Result.Winner, archived.Result.Team1Rounds, archived.Result.Team2Rounds,
+		archived.Result.ReportedBy, archived.Team1WinProbability, archived.StartedAt, archived.CompletedAt).Scan(&seasonID)
+	if errors.Is(err, sql.ErrNoRows) {
+		as.logger.Info("match already archived", "match_id", archived.ID)
+		return archived, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive match %s: %w", match.ID, err)
+	}
+	if seasonID.Valid {
+		id := int(seasonID.Int64)
+		archived.SeasonID = &id
+	}
+
+	var submitted []models.PlayerMatchStats
+	for _, player := range archived.Players {
+		_, err := tx.Exec(`
+            INSERT INTO match_archive_players (match_id, user_id, username, team, role, outcome,
+                                               rating_before, rating_after, rating_delta)
+            SELECT $1, id, $3, $4, $5, $6, $7, $8, $9 FROM users WHERE id::text = $2
+        `, archived.ID, player.UserID, player.Username, player.Team, player.Role, player.Outcome,
+			player.RatingBefore, player.RatingAfter, player.RatingDelta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to archive player %s: %w", player.UserID, err)
+		}
+		if player.Stats != nil {
+			submitted = append(submitted, *player.Stats)
+		}
+	}
+
+	if err := saveMatchStats(tx, archived.ID, submitted); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit match archive: %w", err)
+	}
+
+	as.logger.Info("match archived", "match_id", archived.ID, "players", len(archived.Players))
+	return archived, nil
+}
+
+// ArchiveLater queues a match whose archive failed with cause so
+// RetryPendingArchives archives it later. The match is kept in memory if it
+// can't be queued in the database either.
+func (as *MatchArchiveService) ArchiveLater(match *models.Match, ratings *models.RatingAudit, stats map[string]models.PlayerMatchStats, cause error) {
+	pending := pendingArchive{Match: match, Ratings: ratings, Stats: stats}
+	if err := as.queuePending(pending, cause); err != nil {
+		as.logger.Error("could not queue match archive, keeping it in memory", "match_id", match.ID, "error", err)
+		as.mutex.Lock()
+		as.pending = append(as.pending, pending)
+		as.mutex.Unlock()
+		return
+	}
+	as.logger.Warn("match archive queued for retry", "match_id", match.ID, "error", cause)
+}
+
+func (as *MatchArchiveService) queuePending(pending pendingArchive, cause error) error {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("failed to encode match archive: %w", err)
+	}
+	_, err = as.db.Exec(`
+        INSERT INTO pending_match_archives (match_id, data, last_error) VALUES ($1, $2, $3)
+        ON CONFLICT (match_id) DO UPDATE
+        SET data = EXCLUDED.data, attempts = pending_match_archives.attempts + 1,
+            last_error = EXCLUDED.last_error, updated_at = NOW()
+    `, pending.Match.ID, data, cause.Error())
+	if err != nil {
+		return fmt.Errorf("failed to queue match archive: %w", err)
+	}
+	return nil
+}
+
+// RetryPendingArchives archives again every match queued by ArchiveLater
+// and returns how many were archived. Matches failing again stay queued.
+func (as *MatchArchiveService) RetryPendingArchives() (int, error) {
+	if as.db == nil {
+		return 0, nil
+	}
+
+	as.mutex.Lock()
+	inMemory := as.pending
+	as.pending = nil
+	as.mutex.Unlock()
+
+	archived := 0
+	for _, pending := range inMemory {
+		if _, err := as.ArchiveMatch(pending.Match, pending.Ratings, pending.Stats); err != nil {
+			as.ArchiveLater(pending.Match, pending.Ratings, pending.Stats, err)
+			continue
+		}
+		archived++
+	}
+
+	rows, err := as.db.Query(`SELECT match_id, data FROM pending_match_archives ORDER BY created_at`)
+	if err != nil {
+		return archived, fmt.Errorf("failed to load pending match archives: %w", err)
+	}
+	var queued []pendingArchive
+	for rows.Next() {
+		var matchID string
+		var data []byte
+		if err := rows.Scan(&matchID, &data); err != nil {
+			rows.Close()
+			return archived, fmt.Errorf("failed to scan pending match archive: %w", err)
+		}
+		var pending pendingArchive
+		if err := json.Unmarshal(data, &pending); err != nil || pending.Match == nil {
+			as.logger.Error("could not decode pending match archive", "match_id", matchID, "error", err)
+			continue
+		}
+		queued = append(queued, pending)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return archived, err
+	}
+
+	for _, pending := range queued {
+		if _, err := as.ArchiveMatch(pending.Match, pending.Ratings, pending.Stats); err != nil {
+			if err := as.queuePending(pending, err); err != nil {
+				as.logger.Error("could not update pending match archive", "match_id", pending.Match.ID, "error", err)
+			}
+			continue
+		}
+		if _, err := as.db.Exec(`DELETE FROM pending_match_archives WHERE match_id = $1`, pending.Match.ID); err != nil {
+			return archived, fmt.Errorf("failed to remove pending match archive %s: %w", pending.Match.ID, err)
+		}
+		archived++
+	}
+	return archived, nil
+}
+
+// archivedPlayers builds the player lines of an archived match
+func archivedPlayers(match *models.Match, ratings *models.RatingAudit, stats map[string]models.PlayerMatchStats) []models.ArchivedMatchPlayer {
+	usernames := make(map[string]string, len(match.Players))
+	roles := make(map[string]string, len(match.Players))
+	for _, player := range match.Players {
+		usernames[player.UserID] = player.Username
+		roles[player.UserID] = player.Role
+	}
+
+	changes := make(map[string]models.RatingAuditPlayer)
+	if ratings != nil {
+		for _, change := range ratings.Players {
+			changes[change.UserID] = change
+		}
+	}
+
+	var players []models.ArchivedMatchPlayer
+	addTeam := func(team string, userIDs []string) {
+		for _, userID := range userIDs {
+			player := models.ArchivedMatchPlayer{
+				UserID:   userID,
+				Username: usernames[userID],
+				Team:     team,
+				Role:     roles[userID],
+				Outcome:  matchOutcome(match.Result.Winner, team),
+			}
+			if change, ok := changes[userID]; ok {
+				player.RatingBefore = int(math.Round(change.RatingBefore))
+				player.RatingAfter = int(math.Round(change.RatingAfter))
+				player.RatingDelta = player.RatingAfter - player.RatingBefore
+			}
+			if playerStats, ok := stats[userID]; ok {
+				player.Stats = &playerStats
+			}
+			players = append(players, player)
+		}
+	}
+	addTeam(models.MatchWinnerTeam1, match.Team1)
+	addTeam(models.MatchWinnerTeam2, match.Team2)
+
+	// Players who left are kept with the team they abandoned, as a loss
+	for _, substitution := range match.Substitutions {
+		if _, listed := usernames[substitution.LeaverID]; listed {
+			continue
+		}
+		usernames[substitution.LeaverID] = substitution.LeaverUsername
+		player := models.ArchivedMatchPlayer{
+			UserID:   substitution.LeaverID,
+			Username: substitution.LeaverUsername,
+			Team:     substitution.Team,
+			Role:     "abandoned",
+			Outcome:  models.MatchOutcomeLoss,
+		}
+		if change, ok := changes[substitution.LeaverID]; ok {
+			player.RatingBefore = int(math.Round(change.RatingBefore))
+			player.RatingAfter = int(math.Round(change.RatingAfter))
+			player.RatingDelta = player.RatingAfter - player.RatingBefore
+		}
+		players = append(players, player)
+	}
+	return players
+}
+
+// matchOutcome returns how a match ended for team ("team1" or "team2")
+func matchOutcome(winner, team string) models.MatchOutcome {
+	switch winner {
+	case models.MatchWinnerTie:
+		return models.MatchOutcomeDraw
+	case team:
+		return models.MatchOutcomeWin
+	default:
+		return models.MatchOutcomeLoss
+	}
+}
+
+const archivedMatchColumns = `a.match_id, a.season_id, COALESCE(a.selected_map, ''), a.banned_maps,
+    COALESCE(a.captain1, ''), COALESCE(a.captain2, ''), COALESCE(a.captain_selection_method, ''),
+    a.winner, a.team1_rounds, a.team2_rounds, COALESCE(a.reported_by, ''),
+    a.team1_win_probability, COALESCE(a.started_at, a.completed_at), a.completed_at`
+
+func scanArchivedMatch(row interface{ Scan(...interface{}) error }) (*models.ArchivedMatch, error) {
+	var match models.ArchivedMatch
+	var seasonID sql.NullInt64
+	var bannedMaps []string
+	err := row.Scan(&match.ID, &seasonID, &match.SelectedMap, pq.Array(&bannedMaps),
+		&match.Captain1, &match.Captain2, &match.CaptainSelectionMethod,
+		&match.Result.Winner, &match.Result.Team1Rounds, &match.Result.Team2Rounds, &match.Result.ReportedBy,
+		&match.Team1WinProbability, &match.StartedAt, &match.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	if seasonID.Valid {
+		id := int(seasonID.Int64)
+		match.SeasonID = &id
+	}
+	if bannedMaps == nil {
+		bannedMaps = []string{}
+	}
+	match.BannedMaps = bannedMaps
+	match.Result.ReportedAt = match.CompletedAt
+	match.Players = []models.ArchivedMatchPlayer{}
+	return &match, nil
+}
+
+// GetMatch returns an archived match with all its players
+func (as *MatchArchiveService) GetMatch(matchID string) (*models.ArchivedMatch, error) {
+	match, err := scanArchivedMatch(as.db.QueryRow(`SELECT `+archivedMatchColumns+` FROM match_archive a WHERE a.match_id = $1`, matchID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("match not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load match: %w", err)
+	}
+
+	if err := as.loadPlayers([]*models.ArchivedMatch{match}); err != nil {
+		return nil, err
+	}
+	return match, nil
+}
+
+// GetUserMatches returns a page of a player's archived matches, newest
+// first, and how many matches the filter matches in total
+func (as *MatchArchiveService) GetUserMatches(userID string, filter MatchHistoryFilter) ([]*models.ArchivedMatch, int, error) {
+	where := `
+        FROM match_archive a
+        JOIN match_archive_players p ON p.match_id = a.match_id
+        WHERE p.user_id::text = $1
+          AND ($2 = '' OR a.selected_map = $2)
+          AND ($3 = '' OR p.outcome = $3)
+          AND ($4 = 0 OR a.season_id = $4)`
+	args := []interface{}{userID, filter.Map, string(filter.Outcome), filter.SeasonID}
+
+	var total int
+	if err := as.db.QueryRow(`SELECT COUNT(*) `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count matches: %w", err)
+	}
+
+	rows, err := as.db.Query(`SELECT `+archivedMatchColumns+where+`
+        ORDER BY a.completed_at DESC, a.match_id
+        LIMIT $5 OFFSET $6`, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load matches: %w", err)
+	}
+	defer rows.Close()
+
+	matches := []*models.ArchivedMatch{}
+	for rows.Next() {
+		match, err := scanArchivedMatch(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan match: %w", err)
+		}
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := as.loadPlayers(matches); err != nil {
+		return nil, 0, err
+	}
+	return matches, total, nil
+}
+
+// loadPlayers fills in the players of archived matches
+func (as *MatchArchiveService) loadPlayers(matches []*models.ArchivedMatch) error {
+	if len(matches) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*models.ArchivedMatch, len(matches))
+	matchIDs := make([]string, len(matches))
+	for i, match := range matches {
+		byID[match.ID] = match
+		matchIDs[i] = match.ID
+	}
+
+	rows, err := as.db.Query(`
+        SELECT p.match_id, p.user_id::text, COALESCE(p.username, ''), p.team, COALESCE(p.role, ''), p.outcome,
+               p.rating_before, p.rating_after, p.rating_delta,
+               s.user_id IS NOT NULL, COALESCE(s.agent, ''), COALESCE(s.kills, 0), COALESCE(s.deaths, 0),
+               COALESCE(s.assists, 0), COALESCE(s.acs, 0), COALESCE(s.headshot_percent, 0),
+               COALESCE(s.first_bloods, 0), COALESCE(s.submitted_by, ''), COALESCE(s.submitted_at, NOW())
+        FROM match_archive_players p
+        LEFT JOIN match_player_stats s ON s.match_id = p.match_id AND s.user_id = p.user_id
+        WHERE p.match_id = ANY($1)
+        ORDER BY p.match_id, p.team, p.rating_before DESC
+    `, pq.Array(matchIDs))
+	if err != nil {
+		return fmt.Errorf("failed to load match players: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var matchID string
+		var player models.ArchivedMatchPlayer
+		var hasStats bool
+		var stats models.PlayerMatchStats
+		if err := rows.Scan(&matchID, &player.UserID, &player.Username, &player.Team, &player.Role, &player.Outcome,
+			&player.RatingBefore, &player.RatingAfter, &player.RatingDelta,
+			&hasStats, &stats.Agent, &stats.Kills, &stats.Deaths, &stats.Assists, &stats.ACS,
+			&stats.HeadshotPercent, &stats.FirstBloods, &stats.SubmittedBy, &stats.SubmittedAt); err != nil {
+			return fmt.Errorf("failed to scan match player: %w", err)
+		}
+		if hasStats {
+			stats.UserID = player.UserID
+			player.Stats = &stats
+		}
+		if match, ok := byID[matchID]; ok {
+			match.Players = append(match.Players, player)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,357 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"valorant-mobile-web/backend/internal/metrics"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// partyCodePattern accepts Valorant party codes with some slack for formatting
+var partyCodePattern = regexp.MustCompile(`^[A-Z0-9]{4,12}$`)
+
+// LobbyNoShow is a player who never joined the in-game lobby of a match
+// that was abandoned because of it
+type LobbyNoShow struct {
+	MatchID      string
+	Substitution models.Substitution // Without a substitute
+}
+
+// ensureLobby creates the in-game lobby of a match once both teams are
+// complete and starts the deadline for players to join it. The captain of team 1
+// hosts by default. Must be called with mrs.mutex held.
+func (mrs *MatchRoomService) ensureLobby(match *models.Match, now time.Time) (*models.MatchLobby, error) {
+	if match.Lobby != nil {
+		return match.Lobby, nil
+	}
+
+	switch match.Status {
+	case models.MatchStatusPending, models.MatchStatusCancelled, models.MatchStatusCompleted:
+		return nil, fmt.Errorf("a %s match has no lobby", match.Status)
+	case models.MatchStatusTeamDraft:
+		return nil, fmt.Errorf("teams are still being drafted")
+	}
+	if len(match.Team1) == 0 || len(match.Team2) == 0 || len(match.Team1)+len(match.Team2) < len(match.Players) {
+		return nil, fmt.Errorf("teams have not been set")
+	}
+
+	host := match.Captain1
+	if host == "" {
+		host = match.Team1[0]
+	}
+	deadline := now.Add(mrs.options.LobbyJoinTimeout)
+	match.Lobby = &models.MatchLobby{
+		HostID:       host,
+		JoinDeadline: &deadline,
+		CheckIns:     make(map[string]time.Time),
+	}
+	match.UpdatedAt = now
+	mrs.logger.Info("lobby opened", "match_id", match.ID, "host_id", host, "join_deadline", deadline)
+	return match.Lobby, nil
+}
+
+// isMatchPlayer reports whether userID plays in match
+func isMatchPlayer(match *models.Match, userID string) bool {
+	for _, player := range match.Players {
+		if player.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLobby returns a copy of a match's in-game lobby, including the party
+// code, for one of its players
+func (mrs *MatchRoomService) GetLobby(matchID, userID string) (_ models.MatchLobby, err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return models.MatchLobby{}, err
+	}
+	defer mrs.mutex.Unlock(&err)
+
+	match, exists := mrs.rooms[matchID]
+	if !exists {
+		return models.MatchLobby{}, fmt.Errorf("match room not found")
+	}
+	if !isMatchPlayer(match, userID) {
+		return models.MatchLobby{}, fmt.Errorf("only players of this match can see its lobby")
+	}
+
+	lobby, err := mrs.ensureLobby(match, time.Now())
+	if err != nil {
+		return models.MatchLobby{}, err
+	}
+
+	view := *lobby
+	view.CheckIns = make(map[string]time.Time, len(lobby.CheckIns))
+	for userID, at := range lobby.CheckIns {
+		view.CheckIns[userID] = at
+	}
+	view.NoShows = append([]string{}, lobby.NoShows...)
+	return view, nil
+}
+
+// SetLobbyHost hands hosting the in-game lobby to another player. Captains
+// and the current host can do this until the match is under way. A new host
+// creates a new lobby, so the party code and check-ins are cleared, but the
+// join deadline stays.
+func (mrs *MatchRoomService) SetLobbyHost(matchID, requesterID, hostID string) (_ *models.Match, err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer mrs.mutex.Unlock(&err)
+
+	match, exists := mrs.rooms[matchID]
+	if !exists {
+		return nil, fmt.Errorf("match room not found")
+	}
+
+	lobby, err := mrs.ensureLobby(match, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if match.Status == models.MatchStatusOngoing {
+		return nil, fmt.Errorf("every player has already joined the lobby")
+	}
+	if requesterID != match.Captain1 && requesterID != match.Captain2 && requesterID != lobby.HostID {
+		return nil, fmt.Errorf("only captains or the current host can change the host")
+	}
+	if !isMatchPlayer(match, hostID) {
+		return nil, fmt.Errorf("the host must be a player of this match")
+	}
+
+	if hostID != lobby.HostID {
+		lobby.HostID = hostID
+		lobby.PartyCode = ""
+		lobby.CodePostedAt = nil
+		lobby.CheckIns = make(map[string]time.Time)
+	}
+	match.UpdatedAt = time.Now()
+
+	mrs.logger.Info("lobby host set", "match_id", matchID, "host_id", hostID, "set_by", requesterID)
+	return match, nil
+}
+
+// PostPartyCode records the party code of the in-game lobby. Posting again
+// replaces the code, the deadline to join, started when the lobby opened,
+// stays. The host counts as checked in.
+func (mrs *MatchRoomService) PostPartyCode(matchID, hostID, partyCode string) (_ *models.Match, err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer mrs.mutex.Unlock(&err)
+
+	match, exists := mrs.rooms[matchID]
+	if !exists {
+		return nil, fmt.Errorf("match room not found")
+	}
+
+	lobby, err := mrs.ensureLobby(match, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if match.Status == models.MatchStatusOngoing {
+		return nil, fmt.Errorf("every player has already joined the lobby")
+	}
+	if hostID != lobby.HostID {
+		return nil, fmt.Errorf("only the host can post the party code")
+	}
+
+	partyCode = strings.ToUpper(strings.TrimSpace(partyCode))
+	if !partyCodePattern.MatchString(partyCode) {
+		return nil, fmt.Errorf("party code must be 4-12 letters or digits")
+	}
+
+	now := time.Now()
+	lobby.PartyCode = partyCode
+	lobby.CodePostedAt = &now
+	lobby.CheckIns[hostID] = now
+	match.UpdatedAt = now
+
+	mrs.logger.Info("party code posted", "match_id", matchID, "host_id", hostID, "join_deadline", lobby.JoinDeadline)
+	mrs.startIfAllCheckedIn(match)
+	return match, nil
+}
+
+// CheckInLobby records that a player joined the in-game lobby. The match
+// starts once every player has checked in.
+func (mrs *MatchRoomService) CheckInLobby(matchID, userID string) (_ *models.Match, err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer mrs.mutex.Unlock(&err)
+
+	match, exists := mrs.rooms[matchID]
+	if !exists {
+		return nil, fmt.Errorf("match room not found")
+	}
+	if !isMatchPlayer(match, userID) {
+		return nil, fmt.Errorf("player not in match")
+	}
+
+	lobby, err := mrs.ensureLobby(match, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if lobby.PartyCode == "" {
+		return nil, fmt.Errorf("the host has not posted the party code yet")
+	}
+	if _, done := lobby.CheckIns[userID]; done {
+		return match, nil
+	}
+	if lobby.JoinDeadline != nil && time.Now().After(*lobby.JoinDeadline) {
+		return nil, fmt.Errorf("the deadline to join the lobby has passed")
+	}
+
+	lobby.CheckIns[userID] = time.Now()
+	match.UpdatedAt = time.Now()
+
+	mrs.logger.Info("lobby check-in", "match_id", matchID, "user_id", userID,
+		"checked_in", len(lobby.CheckIns), "players", len(match.Players))
+	mrs.startIfAllCheckedIn(match)
+	return match, nil
+}
+
+// startIfAllCheckedIn moves a match to ongoing once every player is in the
+// in-game lobby. Must be called with mrs.mutex held.
+func (mrs *MatchRoomService) startIfAllCheckedIn(match *models.Match) {
+	for _, player := range match.Players {
+		if _, ok := match.Lobby.CheckIns[player.UserID]; !ok {
+			return
+		}
+	}
+
+	match.Status = models.MatchStatusOngoing
+	match.UpdatedAt = time.Now()
+	mrs.logger.Info("match started, every player joined the lobby", "match_id", match.ID)
+}
+
+// ExpireLobbyCheckIns opens the lobby of every match whose teams are set and
+// handles every lobby whose join deadline has passed without all players
+// checking in. The missing players, or only the host if they never posted
+// the party code, are recorded as no-shows and substitutes are offered their
+// spots. If no substitutes are available, or the deadline is missed again,
+// the match is abandoned and the players who did show up go back to the
+// queue. Returns the no-shows of abandoned matches, for their abandons to be
+// recorded.
+func (mrs *MatchRoomService) ExpireLobbyCheckIns(now time.Time) (noShows []LobbyNoShow) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return nil
+	}
+	var err error
+	defer func() {
+		mrs.mutex.Unlock(&err)
+		// The abandons were undone, so must not be recorded
+		if err != nil {
+			noShows = nil
+		}
+	}()
+
+	for _, match := range mrs.rooms {
+		if match.Lobby == nil && match.Status != models.MatchStatusOngoing && match.Status != models.MatchStatusReporting {
+			// Not an error, the teams may not be set yet
+			mrs.ensureLobby(match, now)
+		}
+
+		lobby := match.Lobby
+		if lobby == nil || lobby.JoinDeadline == nil || match.Status == models.MatchStatusOngoing ||
+			match.Status == models.MatchStatusCancelled || match.Status == models.MatchStatusCompleted ||
+			match.Status == models.MatchStatusReporting ||
+			now.Before(*lobby.JoinDeadline) || len(match.SubstituteOffers) > 0 {
+			continue
+		}
+
+		lobby.NoShows = nil
+		for _, player := range match.Players {
+			if _, ok := lobby.CheckIns[player.UserID]; ok {
+				continue
+			}
+			// Without a party code nobody else could join
+			if lobby.PartyCode == "" && player.UserID != lobby.HostID {
+				continue
+			}
+			lobby.NoShows = append(lobby.NoShows, player.UserID)
+		}
+		if len(lobby.NoShows) == 0 {
+			mrs.startIfAllCheckedIn(match)
+			continue
+		}
+
+		if !lobby.SubstitutesRequested && mrs.offerNoShowSubstitutes(match, now) {
+			continue
+		}
+
+		noShows = append(noShows, mrs.abandonMatch(match, now)...)
+	}
+
+	return noShows
+}
+
+// offerNoShowSubstitutes offers a substitute for every no-show and gives the
+// lobby a new deadline. Returns false, without offering anything, when there
+// are not enough substitutes. Must be called with mrs.mutex held.
+func (mrs *MatchRoomService) offerNoShowSubstitutes(match *models.Match, now time.Time) bool {
+	for _, userID := range match.Lobby.NoShows {
+		if err := mrs.offerSubstitute(match, userID, ""); err != nil {
+			match.SubstituteOffers = nil
+			return false
+		}
+	}
+
+	deadline := now.Add(mrs.options.SubstituteOfferTimeout + mrs.options.LobbyJoinTimeout)
+	match.Lobby.JoinDeadline = &deadline
+	match.Lobby.SubstitutesRequested = true
+	match.UpdatedAt = now
+
+	mrs.logger.Info("substitutes offered for lobby no-shows", "match_id", match.ID, "no_shows", match.Lobby.NoShows)
+	return true
+}
+
+// abandonMatch cancels a match whose lobby has no-shows, returns the
+// players who checked in to the queue and returns the no-shows. Must be
+// called with mrs.mutex held.
+func (mrs *MatchRoomService) abandonMatch(match *models.Match, now time.Time) []LobbyNoShow {
+	match.Status = models.MatchStatusCancelled
+	match.UpdatedAt = now
+	metrics.Matches.Inc("cancelled", "lobby_no_show")
+
+	mrs.logger.Info("match abandoned", "match_id", match.ID, "no_shows", match.Lobby.NoShows)
+
+	var noShows []LobbyNoShow
+	for _, player := range match.Players {
+		if slices.Contains(match.Lobby.NoShows, player.UserID) {
+			team := models.MatchWinnerTeam1
+			if slices.Contains(match.Team2, player.UserID) {
+				team = models.MatchWinnerTeam2
+			}
+			noShows = append(noShows, LobbyNoShow{
+				MatchID: match.ID,
+				Substitution: models.Substitution{
+					LeaverID:       player.UserID,
+					LeaverUsername: player.Username,
+					Team:           team,
+					SubstitutedAt:  now,
+				},
+			})
+			continue
+		}
+		if _, ok := match.Lobby.CheckIns[player.UserID]; !ok {
+			continue
+		}
+		err := mrs.queueService.joinQueueEntry(models.QueueEntry{
+			UserID:      player.UserID,
+			Username:    player.Username,
+			ELO:         player.ELO,
+			PartyID:     player.PartyID,
+			Provisional: player.Provisional,
+			Tier:        player.Tier,
+			RiotID:      player.RiotID,
+		}, &mrs.mutex)
+		if err != nil {
+			mrs.logger.Warn("could not return player to queue", "match_id", match.ID, "user_id", player.UserID, "error", err)
+		}
+	}
+	return noShows
+}
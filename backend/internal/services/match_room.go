@@ -1,85 +1,105 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"math/rand"
-	"sync"
+	"sort"
 	"time"
+	"valorant-mobile-web/backend/internal/logging"
+	"valorant-mobile-web/backend/internal/metrics"
 	"valorant-mobile-web/backend/internal/models"
 )
 
+// DefaultAcceptTimeout is how long players have to accept a match they were
+// put in
+const DefaultAcceptTimeout = 15 * time.Second
+
+// DefaultLobbyJoinTimeout is how long players have to join the in-game lobby
+// once it opens, when the teams are set
+const DefaultLobbyJoinTimeout = 5 * time.Minute
+
+// DefaultSubstituteOfferTimeout is how long a queued player has to accept
+// replacing a leaver before the next candidate is asked
+const DefaultSubstituteOfferTimeout = 30 * time.Second
+
+// MatchRoomOptions configures match rooms
+type MatchRoomOptions struct {
+	AcceptTimeout          time.Duration
+	LobbyJoinTimeout       time.Duration
+	SubstituteOfferTimeout time.Duration
+}
+
 type MatchRoomService struct {
 	rooms        map[string]*models.Match
-	mutex        sync.RWMutex
+	mutex        sharedMutex
 	queueService *QueueService
+	options      MatchRoomOptions
+	logger       *slog.Logger
 }
 
 func NewMatchRoomService() *MatchRoomService {
 	return &MatchRoomService{
 		rooms:        make(map[string]*models.Match),
-		queueService: NewQueueService(),
+		queueService: NewQueueService(logging.Component("queue")),
+		options: MatchRoomOptions{
+			AcceptTimeout:          DefaultAcceptTimeout,
+			LobbyJoinTimeout:       DefaultLobbyJoinTimeout,
+			SubstituteOfferTimeout: DefaultSubstituteOfferTimeout,
+		},
+		logger: logging.Component("match_room"),
 	}
 }
 
 // NewMatchRoomServiceWithQueue creates a MatchRoomService with a shared QueueService instance
-func NewMatchRoomServiceWithQueue(queueService *QueueService) *MatchRoomService {
+func NewMatchRoomServiceWithQueue(queueService *QueueService, options MatchRoomOptions, logger *slog.Logger) *MatchRoomService {
+	if options.LobbyJoinTimeout <= 0 {
+		options.LobbyJoinTimeout = DefaultLobbyJoinTimeout
+	}
+	if options.SubstituteOfferTimeout <= 0 {
+		options.SubstituteOfferTimeout = DefaultSubstituteOfferTimeout
+	}
 	return &MatchRoomService{
 		rooms:        make(map[string]*models.Match),
 		queueService: queueService,
+		options:      options,
+		logger:       logger,
 	}
 }
 
 // CreateMatchRoom creates a new match room when queue is full
-func (mrs *MatchRoomService) CreateMatchRoom() (*models.Match, error) {
-	mrs.mutex.Lock()
-	defer mrs.mutex.Unlock()
-
-	fmt.Printf("=== MATCH ROOM SERVICE: Starting CreateMatchRoom ===\n")
-
-	// Get players from queue
-	players, err := mrs.queueService.GetQueuedPlayers(2) // TEMPORARY: Changed from 10 to 2
-	if err != nil {
-		fmt.Printf("ERROR getting queued players: %v\n", err)
+func (mrs *MatchRoomService) CreateMatchRoom() (_ *models.Match, err error) {
+	if err := mrs.mutex.Lock(); err != nil {
 		return nil, err
 	}
+	defer mrs.mutex.Unlock(&err)
 
-	fmt.Printf("Retrieved %d players from queue\n", len(players))
-
-	if len(players) < 2 { // TEMPORARY: Changed from 10 to 2
-		fmt.Printf("ERROR: Not enough players. Need 2, have %d\n", len(players))
-		return nil, fmt.Errorf("not enough players in queue")
-	}
-
-	// CRITICAL: Remove players from queue IMMEDIATELY to prevent race conditions
-	playerIDs := make([]string, len(players))
-	for i, player := range players {
-		playerIDs[i] = player.UserID
-	}
-
-	fmt.Printf("Removing players from queue immediately to prevent race conditions...\n")
-	err = mrs.queueService.RemovePlayersFromQueue(playerIDs)
+	// Take players from the queue in one step so no other request, or
+	// other instance, can put them in a second match
+	players, err := mrs.queueService.takeMatchPlayers(2, &mrs.mutex) // TEMPORARY: Changed from 10 to 2
 	if err != nil {
-		fmt.Printf("ERROR removing players from queue: %v\n", err)
-		return nil, fmt.Errorf("failed to remove players from queue: %v", err)
+		mrs.logger.Warn("not enough players to create a match room", "needed", 2, "error", err)
+		return nil, err
 	}
-	fmt.Printf("Players removed from queue successfully\n")
-
-	fmt.Printf("Creating match room for %d players...\n", len(players))
 
 	// Generate unique match ID
 	matchID := fmt.Sprintf("match-%d-%d", time.Now().Unix(), rand.Intn(10000))
-	fmt.Printf("Generated match ID: %s\n", matchID)
 
 	// Convert QueueEntry to MatchPlayer
 	matchPlayers := make([]models.MatchPlayer, len(players))
 	for i, player := range players {
 		matchPlayers[i] = models.MatchPlayer{
-			UserID:   player.UserID,
-			Username: player.Username,
-			ELO:      player.ELO,
-			Accepted: false, // Initially all players need to accept
-			Team:     "",
-			Role:     "",
+			UserID:      player.UserID,
+			Username:    player.Username,
+			ELO:         player.ELO,
+			PartyID:     player.PartyID,
+			Provisional: player.Provisional,
+			Tier:        player.Tier,
+			RiotID:      player.RiotID,
+			Accepted:    false, // Initially all players need to accept
+			Team:        "",
+			Role:        "",
 		}
 	}
 
@@ -99,36 +119,60 @@ func (mrs *MatchRoomService) CreateMatchRoom() (*models.Match, error) {
 		BannedMaps:             []string{},
 		Winner:                 nil,
 		StartTime:              time.Now(),
-		ExpireTime:             time.Now().Add(15 * time.Second), // 15 seconds to accept
+		ExpireTime:             time.Now().Add(mrs.options.AcceptTimeout),
 		CreatedAt:              time.Now(),
 		UpdatedAt:              time.Now(),
 	}
 
-	fmt.Printf("Match object created successfully\n")
-
 	// Store match room
 	mrs.rooms[matchID] = match
-	fmt.Printf("Match stored in rooms map\n")
+	metrics.Matches.Inc("created", "queue")
 
-	fmt.Printf("MATCH ROOM CREATED: %s with %d players\n", matchID, len(players))
+	mrs.logger.Info("match room created", "match_id", matchID, "players", len(players))
 	return match, nil
 }
 
 // UpdateMatchRoom updates an existing match room
-func (mrs *MatchRoomService) UpdateMatchRoom(match *models.Match) error {
-	mrs.mutex.Lock()
-	defer mrs.mutex.Unlock()
+func (mrs *MatchRoomService) UpdateMatchRoom(match *models.Match) (err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return err
+	}
+	defer mrs.mutex.Unlock(&err)
 
 	match.UpdatedAt = time.Now()
 	mrs.rooms[match.ID] = match
 
 	acceptedCount := mrs.countAcceptedPlayers(match)
-	fmt.Printf("MATCH UPDATED: %s - Status: %s, Players accepted: %d/%d\n",
-		match.ID, match.Status, acceptedCount, len(match.Players))
+	mrs.logger.Debug("match room updated", "match_id", match.ID, "status", match.Status,
+		"accepted", acceptedCount, "players", len(match.Players))
 
 	return nil
 }
 
+// UpdateMatch applies update to match room matchID while holding the lock,
+// so the change is made to the latest copy of the room and, once the rooms
+// are shared, reaches every instance. Changes to rooms returned by
+// GetMatchRoom are not shared.
+func (mrs *MatchRoomService) UpdateMatch(matchID string, update func(match *models.Match) error) (_ *models.Match, err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer mrs.mutex.Unlock(&err)
+
+	match, exists := mrs.rooms[matchID]
+	if !exists {
+		return nil, fmt.Errorf("match room not found")
+	}
+	if err := update(match); err != nil {
+		return match, err
+	}
+	match.UpdatedAt = time.Now()
+
+	mrs.logger.Debug("match room updated", "match_id", match.ID, "status", match.Status,
+		"accepted", mrs.countAcceptedPlayers(match), "players", len(match.Players))
+	return match, nil
+}
+
 // countAcceptedPlayers counts how many players have accepted the match
 func (mrs *MatchRoomService) countAcceptedPlayers(match *models.Match) int {
 	count := 0
@@ -154,9 +198,11 @@ func (mrs *MatchRoomService) GetMatchRoom(matchID string) (*models.Match, error)
 }
 
 // SetCaptainSelectionMethod sets how captains will be selected
-func (mrs *MatchRoomService) SetCaptainSelectionMethod(matchID string, method models.CaptainSelectionMethod) error {
-	mrs.mutex.Lock()
-	defer mrs.mutex.Unlock()
+func (mrs *MatchRoomService) SetCaptainSelectionMethod(matchID string, method models.CaptainSelectionMethod, rules models.CaptainVotingRules) (err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return err
+	}
+	defer mrs.mutex.Unlock(&err)
 
 	match, exists := mrs.rooms[matchID]
 	if !exists {
@@ -169,19 +215,50 @@ func (mrs *MatchRoomService) SetCaptainSelectionMethod(matchID string, method mo
 	if method == models.CaptainSelectionRandom {
 		// Randomly select 2 captains
 		return mrs.selectRandomCaptains(match)
+	} else if method == models.CaptainSelectionAuto {
+		// Skip the draft entirely, teams come straight from ELO
+		return mrs.autoBalanceTeams(match)
 	} else if method == models.CaptainSelectionVoting {
+		if rules.DurationSeconds <= 0 {
+			rules.DurationSeconds = models.DefaultCaptainVotingRules.DurationSeconds
+		}
+
 		// Start voting phase
 		match.Status = models.MatchStatusCaptainVoting
-		// All players are potential captain candidates
-		for _, player := range match.Players {
-			match.CaptainCandidates = append(match.CaptainCandidates, player.UserID)
-		}
+		match.CaptainVotingRules = rules
+		match.CaptainVotingDeadline = time.Now().Add(time.Duration(rules.DurationSeconds) * time.Second)
+		match.CaptainVoteSeed = time.Now().UnixNano()
+		match.CaptainVotes = make(map[string]string)
+		match.CaptainCandidates = eligibleCaptainCandidates(match.Players, rules.MaxCandidates)
 	}
 
-	fmt.Printf("CAPTAIN SELECTION METHOD SET: %s for match %s\n", method, matchID)
+	mrs.logger.Info("captain selection method set", "match_id", matchID, "method", method)
 	return nil
 }
 
+// eligibleCaptainCandidates returns the players allowed to receive captain votes.
+// When maxCandidates is set only the top-N players by ELO are eligible.
+func eligibleCaptainCandidates(players []models.MatchPlayer, maxCandidates int) []string {
+	sorted := make([]models.MatchPlayer, len(players))
+	copy(sorted, players)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].ELO != sorted[j].ELO {
+			return sorted[i].ELO > sorted[j].ELO
+		}
+		return sorted[i].UserID < sorted[j].UserID
+	})
+
+	if maxCandidates > 0 && maxCandidates < len(sorted) {
+		sorted = sorted[:maxCandidates]
+	}
+
+	candidates := make([]string, len(sorted))
+	for i, player := range sorted {
+		candidates[i] = player.UserID
+	}
+	return candidates
+}
+
 // selectRandomCaptains randomly selects 2 captains from the players
 func (mrs *MatchRoomService) selectRandomCaptains(match *models.Match) error {
 	if len(match.Players) < 2 {
@@ -202,15 +279,19 @@ func (mrs *MatchRoomService) selectRandomCaptains(match *models.Match) error {
 	match.Status = models.MatchStatusTeamDraft
 	match.UpdatedAt = time.Now()
 
-	fmt.Printf("RANDOM CAPTAINS SELECTED: %s and %s for match %s\n",
-		match.Captain1, match.Captain2, match.ID)
+	mrs.logger.Info("random captains selected", "match_id", match.ID,
+		"captain1", match.Captain1, "captain2", match.Captain2)
 	return nil
 }
 
-// VoteForCaptain allows a player to vote for a captain
-func (mrs *MatchRoomService) VoteForCaptain(matchID, voterID, candidateID string) error {
-	mrs.mutex.Lock()
-	defer mrs.mutex.Unlock()
+// VoteForCaptain allows a player to vote for a captain. Voting again before
+// the deadline replaces the player's previous vote. Captains are only
+// selected once the deadline passes.
+func (mrs *MatchRoomService) VoteForCaptain(matchID, voterID, candidateID string) (err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return err
+	}
+	defer mrs.mutex.Unlock(&err)
 
 	match, exists := mrs.rooms[matchID]
 	if !exists {
@@ -221,6 +302,14 @@ func (mrs *MatchRoomService) VoteForCaptain(matchID, voterID, candidateID string
 		return fmt.Errorf("not in captain voting phase")
 	}
 
+	// Votes arriving after the deadline close the vote instead of being counted
+	if time.Now().After(match.CaptainVotingDeadline) {
+		if err := mrs.finalizeCaptainVoting(match); err != nil {
+			return err
+		}
+		return fmt.Errorf("captain voting has closed")
+	}
+
 	// Verify voter is in the match
 	voterInMatch := false
 	for _, player := range match.Players {
@@ -233,6 +322,10 @@ func (mrs *MatchRoomService) VoteForCaptain(matchID, voterID, candidateID string
 		return fmt.Errorf("voter not in match")
 	}
 
+	if voterID == candidateID && !match.CaptainVotingRules.AllowSelfVote {
+		return fmt.Errorf("self votes are not allowed in this match")
+	}
+
 	// Verify candidate is valid
 	candidateValid := false
 	for _, candidate := range match.CaptainCandidates {
@@ -246,66 +339,284 @@ func (mrs *MatchRoomService) VoteForCaptain(matchID, voterID, candidateID string
 	}
 
 	// Record vote
+	previous, changed := match.CaptainVotes[voterID]
 	match.CaptainVotes[voterID] = candidateID
 	match.UpdatedAt = time.Now()
 
-	fmt.Printf("CAPTAIN VOTE: %s voted for %s in match %s\n", voterID, candidateID, matchID)
-
-	// Check if all players have voted
-	if len(match.CaptainVotes) == len(match.Players) {
-		return mrs.finalizeCaptainVoting(match)
+	if changed {
+		mrs.logger.Info("captain vote changed", "match_id", matchID, "voter_id", voterID,
+			"previous_candidate_id", previous, "candidate_id", candidateID)
+	} else {
+		mrs.logger.Info("captain vote", "match_id", matchID, "voter_id", voterID, "candidate_id", candidateID)
 	}
 
+	// Voting stays open until the deadline, even once every player voted,
+	// so votes can still be changed; CloseExpiredCaptainVotes closes it
 	return nil
 }
 
-// finalizeCaptainVoting counts votes and selects captains
+// CloseExpiredCaptainVotes finalizes every captain vote whose deadline has
+// passed, ignoring the players who didn't vote. Returns how many were closed.
+func (mrs *MatchRoomService) CloseExpiredCaptainVotes() (closed int) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return 0
+	}
+	var err error
+	defer func() {
+		mrs.mutex.Unlock(&err)
+		if err != nil {
+			closed = 0
+		}
+	}()
+
+	now := time.Now()
+	for _, match := range mrs.rooms {
+		if match.Status != models.MatchStatusCaptainVoting || now.Before(match.CaptainVotingDeadline) {
+			continue
+		}
+		if err := mrs.finalizeCaptainVoting(match); err != nil {
+			mrs.logger.Error("could not close captain vote", "match_id", match.ID, "error", err)
+			continue
+		}
+		closed++
+	}
+
+	return closed
+}
+
+// finalizeCaptainVoting counts votes and selects captains. Ties are broken by
+// higher ELO and then by a shuffle driven by match.CaptainVoteSeed, so the
+// outcome can be reproduced from the stored match.
 func (mrs *MatchRoomService) finalizeCaptainVoting(match *models.Match) error {
+	if len(match.CaptainCandidates) < 2 {
+		return fmt.Errorf("not enough captain candidates")
+	}
+
 	// Count votes
 	voteCount := make(map[string]int)
 	for _, candidate := range match.CaptainVotes {
 		voteCount[candidate]++
 	}
 
-	// Find top 2 candidates
-	type candidateVotes struct {
-		userID string
-		votes  int
+	playerELO := make(map[string]int)
+	for _, player := range match.Players {
+		playerELO[player.UserID] = player.ELO
 	}
 
-	var candidates []candidateVotes
-	for userID, votes := range voteCount {
-		candidates = append(candidates, candidateVotes{userID, votes})
+	type candidateVotes struct {
+		userID  string
+		votes   int
+		elo     int
+		tieRank int
 	}
 
-	// Sort by votes (descending)
-	for i := 0; i < len(candidates)-1; i++ {
-		for j := i + 1; j < len(candidates); j++ {
-			if candidates[i].votes < candidates[j].votes {
-				candidates[i], candidates[j] = candidates[j], candidates[i]
-			}
+	// Candidates without votes still take part so the second captain is picked
+	// by the same rules when only one candidate received votes
+	ordered := make([]string, len(match.CaptainCandidates))
+	copy(ordered, match.CaptainCandidates)
+	sort.Strings(ordered)
+
+	rng := rand.New(rand.NewSource(match.CaptainVoteSeed))
+	tieRanks := rng.Perm(len(ordered))
+
+	candidates := make([]candidateVotes, len(ordered))
+	for i, userID := range ordered {
+		candidates[i] = candidateVotes{
+			userID:  userID,
+			votes:   voteCount[userID],
+			elo:     playerELO[userID],
+			tieRank: tieRanks[i],
 		}
 	}
 
-	if len(candidates) >= 2 {
-		match.Captain1 = candidates[0].userID
-		match.Captain2 = candidates[1].userID
-	} else if len(candidates) == 1 {
-		match.Captain1 = candidates[0].userID
-		// Select random second captain
-		for _, player := range match.Players {
-			if player.UserID != match.Captain1 {
-				match.Captain2 = player.UserID
-				break
-			}
+	// Sort by votes, then ELO (descending), then seeded random order
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].votes != candidates[j].votes {
+			return candidates[i].votes > candidates[j].votes
 		}
-	}
+		if candidates[i].elo != candidates[j].elo {
+			return candidates[i].elo > candidates[j].elo
+		}
+		return candidates[i].tieRank < candidates[j].tieRank
+	})
 
+	match.Captain1 = candidates[0].userID
+	match.Captain2 = candidates[1].userID
 	match.Status = models.MatchStatusTeamDraft
 	match.UpdatedAt = time.Now()
 
-	fmt.Printf("CAPTAINS SELECTED BY VOTING: %s and %s for match %s\n",
-		match.Captain1, match.Captain2, match.ID)
+	outcome := "deadline"
+	if len(match.CaptainVotes) == len(match.Players) {
+		outcome = "all_voted"
+	}
+	started := match.CaptainVotingDeadline.Add(-time.Duration(match.CaptainVotingRules.DurationSeconds) * time.Second)
+	metrics.CaptainVoteDuration.Observe(match.UpdatedAt.Sub(started).Seconds(), outcome)
+
+	mrs.logger.Info("captains selected by voting", "match_id", match.ID,
+		"captain1", match.Captain1, "captain1_votes", candidates[0].votes,
+		"captain2", match.Captain2, "captain2_votes", candidates[1].votes,
+		"voters", len(match.CaptainVotes), "players", len(match.Players), "seed", match.CaptainVoteSeed)
+	return nil
+}
+
+// ReportResult records the result of a match reported by one of its captains
+// and moves the match to reporting while its ratings are applied. The
+// caller then calls FinishResult once they are, or ReleaseResult if they
+// couldn't be so the result can be reported again. A match in reporting
+// can't be reported twice, so it is never rated twice. Returns a copy of the
+// match.
+func (mrs *MatchRoomService) ReportResult(matchID, reporterID string, result models.MatchResult) (*models.Match, error) {
+	return mrs.ReportResultOnMap(matchID, reporterID, "", result)
+}
+
+// ReportResultOnMap is ReportResult for a result known to be played on
+// mapName, e.g. imported from Riot, which also records the map. A match that
+// already has a different map is rejected and left as it was.
+func (mrs *MatchRoomService) ReportResultOnMap(matchID, reporterID, mapName string, result models.MatchResult) (_ *models.Match, err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer mrs.mutex.Unlock(&err)
+
+	match, exists := mrs.rooms[matchID]
+	if !exists {
+		return nil, fmt.Errorf("match room not found")
+	}
+
+	switch match.Status {
+	case models.MatchStatusPending, models.MatchStatusCancelled, models.MatchStatusCompleted:
+		return nil, fmt.Errorf("cannot report a result for a %s match", match.Status)
+	case models.MatchStatusReporting:
+		return nil, fmt.Errorf("the result of this match is already being recorded")
+	}
+
+	if len(match.Team1) == 0 || len(match.Team2) == 0 {
+		return nil, fmt.Errorf("teams have not been set")
+	}
+
+	if reporterID != match.Captain1 && reporterID != match.Captain2 {
+		return nil, fmt.Errorf("only captains can report the result")
+	}
+
+	if err := validateRoundScore(result); err != nil {
+		return nil, err
+	}
+
+	if mapName != "" && match.SelectedMap != "" && match.SelectedMap != mapName {
+		return nil, fmt.Errorf("match was played on %s, not %s", match.SelectedMap, mapName)
+	}
+
+	result.ReportedBy = reporterID
+	result.ReportedAt = time.Now()
+	winner := result.Winner
+
+	if mapName != "" {
+		match.SelectedMap = mapName
+	}
+	match.Result = &result
+	match.Winner = &winner
+	match.Status = models.MatchStatusReporting
+	match.UpdatedAt = time.Now()
+
+	mrs.logger.Info("match result reported", "match_id", matchID, "winner", winner,
+		"team1_rounds", result.Team1Rounds, "team2_rounds", result.Team2Rounds, "reported_by", reporterID)
+	return cloneMatch(match)
+}
+
+// FinishResult marks a match whose reported result was rated as completed
+func (mrs *MatchRoomService) FinishResult(matchID string) (err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return err
+	}
+	defer mrs.mutex.Unlock(&err)
+
+	match, exists := mrs.rooms[matchID]
+	if !exists {
+		return fmt.Errorf("match room not found")
+	}
+	if match.Status != models.MatchStatusReporting {
+		return fmt.Errorf("the result of this match is not being recorded")
+	}
+
+	match.Status = models.MatchStatusCompleted
+	match.UpdatedAt = time.Now()
+	return nil
+}
+
+// ReleaseResult takes back a reported result whose ratings could not be
+// applied. Ratings are saved in one transaction, so nothing was rated and
+// the match goes back to ongoing for the captains to report it again.
+func (mrs *MatchRoomService) ReleaseResult(matchID string) (err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return err
+	}
+	defer mrs.mutex.Unlock(&err)
+
+	match, exists := mrs.rooms[matchID]
+	if !exists || match.Status != models.MatchStatusReporting {
+		return nil
+	}
+
+	match.Result = nil
+	match.Winner = nil
+	match.Status = models.MatchStatusOngoing
+	match.UpdatedAt = time.Now()
+	mrs.logger.Info("match result released, it can be reported again", "match_id", matchID)
+	return nil
+}
+
+// cloneMatch returns a deep copy of match, including the lobby's party
+// code, that stays valid once mrs.mutex is released
+func cloneMatch(match *models.Match) (*models.Match, error) {
+	data, err := json.Marshal(savedMatchRoom{Match: match, PartyCode: partyCode(match)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy match: %w", err)
+	}
+	var room savedMatchRoom
+	if err := json.Unmarshal(data, &room); err != nil {
+		return nil, fmt.Errorf("failed to copy match: %w", err)
+	}
+	if room.Match.Lobby != nil {
+		room.Match.Lobby.PartyCode = room.PartyCode
+	}
+	return room.Match, nil
+}
+
+// partyCode returns the party code of a match's lobby, if any
+func partyCode(match *models.Match) string {
+	if match.Lobby == nil {
+		return ""
+	}
+	return match.Lobby.PartyCode
+}
+
+// validateRoundScore checks the round score agrees with the winner. A 0-0
+// score means the rounds were not reported.
+func validateRoundScore(result models.MatchResult) error {
+	if result.Team1Rounds < 0 || result.Team2Rounds < 0 {
+		return fmt.Errorf("round scores cannot be negative")
+	}
+	if result.Team1Rounds == 0 && result.Team2Rounds == 0 {
+		return nil
+	}
+
+	switch result.Winner {
+	case models.MatchWinnerTeam1:
+		if result.Team1Rounds <= result.Team2Rounds {
+			return fmt.Errorf("team1 cannot win with %d-%d", result.Team1Rounds, result.Team2Rounds)
+		}
+	case models.MatchWinnerTeam2:
+		if result.Team2Rounds <= result.Team1Rounds {
+			return fmt.Errorf("team2 cannot win with %d-%d", result.Team1Rounds, result.Team2Rounds)
+		}
+	case models.MatchWinnerTie:
+		if result.Team1Rounds != result.Team2Rounds {
+			return fmt.Errorf("a tie needs equal round scores")
+		}
+	default:
+		return fmt.Errorf("winner must be 'team1', 'team2', or 'tie'")
+	}
+
 	return nil
 }
 
@@ -340,14 +651,104 @@ func (mrs *MatchRoomService) ListActiveRooms() []*models.Match {
 
 // CleanupExpiredRooms removes expired match rooms
 func (mrs *MatchRoomService) CleanupExpiredRooms() {
-	mrs.mutex.Lock()
-	defer mrs.mutex.Unlock()
+	if err := mrs.mutex.Lock(); err != nil {
+		return
+	}
+	var err error
+	defer mrs.mutex.Unlock(&err)
 
 	now := time.Now()
 	for matchID, match := range mrs.rooms {
 		if now.After(match.ExpireTime) {
 			delete(mrs.rooms, matchID)
-			fmt.Printf("EXPIRED MATCH ROOM REMOVED: %s\n", matchID)
+			mrs.logger.Info("expired match room removed", "match_id", matchID)
+		}
+	}
+}
+
+// savedMatchRoom is a match room as saved across restarts. The party code
+// is left out of the match's JSON so it is saved next to it.
+type savedMatchRoom struct {
+	Match     *models.Match `json:"match"`
+	PartyCode string        `json:"party_code,omitempty"`
+}
+
+// Snapshot encodes every active match room
+func (mrs *MatchRoomService) Snapshot() ([]byte, error) {
+	mrs.mutex.RLock()
+	defer mrs.mutex.RUnlock()
+	return mrs.encode()
+}
+
+// encode is Snapshot without locking. Rooms are sorted so unchanged rooms
+// always encode the same.
+func (mrs *MatchRoomService) encode() ([]byte, error) {
+	rooms := make([]savedMatchRoom, 0, len(mrs.rooms))
+	for _, match := range mrs.rooms {
+		rooms = append(rooms, savedMatchRoom{Match: match, PartyCode: partyCode(match)})
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Match.ID < rooms[j].Match.ID })
+	return json.Marshal(rooms)
+}
+
+// decode replaces every match room with a snapshot, without locking
+func (mrs *MatchRoomService) decode(data []byte) error {
+	var rooms []savedMatchRoom
+	if err := json.Unmarshal(data, &rooms); err != nil {
+		return fmt.Errorf("invalid match room snapshot: %w", err)
+	}
+
+	mrs.rooms = make(map[string]*models.Match, len(rooms))
+	for _, room := range rooms {
+		if room.Match == nil || room.Match.ID == "" {
+			continue
+		}
+		if room.Match.Lobby != nil {
+			room.Match.Lobby.PartyCode = room.PartyCode
+		}
+		mrs.rooms[room.Match.ID] = room.Match
+	}
+	return nil
+}
+
+// Share shares the match rooms with every backend instance using
+// coordinator. Each instance then sees every room and its updates, and a
+// match is formed by exactly one instance since CreateMatchRoom holds the
+// shared lock while it takes players from the queue. Must be called before
+// the rooms are used.
+func (mrs *MatchRoomService) Share(coordinator Coordinator) {
+	mrs.mutex.share(coordinator, "match_rooms", mrs.encode, mrs.decode, mrs.logger)
+}
+
+// Restore brings back the match rooms of a snapshot and returns how many
+// were restored. Deadlines that passed while the server was down are
+// handled by the next sweep.
+func (mrs *MatchRoomService) Restore(data []byte) (_ int, err error) {
+	var rooms []savedMatchRoom
+	if err := json.Unmarshal(data, &rooms); err != nil {
+		return 0, fmt.Errorf("invalid match room snapshot: %w", err)
+	}
+
+	if err := mrs.mutex.Lock(); err != nil {
+		return 0, err
+	}
+	defer mrs.mutex.Unlock(&err)
+
+	restored := 0
+	for _, room := range rooms {
+		if room.Match == nil || room.Match.ID == "" {
+			continue
+		}
+		if _, exists := mrs.rooms[room.Match.ID]; exists {
+			continue
 		}
+		if room.Match.Lobby != nil {
+			room.Match.Lobby.PartyCode = room.PartyCode
+		}
+		mrs.rooms[room.Match.ID] = room.Match
+		restored++
 	}
+
+	mrs.logger.Info("match rooms restored", "restored", restored, "rooms", len(mrs.rooms))
+	return restored, nil
 }
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// maxACS is well above any real average combat score and only catches typos
+const maxACS = 1000
+
+// MatchStatsService stores per-player scoreboards of archived matches and
+// aggregates them into career and season stats
+type MatchStatsService struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewMatchStatsService(db *sql.DB, logger *slog.Logger) *MatchStatsService {
+	return &MatchStatsService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+type rosterPlayer struct {
+	team    string
+	captain bool
+}
+
+// SubmitStats stores scoreboard lines for an archived match. Players can
+// submit their own line and captains can submit lines for their whole team.
+// Submitting a line again replaces it. The match was already rated when it
+// was archived, so the lines feed career and season stats but not ratings;
+// only stats from a Riot import are rated.
+func (ss *MatchStatsService) SubmitStats(matchID, submitterID string, stats []models.PlayerMatchStats) ([]models.PlayerMatchStats, error) {
+	if len(stats) == 0 {
+		return nil, fmt.Errorf("no stats submitted")
+	}
+
+	var captain1, captain2 string
+	var totalRounds int
+	err := ss.db.QueryRow(`
+        SELECT COALESCE(captain1, ''), COALESCE(captain2, ''), team1_rounds + team2_rounds
+        FROM match_archive WHERE match_id = $1
+    `, matchID).Scan(&captain1, &captain2, &totalRounds)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("match not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load match: %w", err)
+	}
+
+	roster, err := ss.loadRoster(matchID, captain1, captain2)
+	if err != nil {
+		return nil, err
+	}
+
+	submitter, ok := roster[submitterID]
+	if !ok {
+		return nil, fmt.Errorf("only players of this match can submit stats")
+	}
+
+	seen := make(map[string]bool, len(stats))
+	now := time.Now()
+	for i := range stats {
+		line := &stats[i]
+		player, ok := roster[line.UserID]
+		if !ok {
+			return nil, fmt.Errorf("player %s did not play this match", line.UserID)
+		}
+		if seen[line.UserID] {
+			return nil, fmt.Errorf("player %s has more than one stat line", line.UserID)
+		}
+		seen[line.UserID] = true
+
+		if line.UserID != submitterID && !(submitter.captain && submitter.team == player.team) {
+			return nil, fmt.Errorf("only a captain can submit stats for teammates")
+		}
+
+		if err := validatePlayerStats(line, totalRounds); err != nil {
+			return nil, fmt.Errorf("invalid stats for %s: %w", line.UserID, err)
+		}
+		line.SubmittedBy = submitterID
+		line.SubmittedAt = now
+	}
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start stats transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveMatchStats(tx, matchID, stats); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit stats: %w", err)
+	}
+
+	ss.logger.Info("stats submitted", "match_id", matchID, "lines", len(stats), "submitted_by", submitterID)
+	return stats, nil
+}
+
+func (ss *MatchStatsService) loadRoster(matchID, captain1, captain2 string) (map[string]rosterPlayer, error) {
+	rows, err := ss.db.Query(`SELECT user_id::text, team FROM match_archive_players WHERE match_id = $1`, matchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load roster: %w", err)
+	}
+	defer rows.Close()
+
+	roster := make(map[string]rosterPlayer)
+	for rows.Next() {
+		var userID string
+		var player rosterPlayer
+		if err := rows.Scan(&userID, &player.team); err != nil {
+			return nil, fmt.Errorf("failed to scan roster: %w", err)
+		}
+		player.captain = (player.team == models.MatchWinnerTeam1 && userID == captain1) ||
+			(player.team == models.MatchWinnerTeam2 && userID == captain2)
+		roster[userID] = player
+	}
+	return roster, rows.Err()
+}
+
+// validatePlayerStats checks a scoreboard line is plausible and normalises
+// the agent name. totalRounds is 0 when the round score was not reported.
+func validatePlayerStats(line *models.PlayerMatchStats, totalRounds int) error {
+	if line.Kills < 0 || line.Deaths < 0 || line.Assists < 0 || line.FirstBloods < 0 {
+		return fmt.Errorf("kills, deaths, assists and first bloods cannot be negative")
+	}
+	if line.ACS < 0 || line.ACS > maxACS {
+		return fmt.Errorf("acs must be between 0 and %d", maxACS)
+	}
+	if line.HeadshotPercent < 0 || line.HeadshotPercent > 100 {
+		return fmt.Errorf("headshot_percent must be between 0 and 100")
+	}
+	if line.FirstBloods > line.Kills {
+		return fmt.Errorf("first bloods cannot exceed kills")
+	}
+	if totalRounds > 0 && line.FirstBloods > totalRounds {
+		return fmt.Errorf("first bloods cannot exceed the %d rounds played", totalRounds)
+	}
+
+	line.Agent = strings.ToLower(strings.TrimSpace(line.Agent))
+	if line.Agent == "" {
+		return fmt.Errorf("agent is required")
+	}
+	for _, agent := range models.ValorantAgents {
+		if agent == line.Agent {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown agent %q", line.Agent)
+}
+
+// saveMatchStats upserts scoreboard lines using db so they can be written
+// in the caller's transaction
+func saveMatchStats(db execer, matchID string, stats []models.PlayerMatchStats) error {
+	for _, line := range stats {
+		var submittedBy interface{}
+		if line.SubmittedBy != "" {
+			submittedBy = line.SubmittedBy
+		}
+		submittedAt := line.SubmittedAt
+		if submittedAt.IsZero() {
+			submittedAt = time.Now()
+		}
+
+		_, err := db.Exec(`
+            INSERT INTO match_player_stats (match_id, user_id, agent, kills, deaths, assists, acs,
+                                            headshot_percent, first_bloods, submitted_by, submitted_at)
+            SELECT $1, id, $3, $4, $5, $6, $7, $8, $9, $10, $11 FROM users WHERE id::text = $2
+            ON CONFLICT (match_id, user_id) DO UPDATE SET
+                agent = EXCLUDED.agent, kills = EXCLUDED.kills, deaths = EXCLUDED.deaths,
+                assists = EXCLUDED.assists, acs = EXCLUDED.acs, headshot_percent = EXCLUDED.headshot_percent,
+                first_bloods = EXCLUDED.first_bloods, submitted_by = EXCLUDED.submitted_by,
+                submitted_at = EXCLUDED.submitted_at
+        `, matchID, line.UserID, line.Agent, line.Kills, line.Deaths, line.Assists, line.ACS,
+			line.HeadshotPercent, line.FirstBloods, submittedBy, submittedAt)
+		if err != nil {
+			return fmt.Errorf("failed to save stats for %s: %w", line.UserID, err)
+		}
+	}
+	return nil
+}
+
+// CareerVersion identifies what GetCareerStats currently returns for a
+// player: it changes whenever one of their matches is archived or a stat
+// line of theirs is submitted.
+func (ss *MatchStatsService) CareerVersion(userID string) (string, error) {
+	var archived, lines int
+	var submitted sql.NullTime
+	err := ss.db.QueryRow(`
+        SELECT (SELECT COUNT(*) FROM match_archive_players WHERE user_id::text = $1),
+               COUNT(*), MAX(submitted_at)
+        FROM match_player_stats WHERE user_id::text = $1
+    `, userID).Scan(&archived, &lines, &submitted)
+	if err != nil {
+		return "", fmt.Errorf("failed to load career version: %w", err)
+	}
+	var submittedAt int64
+	if submitted.Valid {
+		submittedAt = submitted.Time.UnixMicro()
+	}
+	return fmt.Sprintf("%d-%d-%d", archived, lines, submittedAt), nil
+}
+
+// GetCareerStats aggregates a player's matches. seasonID 0 covers the whole career.
+func (ss *MatchStatsService) GetCareerStats(userID string, seasonID int) (*models.CareerStats, error) {
+	career := &models.CareerStats{SeasonID: seasonID, Maps: []models.MapStats{}}
+
+	err := ss.db.QueryRow(`
+        SELECT COUNT(*), COALESCE(SUM(s.kills), 0), COALESCE(SUM(s.deaths), 0), COALESCE(SUM(s.assists), 0),
+               COALESCE(AVG(s.acs), 0), COALESCE(AVG(s.headshot_percent), 0), COALESCE(SUM(s.first_bloods), 0)
+        FROM match_player_stats s
+        JOIN match_archive a ON a.match_id = s.match_id
+        WHERE s.user_id::text = $1 AND ($2 = 0 OR a.season_id = $2)
+    `, userID, seasonID).Scan(&career.MatchesWithStats, &career.Kills, &career.Deaths, &career.Assists,
+		&career.AverageACS, &career.HeadshotPercent, &career.FirstBloods)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate stats: %w", err)
+	}
+
+	deaths := career.Deaths
+	if deaths < 1 {
+		deaths = 1
+	}
+	career.KD = math.Round(float64(career.Kills)/float64(deaths)*100) / 100
+	career.AverageACS = math.Round(career.AverageACS*10) / 10
+	career.HeadshotPercent = math.Round(career.HeadshotPercent*10) / 10
+
+	err = ss.db.QueryRow(`
+        SELECT s.agent
+        FROM match_player_stats s
+        JOIN match_archive a ON a.match_id = s.match_id
+        WHERE s.user_id::text = $1 AND ($2 = 0 OR a.season_id = $2) AND s.agent <> ''
+        GROUP BY s.agent
+        ORDER BY COUNT(*) DESC, s.agent
+        LIMIT 1
+    `, userID, seasonID).Scan(&career.MostPlayedAgent)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to load most played agent: %w", err)
+	}
+
+	rows, err := ss.db.Query(`
+        SELECT COALESCE(NULLIF(a.selected_map, ''), 'unknown') AS map, COUNT(*),
+               COUNT(*) FILTER (WHERE p.outcome = 'win'),
+               COUNT(*) FILTER (WHERE p.outcome = 'loss'),
+               COUNT(*) FILTER (WHERE p.outcome = 'draw')
+        FROM match_archive_players p
+        JOIN match_archive a ON a.match_id = p.match_id
+        WHERE p.user_id::text = $1 AND ($2 = 0 OR a.season_id = $2)
+        GROUP BY 1
+        ORDER BY COUNT(*) DESC, 1
+    `, userID, seasonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate map stats: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mapStats models.MapStats
+		if err := rows.Scan(&mapStats.Map, &mapStats.Played, &mapStats.Wins, &mapStats.Losses, &mapStats.Draws); err != nil {
+			return nil, fmt.Errorf("failed to scan map stats: %w", err)
+		}
+		if mapStats.Played > 0 {
+			mapStats.WinRate = math.Round(float64(mapStats.Wins)/float64(mapStats.Played)*10000) / 100
+		}
+		career.MatchesPlayed += mapStats.Played
+		career.Maps = append(career.Maps, mapStats)
+	}
+	return career, rows.Err()
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// NotificationService stores messages for players
+type NotificationService struct {
+	db *sql.DB
+}
+
+func NewNotificationService(db *sql.DB) *NotificationService {
+	return &NotificationService{
+		db: db,
+	}
+}
+
+// Notify stores a notification, using db so it can be part of a caller's transaction
+func (ns *NotificationService) Notify(db execer, userID string, notificationType models.NotificationType, message string, now time.Time) error {
+	_, err := db.Exec(`
+        INSERT INTO notifications (user_id, type, message, created_at)
+        SELECT id, $2, $3, $4 FROM users WHERE id::text = $1
+    `, userID, notificationType, message, now)
+	if err != nil {
+		return fmt.Errorf("failed to notify %s: %w", userID, err)
+	}
+	return nil
+}
+
+// GetUserNotifications returns a player's most recent notifications, newest first
+func (ns *NotificationService) GetUserNotifications(userID string, limit int) ([]models.Notification, error) {
+	rows, err := ns.db.Query(`
+        SELECT id::text, user_id::text, type, message, created_at, read_at
+        FROM notifications
+        WHERE user_id::text = $1
+        ORDER BY created_at DESC
+        LIMIT $2
+    `, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var notification models.Notification
+		var readAt sql.NullTime
+		if err := rows.Scan(&notification.ID, &notification.UserID, &notification.Type,
+			&notification.Message, &notification.CreatedAt, &readAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		if readAt.Valid {
+			notification.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
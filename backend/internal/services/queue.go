@@ -1,29 +1,71 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
-	"sync"
+	"log/slog"
+	"sort"
 	"time"
+	"valorant-mobile-web/backend/internal/metrics"
 	"valorant-mobile-web/backend/internal/models"
 )
 
+// ErrQueueClosed is returned when joining a queue that was closed because
+// the server is shutting down
+var ErrQueueClosed = errors.New("server restarting, please join the queue again in a moment")
+
 type QueueService struct {
 	queue       map[string]*models.QueueEntry
-	mutex       sync.RWMutex
+	substitutes map[string]*models.QueueEntry // Players only waiting to replace leavers
+	mutex       sharedMutex
 	maxPlayers  int
 	isQueueFull bool
+	closed      bool // No new players are accepted by this instance
+	logger      *slog.Logger
 }
 
-func NewQueueService() *QueueService {
+func NewQueueService(logger *slog.Logger) *QueueService {
 	return &QueueService{
-		queue:      make(map[string]*models.QueueEntry),
-		maxPlayers: 2, // TEMPORARY: Changed from 10 to 2 for testing
+		queue:       make(map[string]*models.QueueEntry),
+		substitutes: make(map[string]*models.QueueEntry),
+		maxPlayers:  2, // TEMPORARY: Changed from 10 to 2 for testing
+		logger:      logger,
 	}
 }
 
 func (qs *QueueService) JoinQueue(userID, username string, elo int) error {
-	qs.mutex.Lock()
-	defer qs.mutex.Unlock()
+	return qs.JoinQueueWithParty(userID, username, elo, "")
+}
+
+// JoinQueueWithParty adds a player to the queue as part of a party. Party
+// members are kept on the same team when teams are auto-balanced.
+func (qs *QueueService) JoinQueueWithParty(userID, username string, elo int, partyID string) error {
+	return qs.JoinQueueEntry(models.QueueEntry{
+		UserID:   userID,
+		Username: username,
+		ELO:      elo,
+		PartyID:  partyID,
+	})
+}
+
+// JoinQueueEntry adds a fully described player to the queue. JoinedAt is set here.
+func (qs *QueueService) JoinQueueEntry(entry models.QueueEntry) error {
+	return qs.joinQueueEntry(entry, nil)
+}
+
+// joinQueueEntry is JoinQueueEntry as part of outer's change, see lock
+func (qs *QueueService) joinQueueEntry(entry models.QueueEntry, outer *sharedMutex) (err error) {
+	userID, username, elo := entry.UserID, entry.Username, entry.ELO
+
+	if err := qs.lock(outer); err != nil {
+		return err
+	}
+	defer qs.mutex.Unlock(&err)
+
+	if qs.closed {
+		return ErrQueueClosed
+	}
 
 	// Check if queue is full and a match is being created
 	if qs.isQueueFull {
@@ -42,35 +84,126 @@ func (qs *QueueService) JoinQueue(userID, username string, elo int) error {
 	}
 
 	// Use provided user data instead of mock data
-	qs.queue[userID] = &models.QueueEntry{
-		UserID:   userID,
-		Username: username,
-		ELO:      elo,
-		JoinedAt: time.Now(),
-	}
+	entry.JoinedAt = time.Now()
+	qs.queue[userID] = &entry
 
 	// Check if we've reached the max players
 	if len(qs.queue) >= qs.maxPlayers {
 		qs.isQueueFull = true
 	}
 
-	fmt.Printf("USER JOINED QUEUE: %s (%s) ELO: %d - Queue size: %d/%d, Full: %v\n",
-		username, userID, elo, len(qs.queue), qs.maxPlayers, qs.isQueueFull)
+	qs.logger.Info("user joined queue", "user_id", userID, "username", username, "elo", elo,
+		"provisional", entry.Provisional, "queue_size", len(qs.queue), "max_players", qs.maxPlayers, "full", qs.isQueueFull)
 	return nil
 }
 
-func (qs *QueueService) LeaveQueue(userID string) error {
-	qs.mutex.Lock()
-	defer qs.mutex.Unlock()
+// JoinSubstituteQueue adds a player who only wants to replace players who
+// left a match. Substitutes never count towards forming a new match.
+func (qs *QueueService) JoinSubstituteQueue(entry models.QueueEntry) (err error) {
+	if err := qs.mutex.Lock(); err != nil {
+		return err
+	}
+	defer qs.mutex.Unlock(&err)
+
+	if qs.closed {
+		return ErrQueueClosed
+	}
+	if _, exists := qs.queue[entry.UserID]; exists {
+		return fmt.Errorf("user is already in queue")
+	}
+	if _, exists := qs.substitutes[entry.UserID]; exists {
+		return fmt.Errorf("user is already in the substitute queue")
+	}
+
+	entry.JoinedAt = time.Now()
+	qs.substitutes[entry.UserID] = &entry
+
+	qs.logger.Info("user joined substitute queue", "user_id", entry.UserID, "username", entry.Username,
+		"elo", entry.ELO, "substitutes_waiting", len(qs.substitutes))
+	return nil
+}
+
+func (qs *QueueService) LeaveQueue(userID string) (err error) {
+	if err := qs.mutex.Lock(); err != nil {
+		return err
+	}
+	defer qs.mutex.Unlock(&err)
 
 	if entry, exists := qs.queue[userID]; exists {
 		delete(qs.queue, userID)
-		fmt.Printf("USER LEFT QUEUE: %s (%s) - Queue size: %d\n", entry.Username, userID, len(qs.queue))
+		metrics.QueueWait.Observe(time.Since(entry.JoinedAt).Seconds(), metrics.QueueMain, "left")
+		qs.logger.Info("user left queue", "user_id", userID, "username", entry.Username, "queue_size", len(qs.queue))
+	}
+	if entry, exists := qs.substitutes[userID]; exists {
+		delete(qs.substitutes, userID)
+		metrics.QueueWait.Observe(time.Since(entry.JoinedAt).Seconds(), metrics.QueueSubstitute, "left")
+		qs.logger.Info("user left substitute queue", "user_id", userID, "username", entry.Username)
 	}
 
 	return nil
 }
 
+// FindSubstitute returns the waiting player whose ELO is closest to elo,
+// looking at the substitute queue and the regular queue. Substitutes win
+// ties, then whoever waited longest. Players in exclude are skipped.
+func (qs *QueueService) FindSubstitute(elo int, exclude map[string]bool) (models.QueueEntry, bool) {
+	qs.mutex.RLock()
+	defer qs.mutex.RUnlock()
+
+	var best *models.QueueEntry
+	bestDistance, bestIsSub := 0, false
+	consider := func(entries map[string]*models.QueueEntry, isSub bool) {
+		for _, entry := range entries {
+			if exclude[entry.UserID] {
+				continue
+			}
+			distance := entry.ELO - elo
+			if distance < 0 {
+				distance = -distance
+			}
+			better := best == nil || distance < bestDistance ||
+				(distance == bestDistance && isSub && !bestIsSub) ||
+				(distance == bestDistance && isSub == bestIsSub && entry.JoinedAt.Before(best.JoinedAt))
+			if better {
+				best, bestDistance, bestIsSub = entry, distance, isSub
+			}
+		}
+	}
+	consider(qs.substitutes, true)
+	consider(qs.queue, false)
+
+	if best == nil {
+		return models.QueueEntry{}, false
+	}
+	return *best, true
+}
+
+// TakeSubstitute removes a player who agreed to substitute from whichever queue they are in
+func (qs *QueueService) TakeSubstitute(userID string) (models.QueueEntry, error) {
+	return qs.takeSubstitute(userID, nil)
+}
+
+// takeSubstitute is TakeSubstitute as part of outer's change, see lock
+func (qs *QueueService) takeSubstitute(userID string, outer *sharedMutex) (_ models.QueueEntry, err error) {
+	if err := qs.lock(outer); err != nil {
+		return models.QueueEntry{}, err
+	}
+	defer qs.mutex.Unlock(&err)
+
+	if entry, exists := qs.substitutes[userID]; exists {
+		delete(qs.substitutes, userID)
+		metrics.QueueWait.Observe(time.Since(entry.JoinedAt).Seconds(), metrics.QueueSubstitute, "substituted")
+		return *entry, nil
+	}
+	if entry, exists := qs.queue[userID]; exists {
+		delete(qs.queue, userID)
+		qs.isQueueFull = len(qs.queue) >= qs.maxPlayers
+		metrics.QueueWait.Observe(time.Since(entry.JoinedAt).Seconds(), metrics.QueueMain, "substituted")
+		return *entry, nil
+	}
+	return models.QueueEntry{}, fmt.Errorf("player is no longer queued")
+}
+
 func (qs *QueueService) GetQueueStatus() (*models.QueueStatus, error) {
 	qs.mutex.RLock()
 	defer qs.mutex.RUnlock()
@@ -92,71 +225,301 @@ func (qs *QueueService) GetQueueStatus() (*models.QueueStatus, error) {
 		estimatedWait = fmt.Sprintf("%d more players needed", qs.maxPlayers-playersCount)
 	}
 
-	fmt.Printf("QUEUE STATUS: %d/%d players, canStart: %v, isFull: %v\n",
-		playersCount, qs.maxPlayers, canStart, qs.isQueueFull)
+	qs.logger.Debug("queue status", "players", playersCount, "max_players", qs.maxPlayers,
+		"can_start", canStart, "full", qs.isQueueFull)
 
 	return &models.QueueStatus{
-		PlayersInQueue:    playersCount,
-		CurrentPlayers:    playersCount, // Add for frontend compatibility
-		Players:           players,
-		EstimatedWait:     estimatedWait,
-		CanStartMatch:     canStart,
-		MaxPlayers:        qs.maxPlayers,
-		IsQueueFull:       qs.isQueueFull,
-		ShouldCreateMatch: canStart, // Indicates frontend should create match room
+		PlayersInQueue:     playersCount,
+		CurrentPlayers:     playersCount, // Add for frontend compatibility
+		Players:            players,
+		EstimatedWait:      estimatedWait,
+		CanStartMatch:      canStart,
+		MaxPlayers:         qs.maxPlayers,
+		IsQueueFull:        qs.isQueueFull,
+		ShouldCreateMatch:  canStart, // Indicates frontend should create match room
+		SubstitutesWaiting: len(qs.substitutes),
 	}, nil
 }
 
+// Depths returns how many players wait in the regular and the substitute queue
+func (qs *QueueService) Depths() (queued, substitutes int) {
+	qs.mutex.RLock()
+	defer qs.mutex.RUnlock()
+	return len(qs.queue), len(qs.substitutes)
+}
+
 func (qs *QueueService) CanStartMatch() bool {
 	qs.mutex.RLock()
 	defer qs.mutex.RUnlock()
 	return len(qs.queue) >= qs.maxPlayers
 }
 
+// GetQueuedPlayers picks up to limit players for the next match, see selectMatchPlayers
 func (qs *QueueService) GetQueuedPlayers(limit int) ([]models.QueueEntry, error) {
 	qs.mutex.RLock()
 	defer qs.mutex.RUnlock()
 
-	var players []models.QueueEntry
-	count := 0
+	entries := make([]models.QueueEntry, 0, len(qs.queue))
 	for _, entry := range qs.queue {
-		if count >= limit {
-			break
-		}
-		players = append(players, *entry)
-		count++
+		entries = append(entries, *entry)
 	}
 
-	fmt.Printf("GETTING PLAYERS FOR MATCH: Found %d players\n", len(players))
+	players := selectMatchPlayers(entries, limit)
+
+	qs.logger.Debug("picked players for match", "players", len(players))
 	return players, nil
 }
 
-func (qs *QueueService) RemovePlayersFromQueue(userIDs []string) error {
-	qs.mutex.Lock()
-	defer qs.mutex.Unlock()
+func (qs *QueueService) RemovePlayersFromQueue(userIDs []string) (err error) {
+	if err := qs.mutex.Lock(); err != nil {
+		return err
+	}
+	defer qs.mutex.Unlock(&err)
 
 	for _, userID := range userIDs {
 		if entry, exists := qs.queue[userID]; exists {
 			delete(qs.queue, userID)
-			fmt.Printf("REMOVED FROM QUEUE FOR MATCH: %s (%s)\n", entry.Username, userID)
+			metrics.QueueWait.Observe(time.Since(entry.JoinedAt).Seconds(), metrics.QueueMain, "matched")
+			qs.logger.Info("removed from queue for match", "user_id", userID, "username", entry.Username)
 		}
 	}
 
 	// Reset queue state after match creation
 	qs.isQueueFull = false
-	fmt.Printf("QUEUE RESET: Queue size: %d/%d, Full: %v\n", len(qs.queue), qs.maxPlayers, qs.isQueueFull)
+	qs.logger.Debug("queue reset", "queue_size", len(qs.queue), "max_players", qs.maxPlayers)
 
 	return nil
 }
 
+// TakeMatchPlayers picks limit players for the next match, see
+// selectMatchPlayers, and removes them from the queue. Nobody is removed if
+// fewer than limit players are waiting.
+func (qs *QueueService) TakeMatchPlayers(limit int) ([]models.QueueEntry, error) {
+	return qs.takeMatchPlayers(limit, nil)
+}
+
+// takeMatchPlayers is TakeMatchPlayers as part of outer's change, see lock
+func (qs *QueueService) takeMatchPlayers(limit int, outer *sharedMutex) (_ []models.QueueEntry, err error) {
+	if err := qs.lock(outer); err != nil {
+		return nil, err
+	}
+	defer qs.mutex.Unlock(&err)
+
+	if len(qs.queue) < limit {
+		return nil, fmt.Errorf("not enough players in queue")
+	}
+
+	entries := make([]models.QueueEntry, 0, len(qs.queue))
+	for _, entry := range qs.queue {
+		entries = append(entries, *entry)
+	}
+	players := selectMatchPlayers(entries, limit)
+
+	for _, player := range players {
+		delete(qs.queue, player.UserID)
+		metrics.QueueWait.Observe(time.Since(player.JoinedAt).Seconds(), metrics.QueueMain, "matched")
+		qs.logger.Info("removed from queue for match", "user_id", player.UserID, "username", player.Username)
+	}
+
+	// Reset queue state after match creation
+	qs.isQueueFull = false
+	qs.logger.Debug("queue reset", "queue_size", len(qs.queue), "max_players", qs.maxPlayers)
+
+	return players, nil
+}
+
+// ELO distance within which the matchmaker prefers to group players. Provisional
+// ratings are unreliable so they get a wider band.
+const (
+	matchmakingELOBand            = 200
+	matchmakingProvisionalELOBand = 400
+)
+
+// selectMatchPlayers builds a match around the player who has waited the
+// longest. Players within the anchor's ELO band who share its provisional
+// status come first, then anyone else within the band, then the closest ELOs
+// so the queue never stalls.
+func selectMatchPlayers(entries []models.QueueEntry, limit int) []models.QueueEntry {
+	if len(entries) == 0 || limit <= 0 {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].JoinedAt.Before(entries[j].JoinedAt)
+	})
+
+	anchor := entries[0]
+	band := matchmakingELOBand
+	if anchor.Provisional {
+		band = matchmakingProvisionalELOBand
+	}
+
+	distance := func(entry models.QueueEntry) int {
+		d := entry.ELO - anchor.ELO
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	preference := func(entry models.QueueEntry) int {
+		inBand := distance(entry) <= band
+		switch {
+		case inBand && entry.Provisional == anchor.Provisional:
+			return 0
+		case inBand:
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	rest := entries[1:]
+	sort.SliceStable(rest, func(i, j int) bool {
+		pi, pj := preference(rest[i]), preference(rest[j])
+		if pi != pj {
+			return pi < pj
+		}
+		if pi == 2 {
+			return distance(rest[i]) < distance(rest[j])
+		}
+		return false // Keep waiting time order inside the band
+	})
+
+	if limit > len(entries) {
+		limit = len(entries)
+	}
+	return entries[:limit]
+}
+
+// lock takes the queue's lock for a change. When outer, the lock of the
+// caller's own change, is set the queue is stored with it in one transaction,
+// so players are never taken from the queue without being put elsewhere.
+func (qs *QueueService) lock(outer *sharedMutex) error {
+	if outer != nil {
+		return qs.mutex.lockWithin(outer)
+	}
+	return qs.mutex.Lock()
+}
+
 // ClearQueue clears the entire queue (useful for match creation)
-func (qs *QueueService) ClearQueue() error {
-	qs.mutex.Lock()
-	defer qs.mutex.Unlock()
+func (qs *QueueService) ClearQueue() (err error) {
+	if err := qs.mutex.Lock(); err != nil {
+		return err
+	}
+	defer qs.mutex.Unlock(&err)
 
 	qs.queue = make(map[string]*models.QueueEntry)
+	qs.substitutes = make(map[string]*models.QueueEntry)
 	qs.isQueueFull = false
-	fmt.Printf("QUEUE CLEARED: New queue ready for players\n")
+	qs.logger.Info("queue cleared")
 
 	return nil
 }
+
+// Close stops accepting players into both queues. Players already waiting
+// stay queued and can still leave or be matched.
+func (qs *QueueService) Close() {
+	// closed belongs to this instance, so closing must work even when the
+	// shared state is unavailable
+	qs.mutex.local.Lock()
+	defer qs.mutex.local.Unlock()
+
+	qs.closed = true
+	qs.logger.Info("queue closed", "queue_size", len(qs.queue), "substitutes_waiting", len(qs.substitutes))
+}
+
+// queueSnapshot is the saved state of both queues
+type queueSnapshot struct {
+	Queue       []models.QueueEntry `json:"queue"`
+	Substitutes []models.QueueEntry `json:"substitutes"`
+}
+
+// Snapshot encodes every waiting player, keeping when they joined so their
+// place in line survives a restart
+func (qs *QueueService) Snapshot() ([]byte, error) {
+	qs.mutex.RLock()
+	defer qs.mutex.RUnlock()
+	return qs.encode()
+}
+
+// encode is Snapshot without locking. Players are sorted so an unchanged
+// queue always encodes the same.
+func (qs *QueueService) encode() ([]byte, error) {
+	snapshot := queueSnapshot{
+		Queue:       make([]models.QueueEntry, 0, len(qs.queue)),
+		Substitutes: make([]models.QueueEntry, 0, len(qs.substitutes)),
+	}
+	for _, entry := range qs.queue {
+		snapshot.Queue = append(snapshot.Queue, *entry)
+	}
+	for _, entry := range qs.substitutes {
+		snapshot.Substitutes = append(snapshot.Substitutes, *entry)
+	}
+	for _, entries := range [][]models.QueueEntry{snapshot.Queue, snapshot.Substitutes} {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].UserID < entries[j].UserID })
+	}
+	return json.Marshal(snapshot)
+}
+
+// decode replaces both queues with a snapshot, without locking
+func (qs *QueueService) decode(data []byte) error {
+	var snapshot queueSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("invalid queue snapshot: %w", err)
+	}
+
+	qs.queue = make(map[string]*models.QueueEntry, len(snapshot.Queue))
+	for _, entry := range snapshot.Queue {
+		qs.queue[entry.UserID] = &entry
+	}
+	qs.substitutes = make(map[string]*models.QueueEntry, len(snapshot.Substitutes))
+	for _, entry := range snapshot.Substitutes {
+		qs.substitutes[entry.UserID] = &entry
+	}
+	qs.isQueueFull = len(qs.queue) >= qs.maxPlayers
+	return nil
+}
+
+// Share shares the queue with every backend instance using coordinator, so
+// players see the same queue whichever instance they reach. Must be called
+// before the queue is used.
+func (qs *QueueService) Share(coordinator Coordinator) {
+	qs.mutex.share(coordinator, "queue", qs.encode, qs.decode, qs.logger)
+}
+
+// Restore puts the players of a snapshot back in line and returns how many
+// were restored. Players who queued again in the meantime keep their new entry.
+func (qs *QueueService) Restore(data []byte) (_ int, err error) {
+	var snapshot queueSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return 0, fmt.Errorf("invalid queue snapshot: %w", err)
+	}
+
+	if err := qs.mutex.Lock(); err != nil {
+		return 0, err
+	}
+	defer qs.mutex.Unlock(&err)
+
+	restored := 0
+	for _, entry := range snapshot.Queue {
+		if _, exists := qs.queue[entry.UserID]; exists {
+			continue
+		}
+		qs.queue[entry.UserID] = &entry
+		restored++
+	}
+	for _, entry := range snapshot.Substitutes {
+		if _, exists := qs.queue[entry.UserID]; exists {
+			continue
+		}
+		if _, exists := qs.substitutes[entry.UserID]; exists {
+			continue
+		}
+		qs.substitutes[entry.UserID] = &entry
+		restored++
+	}
+	qs.isQueueFull = len(qs.queue) >= qs.maxPlayers
+
+	qs.logger.Info("queue restored", "restored", restored, "queue_size", len(qs.queue), "substitutes_waiting", len(qs.substitutes))
+	return restored, nil
+}
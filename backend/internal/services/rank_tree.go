@@ -0,0 +1,166 @@
+package services
+
+import (
+	"math/rand"
+)
+
+// rankKey orders players on a leaderboard: highest value first, ties broken
+// by user ID the same way the leaderboard queries do
+type rankKey struct {
+	value  float64
+	userID string
+}
+
+func (k rankKey) before(other rankKey) bool {
+	if k.value != other.value {
+		return k.value > other.value
+	}
+	return k.userID < other.userID
+}
+
+// rankTree is an order-statistic treap: every node knows the size of its
+// subtree, so inserts, deletes, rank lookups and selecting the n-th player
+// all take logarithmic time
+type rankTree struct {
+	root *rankNode
+}
+
+type rankNode struct {
+	key         rankKey
+	priority    int64
+	size        int
+	left, right *rankNode
+}
+
+func nodeSize(n *rankNode) int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *rankNode) update() {
+	n.size = 1 + nodeSize(n.left) + nodeSize(n.right)
+}
+
+// split divides a subtree into the keys before key and the rest
+func split(n *rankNode, key rankKey) (*rankNode, *rankNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if n.key.before(key) {
+		left, right := split(n.right, key)
+		n.right = left
+		n.update()
+		return n, right
+	}
+	left, right := split(n.left, key)
+	n.left = right
+	n.update()
+	return left, n
+}
+
+// merge joins two subtrees where every key of a comes before every key of b
+func merge(a, b *rankNode) *rankNode {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.priority > b.priority {
+		a.right = merge(a.right, b)
+		a.update()
+		return a
+	}
+	b.left = merge(a, b.left)
+	b.update()
+	return b
+}
+
+func (t *rankTree) Len() int {
+	return nodeSize(t.root)
+}
+
+// Insert adds key, which must not be in the tree yet
+func (t *rankTree) Insert(key rankKey) {
+	left, right := split(t.root, key)
+	node := &rankNode{key: key, priority: rand.Int63(), size: 1}
+	t.root = merge(merge(left, node), right)
+}
+
+// Delete removes key if it is in the tree
+func (t *rankTree) Delete(key rankKey) {
+	left, rest := split(t.root, key)
+	if rest != nil {
+		// The first key of rest is key itself when it is in the tree
+		first := rest
+		for first.left != nil {
+			first = first.left
+		}
+		if first.key == key {
+			rest = deleteFirst(rest)
+		}
+	}
+	t.root = merge(left, rest)
+}
+
+func deleteFirst(n *rankNode) *rankNode {
+	if n.left == nil {
+		return n.right
+	}
+	n.left = deleteFirst(n.left)
+	n.update()
+	return n
+}
+
+// CountBefore returns how many keys come before key
+func (t *rankTree) CountBefore(key rankKey) int {
+	count := 0
+	for n := t.root; n != nil; {
+		if n.key.before(key) {
+			count += nodeSize(n.left) + 1
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return count
+}
+
+// Slice returns up to limit keys starting at the zero-based position start
+func (t *rankTree) Slice(start, limit int) []rankKey {
+	if start < 0 {
+		limit += start
+		start = 0
+	}
+	if limit <= 0 || start >= t.Len() {
+		return nil
+	}
+	keys := make([]rankKey, 0, limit)
+	collect(t.root, start, limit, &keys)
+	return keys
+}
+
+// collect appends the keys of a subtree in order, skipping the first skip
+// keys and stopping once keys holds limit
+func collect(n *rankNode, skip, limit int, keys *[]rankKey) {
+	if n == nil || len(*keys) >= limit {
+		return
+	}
+	leftSize := nodeSize(n.left)
+	if skip < leftSize {
+		collect(n.left, skip, limit, keys)
+	}
+	if len(*keys) >= limit {
+		return
+	}
+	if skip <= leftSize {
+		*keys = append(*keys, n.key)
+	}
+	rightSkip := skip - leftSize - 1
+	if rightSkip < 0 {
+		rightSkip = 0
+	}
+	collect(n.right, rightSkip, limit, keys)
+}
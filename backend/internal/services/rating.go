@@ -0,0 +1,584 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+	"valorant-mobile-web/backend/internal/metrics"
+	"valorant-mobile-web/backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// RatingMode selects how a team result is turned into per-player changes
+type RatingMode string
+
+const (
+	// RatingModeIndividual rates every player on their own against the opposing team
+	RatingModeIndividual RatingMode = "individual"
+	// RatingModeTeam rates the teams against each other and splits the team's
+	// change between its players by rating and, when submitted, performance
+	RatingModeTeam RatingMode = "team"
+)
+
+// performanceBand caps how much submitted stats can scale a player's change (±25%)
+const performanceBand = 0.25
+
+// maxMarginMultiplier caps the margin of victory bonus
+const maxMarginMultiplier = 2.0
+
+// DefaultSubstituteStakes is the share of a normal rating change that
+// substitutes win or lose
+const DefaultSubstituteStakes = 0.5
+
+// RatingOptions tunes how RatingService turns results into rating changes
+type RatingOptions struct {
+	Mode RatingMode
+	// MarginFactor scales rating changes by round differential:
+	// multiplier = 1 + MarginFactor·ln(1 + |team1 rounds - team2 rounds|).
+	// Zero disables margin of victory.
+	MarginFactor float64
+	// PlacementMatches is how many rated matches a player needs before leaving
+	// provisional status. Must match the value given to the engine.
+	PlacementMatches int
+	// SubstituteStakes scales the rating change of players who joined as a
+	// substitute, in (0, 1]. Zero selects DefaultSubstituteStakes.
+	SubstituteStakes float64
+}
+
+// RatingService loads and stores player ratings and rates finished matches
+// with the configured RatingEngine
+type RatingService struct {
+	db      *sql.DB
+	engine  RatingEngine
+	seasons *SeasonService
+	history *RatingHistoryService
+	options RatingOptions
+	logger  *slog.Logger
+}
+
+// NewRatingService creates a RatingService. seasons and history may be nil, in
+// which case results are not tracked per season or in the rating history.
+func NewRatingService(db *sql.DB, engine RatingEngine, seasons *SeasonService, history *RatingHistoryService, options RatingOptions, logger *slog.Logger) *RatingService {
+	if options.Mode == "" {
+		options.Mode = RatingModeIndividual
+	}
+	if options.SubstituteStakes <= 0 || options.SubstituteStakes > 1 {
+		options.SubstituteStakes = DefaultSubstituteStakes
+	}
+	return &RatingService{
+		db:      db,
+		engine:  engine,
+		seasons: seasons,
+		history: history,
+		options: options,
+		logger:  logger,
+	}
+}
+
+// ParseRatingMode validates a rating mode name. An empty name selects individual mode.
+func ParseRatingMode(name string) (RatingMode, error) {
+	switch RatingMode(name) {
+	case "", RatingModeIndividual:
+		return RatingModeIndividual, nil
+	case RatingModeTeam:
+		return RatingModeTeam, nil
+	default:
+		return "", fmt.Errorf("unknown rating mode %q", name)
+	}
+}
+
+// PlacementMatches returns how many matches a player plays before being ranked
+func (rs *RatingService) PlacementMatches() int {
+	return rs.options.PlacementMatches
+}
+
+// IsProvisional reports whether the user is still playing placement matches
+func (rs *RatingService) IsProvisional(userID string) (bool, error) {
+	ratings, err := rs.GetRatings([]string{userID})
+	if err != nil {
+		return false, err
+	}
+	rating := ratings[userID]
+	return rating.IsProvisional(rs.options.PlacementMatches), nil
+}
+
+// Engine returns the engine used to rate matches
+func (rs *RatingService) Engine() RatingEngine {
+	return rs.engine
+}
+
+// GetRatings loads the rating state of the given users. Users without a row
+// get the engine's initial rating.
+func (rs *RatingService) GetRatings(userIDs []string) (map[string]models.Elo, error) {
+	if rs.db == nil {
+		return rs.loadRatings(nil, userIDs, false)
+	}
+	return rs.loadRatings(rs.db, userIDs, false)
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadRatings is GetRatings reading from db, or only returning initial
+// ratings if db is nil. With forUpdate db must be a transaction: the rows
+// then stay locked until it ends, so a decay can't change them before the
+// new ratings are saved.
+func (rs *RatingService) loadRatings(db querier, userIDs []string, forUpdate bool) (map[string]models.Elo, error) {
+	ratings := make(map[string]models.Elo, len(userIDs))
+	for _, userID := range userIDs {
+		ratings[userID] = rs.engine.Initial(userID)
+	}
+
+	if db == nil {
+		return ratings, nil
+	}
+
+	lock := ""
+	if forUpdate {
+		lock = "FOR UPDATE"
+	}
+	rows, err := db.Query(`
+        SELECT id::text, elo, rating_deviation, volatility, wins, losses, games_played, last_match_at
+        FROM users
+        WHERE id::text = ANY($1)
+        ORDER BY id
+        `+lock, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ratings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rating models.Elo
+		var elo int
+		var lastMatch sql.NullTime
+		if err := rows.Scan(&rating.UserID, &elo, &rating.Deviation, &rating.Volatility,
+			&rating.Wins, &rating.Losses, &rating.GamesPlayed, &lastMatch); err != nil {
+			return nil, fmt.Errorf("failed to scan rating: %w", err)
+		}
+		rating.Rating = float64(elo)
+		if lastMatch.Valid {
+			rating.LastMatch = lastMatch.Time
+		}
+		ratings[rating.UserID] = rating
+	}
+
+	return ratings, rows.Err()
+}
+
+// SaveRatings writes the rating state of every player in a single transaction
+func (rs *RatingService) SaveRatings(ratings []models.Elo) error {
+	if rs.db == nil {
+		return nil
+	}
+
+	tx, err := rs.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start rating transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := rs.saveRatingsTx(tx, ratings); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (rs *RatingService) saveRatingsTx(tx *sql.Tx, ratings []models.Elo) error {
+	for _, rating := range ratings {
+		var lastMatch interface{}
+		if !rating.LastMatch.IsZero() {
+			lastMatch = rating.LastMatch
+		}
+		_, err := tx.Exec(`
+            UPDATE users
+            SET elo = $2, rating_deviation = $3, volatility = $4, wins = $5, losses = $6,
+                games_played = $7, last_match_at = $8, decay_steps = 0, updated_at = NOW()
+            WHERE id::text = $1
+        `, rating.UserID, int(math.Round(rating.Rating)), rating.Deviation, rating.Volatility,
+			rating.Wins, rating.Losses, rating.GamesPlayed, lastMatch)
+		if err != nil {
+			return fmt.Errorf("failed to save rating for %s: %w", rating.UserID, err)
+		}
+	}
+
+	return nil
+}
+
+// saveMatchRatings stores the new ratings of a match, adds the result to the
+// active season and records the changes in the rating history as part of tx
+func (rs *RatingService) saveMatchRatings(tx *sql.Tx, matchID string, before, after []models.Elo, now time.Time) error {
+	if err := rs.saveRatingsTx(tx, after); err != nil {
+		return err
+	}
+
+	if rs.seasons != nil {
+		if err := rs.seasons.RecordResults(tx, before, after); err != nil {
+			return err
+		}
+	}
+
+	if rs.history != nil {
+		if err := rs.history.RecordMatch(tx, matchID, before, after, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MarginMultiplier returns the factor applied to rating changes for a round score
+func (rs *RatingService) MarginMultiplier(team1Rounds, team2Rounds int) float64 {
+	if rs.options.MarginFactor <= 0 {
+		return 1
+	}
+	diff := math.Abs(float64(team1Rounds - team2Rounds))
+	return math.Min(maxMarginMultiplier, 1+rs.options.MarginFactor*math.Log1p(diff))
+}
+
+// formula describes how ratings are computed with the current configuration
+func (rs *RatingService) formula() string {
+	return fmt.Sprintf("%s/%s: new = old + (engine delta) × margin, margin = min(%.1f, 1 + %.3f·ln(1 + |Δrounds|)), performance band ±%.0f%%, substitutes ×%.2f, leavers rated as a loss",
+		rs.engine.Name(), rs.options.Mode, maxMarginMultiplier, rs.options.MarginFactor, performanceBand*100, rs.options.SubstituteStakes)
+}
+
+// RateTeams rates a team game. In individual mode each player is rated
+// against the opposing team as a whole (average rating, root mean square
+// deviation); in team mode see rateTeamContribution. The rating change of
+// every player is then scaled by margin (see MarginMultiplier).
+// team1Score is 1 when team 1 won, 0.5 for a draw and 0 when it lost.
+// stats is optional and only used in team mode.
+func (rs *RatingService) RateTeams(team1, team2 []models.Elo, team1Score, margin float64, stats map[string]models.PlayerMatchStats, now time.Time) ([]models.Elo, []models.Elo) {
+	team1Opponent := teamOpponent(team2, team1Score)
+	team2Opponent := teamOpponent(team1, 1-team1Score)
+
+	rate := func(team []models.Elo, opponent RatingOpponent) []models.Elo {
+		updated := make([]models.Elo, len(team))
+		for i, player := range team {
+			updated[i] = rs.engine.Update(player, []RatingOpponent{opponent}, now)
+			switch opponent.Score {
+			case 1:
+				updated[i].Wins++
+			case 0:
+				updated[i].Losses++
+			}
+		}
+		if rs.options.Mode == RatingModeTeam {
+			rs.rateTeamContribution(team, updated, opponent, stats, now)
+		}
+		for i, player := range team {
+			delta := (updated[i].Rating - player.Rating) * margin
+			updated[i].Rating = NewELOService().ClampELO(player.Rating + delta)
+		}
+		return updated
+	}
+
+	return rate(team1, team1Opponent), rate(team2, team2Opponent)
+}
+
+// rateTeamContribution replaces the individual rating changes in updated by a
+// share of the team's change. The team is rated as one player against the
+// opponent, then the total is split so that on a win the players the team
+// was least expected to rely on gain the most, and on a loss the players
+// expected to carry lose the most. Submitted stats scale each share within
+// performanceBand. Only stats known when the match is rated count, i.e.
+// those of a Riot import: lines submitted afterwards through SubmitStats
+// don't re-rate the match. Deviation and volatility keep their individual
+// update.
+func (rs *RatingService) rateTeamContribution(team, updated []models.Elo, opponent RatingOpponent, stats map[string]models.PlayerMatchStats, now time.Time) {
+	if len(team) == 0 {
+		return
+	}
+
+	composite := teamOpponent(team, 0)
+	teamPlayer := models.Elo{
+		Rating:      composite.Rating,
+		Deviation:   composite.Deviation,
+		Volatility:  averageVolatility(team),
+		GamesPlayed: averageGamesPlayed(team),
+	}
+	teamDelta := rs.engine.Update(teamPlayer, []RatingOpponent{opponent}, now).Rating - teamPlayer.Rating
+
+	eloService := NewELOService()
+	weights := make([]float64, len(team))
+	weightSum := 0.0
+	for i, player := range team {
+		expected := eloService.WinProbability(int(player.Rating), int(opponent.Rating))
+		if teamDelta >= 0 {
+			weights[i] = 1 - expected
+		} else {
+			weights[i] = expected
+		}
+		weightSum += weights[i]
+	}
+
+	multipliers := performanceMultipliers(team, stats)
+	for i, player := range team {
+		share := 1.0
+		if weightSum > 0 {
+			share = weights[i] * float64(len(team)) / weightSum
+		}
+
+		multiplier := multipliers[i]
+		if teamDelta < 0 {
+			// Playing well softens a loss instead of growing it
+			multiplier = 2 - multiplier
+		}
+
+		updated[i].Rating = eloService.ClampELO(player.Rating + teamDelta*share*multiplier)
+	}
+}
+
+// performanceMultipliers compares each player's ACS and KDA with their team
+// average and maps it to [1-performanceBand, 1+performanceBand]. Players
+// without stats, or teams where nobody submitted stats, get 1.
+func performanceMultipliers(team []models.Elo, stats map[string]models.PlayerMatchStats) []float64 {
+	multipliers := make([]float64, len(team))
+	for i := range multipliers {
+		multipliers[i] = 1
+	}
+
+	var acsSum, kdaSum float64
+	submitted := 0
+	for _, player := range team {
+		if s, ok := stats[player.UserID]; ok {
+			acsSum += s.ACS
+			kdaSum += s.KDA()
+			submitted++
+		}
+	}
+	if submitted == 0 || acsSum == 0 || kdaSum == 0 {
+		return multipliers
+	}
+
+	acsAvg := acsSum / float64(submitted)
+	kdaAvg := kdaSum / float64(submitted)
+	for i, player := range team {
+		s, ok := stats[player.UserID]
+		if !ok {
+			continue
+		}
+		performance := (s.ACS/acsAvg + s.KDA()/kdaAvg) / 2
+		multipliers[i] = math.Max(1-performanceBand, math.Min(1+performanceBand, performance))
+	}
+
+	return multipliers
+}
+
+// averageGamesPlayed lets a team of new players move at the provisional rate
+func averageGamesPlayed(team []models.Elo) int {
+	sum := 0
+	for _, player := range team {
+		sum += player.GamesPlayed
+	}
+	return sum / len(team)
+}
+
+// averageVolatility is used for the team composite in Glicko-2
+func averageVolatility(team []models.Elo) float64 {
+	sum := 0.0
+	for _, player := range team {
+		sum += player.Volatility
+	}
+	return sum / float64(len(team))
+}
+
+// MatchRatings holds the new ratings of both teams of a rated match and the
+// audit describing how they were computed
+type MatchRatings struct {
+	Team1   []models.Elo
+	Team2   []models.Elo
+	Leavers []models.Elo // Players replaced by a substitute, rated as a loss
+	Audit   models.RatingAudit
+}
+
+// ApplyMatchResult rates both teams of a completed match from match.Result,
+// stores the new ratings and records a RatingAudit for the match. Players
+// who left and were substituted lose against the opposing team whatever the
+// result, and substitutes only get SubstituteStakes of their change. stats
+// may be nil; a match is rated once, so stats submitted later never change
+// its ratings.
+func (rs *RatingService) ApplyMatchResult(match *models.Match, stats map[string]models.PlayerMatchStats) (*MatchRatings, error) {
+	if match.Result == nil {
+		return nil, fmt.Errorf("match %s has no result", match.ID)
+	}
+
+	userIDs := append(append([]string{}, match.Team1...), match.Team2...)
+	onTeam := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		onTeam[userID] = true
+	}
+	substitutes := make(map[string]bool)
+	var leavers []models.Substitution
+	for _, substitution := range match.Substitutions {
+		substitutes[substitution.SubstituteID] = true
+		// A leaver who came back as someone else's substitute is rated with their team
+		if !onTeam[substitution.LeaverID] {
+			leavers = append(leavers, substitution)
+			userIDs = append(userIDs, substitution.LeaverID)
+			onTeam[substitution.LeaverID] = true
+		}
+	}
+
+	// The ratings are read and saved in one transaction, see loadRatings
+	var tx *sql.Tx
+	var db querier
+	if rs.db != nil {
+		var err error
+		tx, err = rs.db.Begin()
+		if err != nil {
+			return nil, fmt.Errorf("failed to start rating transaction: %w", err)
+		}
+		defer tx.Rollback()
+		db = tx
+	}
+	ratings, err := rs.loadRatings(db, userIDs, true)
+	if err != nil {
+		return nil, err
+	}
+
+	team1 := make([]models.Elo, len(match.Team1))
+	for i, userID := range match.Team1 {
+		team1[i] = ratings[userID]
+	}
+	team2 := make([]models.Elo, len(match.Team2))
+	for i, userID := range match.Team2 {
+		team2[i] = ratings[userID]
+	}
+
+	team1Score := match.Result.Team1Score()
+	margin := rs.MarginMultiplier(match.Result.Team1Rounds, match.Result.Team2Rounds)
+	now := time.Now()
+	newTeam1, newTeam2 := rs.RateTeams(team1, team2, team1Score, margin, stats, now)
+	rs.scaleSubstitutes(team1, newTeam1, substitutes)
+	rs.scaleSubstitutes(team2, newTeam2, substitutes)
+
+	leaversBefore := make([]models.Elo, len(leavers))
+	leaversAfter := make([]models.Elo, len(leavers))
+	for i, leaver := range leavers {
+		opponents := team2
+		if leaver.Team == models.MatchWinnerTeam2 {
+			opponents = team1
+		}
+		leaversBefore[i] = ratings[leaver.LeaverID]
+		leaversAfter[i] = rs.engine.Update(leaversBefore[i], []RatingOpponent{teamOpponent(opponents, 0)}, now)
+		leaversAfter[i].Losses++
+	}
+
+	before := append(append(append([]models.Elo{}, team1...), team2...), leaversBefore...)
+	after := append(append(append([]models.Elo{}, newTeam1...), newTeam2...), leaversAfter...)
+	if tx != nil {
+		if err := rs.saveMatchRatings(tx, match.ID, before, after, now); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit ratings: %w", err)
+		}
+	}
+
+	audit := models.RatingAudit{
+		MatchID:          match.ID,
+		Engine:           rs.engine.Name(),
+		Mode:             string(rs.options.Mode),
+		Formula:          rs.formula(),
+		Team1Score:       team1Score,
+		Team1Rounds:      match.Result.Team1Rounds,
+		Team2Rounds:      match.Result.Team2Rounds,
+		MarginMultiplier: margin,
+		CreatedAt:        time.Now(),
+	}
+	audit.Players = append(auditPlayers("team1", team1, newTeam1), auditPlayers("team2", team2, newTeam2)...)
+	for i := range audit.Players {
+		audit.Players[i].Substitute = substitutes[audit.Players[i].UserID]
+	}
+	for i, leaver := range leavers {
+		player := auditPlayers(leaver.Team, leaversBefore[i:i+1], leaversAfter[i:i+1])[0]
+		player.Abandoned = true
+		audit.Players = append(audit.Players, player)
+	}
+	for _, player := range audit.Players {
+		metrics.RatingChange.Observe(player.RatingAfter-player.RatingBefore, audit.Engine)
+	}
+	if err := rs.SaveAudit(audit); err != nil {
+		// Ratings are already stored, a missing audit row must not fail the match
+		rs.logger.Warn("could not store rating audit", "match_id", match.ID, "error", err)
+	}
+
+	rs.logger.Info("ratings updated", "match_id", match.ID, "engine", rs.engine.Name(), "mode", rs.options.Mode,
+		"team1_score", team1Score, "margin_multiplier", margin, "leavers", len(leavers))
+	return &MatchRatings{Team1: newTeam1, Team2: newTeam2, Leavers: leaversAfter, Audit: audit}, nil
+}
+
+// scaleSubstitutes reduces the rating change of substitutes to SubstituteStakes
+func (rs *RatingService) scaleSubstitutes(before, after []models.Elo, substitutes map[string]bool) {
+	for i := range after {
+		if substitutes[after[i].UserID] {
+			after[i].Rating = before[i].Rating + (after[i].Rating-before[i].Rating)*rs.options.SubstituteStakes
+		}
+	}
+}
+
+// SaveAudit stores the rating audit of a match
+func (rs *RatingService) SaveAudit(audit models.RatingAudit) error {
+	if rs.db == nil {
+		return nil
+	}
+
+	players, err := json.Marshal(audit.Players)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit players: %w", err)
+	}
+
+	_, err = rs.db.Exec(`
+        INSERT INTO match_rating_audits
+            (match_id, engine, mode, formula, team1_score, team1_rounds, team2_rounds, margin_multiplier, players, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        ON CONFLICT (match_id) DO NOTHING
+    `, audit.MatchID, audit.Engine, audit.Mode, audit.Formula, audit.Team1Score,
+		audit.Team1Rounds, audit.Team2Rounds, audit.MarginMultiplier, players, audit.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save rating audit: %w", err)
+	}
+	return nil
+}
+
+func auditPlayers(team string, before, after []models.Elo) []models.RatingAuditPlayer {
+	players := make([]models.RatingAuditPlayer, len(before))
+	for i := range before {
+		players[i] = models.RatingAuditPlayer{
+			UserID:          before[i].UserID,
+			Team:            team,
+			RatingBefore:    before[i].Rating,
+			RatingAfter:     after[i].Rating,
+			DeviationBefore: before[i].Deviation,
+			DeviationAfter:  after[i].Deviation,
+		}
+	}
+	return players
+}
+
+// teamOpponent summarises a team as a single opponent
+func teamOpponent(team []models.Elo, score float64) RatingOpponent {
+	if len(team) == 0 {
+		return RatingOpponent{Rating: 1000, Score: score}
+	}
+
+	var ratingSum, varianceSum float64
+	for _, player := range team {
+		ratingSum += player.Rating
+		varianceSum += player.Deviation * player.Deviation
+	}
+
+	return RatingOpponent{
+		Rating:    ratingSum / float64(len(team)),
+		Deviation: math.Sqrt(varianceSum / float64(len(team))),
+		Score:     score,
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// Rating engine names accepted by NewRatingEngine (RATING_ENGINE env var)
+const (
+	RatingEngineELO     = "elo"
+	RatingEngineGlicko2 = "glicko2"
+)
+
+// DefaultPlacementMatches is how many matches an account plays with a
+// provisional rating before it is ranked
+const DefaultPlacementMatches = 5
+
+// placementKMultiplier makes provisional ELO ratings move much faster
+const placementKMultiplier = 2.5
+
+// RatingOpponent is one rated result against an opponent. For team games the
+// opponent is the other team as a whole.
+type RatingOpponent struct {
+	Rating    float64
+	Deviation float64
+	Score     float64 // 1 win, 0.5 draw, 0 loss
+}
+
+// RatingEngine computes rating changes. Implementations must be safe for
+// concurrent use.
+type RatingEngine interface {
+	Name() string
+	// Initial returns the rating of a player that never played a rated match
+	Initial(userID string) models.Elo
+	// Update applies a set of results played at the same time
+	Update(player models.Elo, results []RatingOpponent, now time.Time) models.Elo
+	// ApplyInactivity adjusts a rating for the time since the player's last match
+	ApplyInactivity(player models.Elo, now time.Time) models.Elo
+}
+
+// NewRatingEngine returns the engine registered under name. An empty name
+// selects the classic ELO engine, which moves ratings by up to kFactor
+// points per game. Players with fewer than placementMatches rated games are
+// provisional and move faster.
+func NewRatingEngine(name string, placementMatches int, kFactor float64) (RatingEngine, error) {
+	switch name {
+	case "", RatingEngineELO:
+		return NewELORatingEngine(placementMatches, kFactor), nil
+	case RatingEngineGlicko2:
+		return NewGlicko2Engine(placementMatches), nil
+	default:
+		return nil, fmt.Errorf("unknown rating engine %q", name)
+	}
+}
+
+// ELORatingEngine adapts ELOService to the RatingEngine interface
+type ELORatingEngine struct {
+	elo              *ELOService
+	placementMatches int
+}
+
+func NewELORatingEngine(placementMatches int, kFactor float64) *ELORatingEngine {
+	return &ELORatingEngine{
+		elo:              NewELOServiceWithKFactor(kFactor),
+		placementMatches: placementMatches,
+	}
+}
+
+func (e *ELORatingEngine) Name() string {
+	return RatingEngineELO
+}
+
+func (e *ELORatingEngine) Initial(userID string) models.Elo {
+	return models.Elo{
+		UserID: userID,
+		Rating: 1000,
+	}
+}
+
+func (e *ELORatingEngine) Update(player models.Elo, results []RatingOpponent, now time.Time) models.Elo {
+	k := e.elo.KFactor()
+	if player.IsProvisional(e.placementMatches) {
+		k *= placementKMultiplier
+	}
+
+	rating := player.Rating
+	for _, result := range results {
+		rating += k * (result.Score - e.elo.WinProbability(int(player.Rating), int(result.Rating)))
+	}
+
+	player.Rating = e.elo.ClampELO(rating)
+	player.GamesPlayed += len(results)
+	player.LastMatch = now
+	return player
+}
+
+// ApplyInactivity is a no-op, plain ELO has no notion of uncertainty
+func (e *ELORatingEngine) ApplyInactivity(player models.Elo, now time.Time) models.Elo {
+	return player
+}
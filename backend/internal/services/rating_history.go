@@ -0,0 +1,164 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// RatingHistoryService keeps a ledger of every rating change so a player's
+// progress can be charted and any rating traced back to its causes
+type RatingHistoryService struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewRatingHistoryService(db *sql.DB, logger *slog.Logger) *RatingHistoryService {
+	return &RatingHistoryService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Record stores one entry, using db so it is written in the same transaction
+// as the rating change itself
+func (hs *RatingHistoryService) Record(db execer, entry models.RatingHistoryEntry) error {
+	var matchID, note interface{}
+	if entry.MatchID != "" {
+		matchID = entry.MatchID
+	}
+	if entry.Note != "" {
+		note = entry.Note
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	_, err := db.Exec(`
+        INSERT INTO rating_history (user_id, reason, rating_before, rating_after, delta,
+                                    deviation_before, deviation_after, match_id, note, created_at)
+        SELECT id, $2, $3, $4, $5, $6, $7, $8, $9, $10 FROM users WHERE id::text = $1
+    `, entry.UserID, entry.Reason, entry.RatingBefore, entry.RatingAfter, entry.RatingAfter-entry.RatingBefore,
+		entry.DeviationBefore, entry.DeviationAfter, matchID, note, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record rating change for %s: %w", entry.UserID, err)
+	}
+	return nil
+}
+
+// RecordMatch stores the rating change of every player of a match.
+// before and after must hold the same players in the same order.
+func (hs *RatingHistoryService) RecordMatch(db execer, matchID string, before, after []models.Elo, now time.Time) error {
+	for i := range after {
+		deviationBefore, deviationAfter := before[i].Deviation, after[i].Deviation
+		err := hs.Record(db, models.RatingHistoryEntry{
+			UserID:          after[i].UserID,
+			Reason:          models.RatingChangeMatch,
+			RatingBefore:    int(math.Round(before[i].Rating)),
+			RatingAfter:     int(math.Round(after[i].Rating)),
+			DeviationBefore: &deviationBefore,
+			DeviationAfter:  &deviationAfter,
+			MatchID:         matchID,
+			CreatedAt:       now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AdjustRating sets a player's rating by hand and records why
+func (hs *RatingHistoryService) AdjustRating(userID string, delta int, note string) (*models.RatingHistoryEntry, error) {
+	tx, err := hs.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start adjustment transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var before int
+	err = tx.QueryRow(`SELECT elo FROM users WHERE id::text = $1 FOR UPDATE`, userID).Scan(&before)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rating: %w", err)
+	}
+
+	after := int(math.Round(NewELOService().ClampELO(float64(before + delta))))
+	if _, err := tx.Exec(`UPDATE users SET elo = $2, updated_at = NOW() WHERE id::text = $1`, userID, after); err != nil {
+		return nil, fmt.Errorf("failed to adjust rating: %w", err)
+	}
+
+	entry := models.RatingHistoryEntry{
+		UserID:       userID,
+		Reason:       models.RatingChangeAdjustment,
+		RatingBefore: before,
+		RatingAfter:  after,
+		Delta:        after - before,
+		Note:         note,
+		CreatedAt:    time.Now(),
+	}
+	if err := hs.Record(tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit adjustment: %w", err)
+	}
+
+	hs.logger.Info("rating adjusted", "user_id", userID, "rating_before", before, "rating_after", after, "note", note)
+	return &entry, nil
+}
+
+// GetUserHistory returns a player's rating changes between from and to
+// (either may be zero for an open range), oldest first
+func (hs *RatingHistoryService) GetUserHistory(userID string, from, to time.Time, limit int) ([]models.RatingHistoryEntry, error) {
+	var fromArg, toArg interface{}
+	if !from.IsZero() {
+		fromArg = from
+	}
+	if !to.IsZero() {
+		toArg = to
+	}
+
+	// The newest entries are kept when the range holds more than limit
+	rows, err := hs.db.Query(`
+        SELECT * FROM (
+            SELECT id::text, user_id::text, reason, rating_before, rating_after, delta,
+                   deviation_before, deviation_after, COALESCE(match_id, ''), COALESCE(note, ''), created_at
+            FROM rating_history
+            WHERE user_id::text = $1
+              AND ($2::timestamp IS NULL OR created_at >= $2)
+              AND ($3::timestamp IS NULL OR created_at < $3)
+            ORDER BY created_at DESC
+            LIMIT $4
+        ) recent
+        ORDER BY created_at ASC
+    `, userID, fromArg, toArg, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rating history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.RatingHistoryEntry{}
+	for rows.Next() {
+		var entry models.RatingHistoryEntry
+		var deviationBefore, deviationAfter sql.NullFloat64
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.Reason, &entry.RatingBefore, &entry.RatingAfter,
+			&entry.Delta, &deviationBefore, &deviationAfter, &entry.MatchID, &entry.Note, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rating history: %w", err)
+		}
+		if deviationBefore.Valid {
+			entry.DeviationBefore = &deviationBefore.Float64
+		}
+		if deviationAfter.Valid {
+			entry.DeviationAfter = &deviationAfter.Float64
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
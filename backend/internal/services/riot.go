@@ -0,0 +1,117 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// RiotClient is the subset of the Riot API used by the backend
+type RiotClient interface {
+	// GetMatch returns a val-match-v1 match document
+	GetMatch(riotMatchID string) (*models.RiotMatch, error)
+	// GetAccountByRiotID looks up an account by game name and tag line
+	GetAccountByRiotID(gameName, tagLine string) (*models.RiotAccount, error)
+	// GetVerificationCode returns the code a player set on their profile to
+	// prove they own the account
+	GetVerificationCode(puuid string) (string, error)
+}
+
+// ErrRiotAccountNotFound is returned when no Riot account has the requested Riot ID
+var ErrRiotAccountNotFound = errors.New("riot account not found")
+
+// DefaultRiotFixtureDir is where FixtureRiotClient looks when RIOT_FIXTURE_DIR is unset
+const DefaultRiotFixtureDir = "fixtures/riot"
+
+// riotMatchIDPattern keeps fixture lookups inside the fixture directory
+var riotMatchIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// FixtureRiotClient serves Riot API responses from JSON files so imports and
+// account linking can be developed and tested without an API key. Matches are
+// read from <dir>/matches/<match id>.json and accounts from <dir>/accounts.json,
+// which is re-read on every call so verification codes can be edited in place.
+type FixtureRiotClient struct {
+	dir string
+}
+
+func NewFixtureRiotClient(dir string) *FixtureRiotClient {
+	if dir == "" {
+		dir = DefaultRiotFixtureDir
+	}
+	return &FixtureRiotClient{
+		dir: dir,
+	}
+}
+
+func (c *FixtureRiotClient) GetMatch(riotMatchID string) (*models.RiotMatch, error) {
+	if !riotMatchIDPattern.MatchString(riotMatchID) {
+		return nil, fmt.Errorf("invalid riot match id %q", riotMatchID)
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.dir, "matches", riotMatchID+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("riot match %s not found", riotMatchID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read riot match %s: %w", riotMatchID, err)
+	}
+
+	var match models.RiotMatch
+	if err := json.Unmarshal(data, &match); err != nil {
+		return nil, fmt.Errorf("invalid riot match %s: %w", riotMatchID, err)
+	}
+	return &match, nil
+}
+
+// fixtureAccount is an entry of accounts.json
+type fixtureAccount struct {
+	models.RiotAccount
+	VerificationCode string `json:"verificationCode"`
+}
+
+func (c *FixtureRiotClient) loadAccounts() ([]fixtureAccount, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, "accounts.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read riot accounts: %w", err)
+	}
+
+	var accounts []fixtureAccount
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("invalid riot accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+func (c *FixtureRiotClient) GetAccountByRiotID(gameName, tagLine string) (*models.RiotAccount, error) {
+	accounts, err := c.loadAccounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		if strings.EqualFold(account.GameName, gameName) && strings.EqualFold(account.TagLine, tagLine) {
+			return &account.RiotAccount, nil
+		}
+	}
+	return nil, ErrRiotAccountNotFound
+}
+
+func (c *FixtureRiotClient) GetVerificationCode(puuid string) (string, error) {
+	accounts, err := c.loadAccounts()
+	if err != nil {
+		return "", err
+	}
+	for _, account := range accounts {
+		if account.PUUID == puuid {
+			return account.VerificationCode, nil
+		}
+	}
+	return "", ErrRiotAccountNotFound
+}
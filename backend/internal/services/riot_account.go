@@ -0,0 +1,240 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+	"valorant-mobile-web/backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// Riot ID format: 3-16 character game name, 3-5 alphanumeric tag line
+var (
+	riotGameNamePattern = regexp.MustCompile(`^[\p{L}\p{N} ]{3,16}$`)
+	riotTagLinePattern  = regexp.MustCompile(`^[\p{L}\p{N}]{3,5}$`)
+)
+
+const riotCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// RiotAccountService links Riot IDs to users. A link is pending until the
+// player proves ownership by setting a one-time code on their Riot profile;
+// only verified links are shown to others and used to map match documents.
+type RiotAccountService struct {
+	db     *sql.DB
+	client RiotClient
+	logger *slog.Logger
+}
+
+func NewRiotAccountService(db *sql.DB, client RiotClient, logger *slog.Logger) *RiotAccountService {
+	return &RiotAccountService{
+		db:     db,
+		client: client,
+		logger: logger,
+	}
+}
+
+// ParseRiotID splits "name#tag" and checks both parts
+func ParseRiotID(riotID string) (string, string, error) {
+	gameName, tagLine, ok := strings.Cut(strings.TrimSpace(riotID), "#")
+	if !ok {
+		return "", "", fmt.Errorf("riot id must look like name#tag")
+	}
+	if !riotGameNamePattern.MatchString(gameName) {
+		return "", "", fmt.Errorf("riot game name must be 3-16 letters, digits or spaces")
+	}
+	if !riotTagLinePattern.MatchString(tagLine) {
+		return "", "", fmt.Errorf("riot tag must be 3-5 letters or digits")
+	}
+	return gameName, tagLine, nil
+}
+
+// StartLink records a pending Riot ID for a user and returns the code they
+// must set on their Riot profile
+func (ras *RiotAccountService) StartLink(userID, riotID, region string) (*models.RiotLink, error) {
+	gameName, tagLine, err := ParseRiotID(riotID)
+	if err != nil {
+		return nil, err
+	}
+
+	region = strings.ToLower(region)
+	validRegion := false
+	for _, r := range models.RiotRegions {
+		if r == region {
+			validRegion = true
+		}
+	}
+	if !validRegion {
+		return nil, fmt.Errorf("region must be one of %s", strings.Join(models.RiotRegions, ", "))
+	}
+
+	account, err := ras.client.GetAccountByRiotID(gameName, tagLine)
+	if err != nil {
+		return nil, err
+	}
+
+	var owner string
+	err = ras.db.QueryRow(`
+        SELECT id::text FROM users
+        WHERE LOWER(riot_game_name) = LOWER($1) AND LOWER(riot_tag_line) = LOWER($2)
+          AND riot_verified_at IS NOT NULL AND id::text <> $3
+    `, account.GameName, account.TagLine, userID).Scan(&owner)
+	if err == nil {
+		return nil, fmt.Errorf("%s#%s is already linked to another account", account.GameName, account.TagLine)
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to check riot id: %w", err)
+	}
+
+	code, err := verificationCode()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := ras.db.Exec(`
+        UPDATE users
+        SET riot_game_name = $2, riot_tag_line = $3, riot_region = $4, riot_puuid = $5,
+            riot_verification_code = $6, riot_verified_at = NULL, updated_at = NOW()
+        WHERE id::text = $1
+    `, userID, account.GameName, account.TagLine, region, account.PUUID, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to link riot id: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	return &models.RiotLink{
+		UserID:           userID,
+		RiotID:           account.GameName + "#" + account.TagLine,
+		GameName:         account.GameName,
+		TagLine:          account.TagLine,
+		Region:           region,
+		VerificationCode: code,
+	}, nil
+}
+
+// Verify completes a pending link once the code is set on the Riot profile
+func (ras *RiotAccountService) Verify(userID string) (*models.RiotLink, error) {
+	link, puuid, err := ras.getLink(userID)
+	if err != nil {
+		return nil, err
+	}
+	if link.Verified {
+		return link, nil
+	}
+	if link.VerificationCode == "" {
+		return nil, fmt.Errorf("no riot id link in progress")
+	}
+
+	code, err := ras.client.GetVerificationCode(puuid)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(strings.TrimSpace(code), link.VerificationCode) {
+		return nil, fmt.Errorf("verification code %s not found on the riot profile of %s", link.VerificationCode, link.RiotID)
+	}
+
+	now := time.Now()
+	_, err = ras.db.Exec(`
+        UPDATE users SET riot_verified_at = $2, riot_verification_code = NULL, updated_at = NOW()
+        WHERE id::text = $1
+    `, userID, now)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, fmt.Errorf("%s is already linked to another account", link.RiotID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify riot id: %w", err)
+	}
+
+	ras.logger.Info("riot id verified", "user_id", userID, "riot_id", link.RiotID, "region", link.Region)
+	link.Verified = true
+	link.VerifiedAt = &now
+	link.VerificationCode = ""
+	return link, nil
+}
+
+// Unlink removes a user's Riot ID
+func (ras *RiotAccountService) Unlink(userID string) error {
+	_, err := ras.db.Exec(`
+        UPDATE users
+        SET riot_game_name = NULL, riot_tag_line = NULL, riot_region = NULL, riot_puuid = NULL,
+            riot_verification_code = NULL, riot_verified_at = NULL, updated_at = NOW()
+        WHERE id::text = $1
+    `, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unlink riot id: %w", err)
+	}
+	return nil
+}
+
+// GetLink returns a user's Riot ID link. The verification code is included,
+// callers showing the link to anyone but its owner must clear it.
+func (ras *RiotAccountService) GetLink(userID string) (*models.RiotLink, error) {
+	link, _, err := ras.getLink(userID)
+	return link, err
+}
+
+func (ras *RiotAccountService) getLink(userID string) (*models.RiotLink, string, error) {
+	var link models.RiotLink
+	var gameName, tagLine, region, code, puuid sql.NullString
+	var verifiedAt sql.NullTime
+	err := ras.db.QueryRow(`
+        SELECT id::text, riot_game_name, riot_tag_line, riot_region, riot_verification_code, riot_puuid, riot_verified_at
+        FROM users WHERE id::text = $1
+    `, userID).Scan(&link.UserID, &gameName, &tagLine, &region, &code, &puuid, &verifiedAt)
+	if err == sql.ErrNoRows {
+		return nil, "", fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load riot id: %w", err)
+	}
+	if !gameName.Valid {
+		return nil, "", fmt.Errorf("no riot id linked")
+	}
+
+	link.GameName = gameName.String
+	link.TagLine = tagLine.String
+	link.RiotID = link.GameName + "#" + link.TagLine
+	link.Region = region.String
+	link.VerificationCode = code.String
+	if verifiedAt.Valid {
+		link.Verified = true
+		link.VerifiedAt = &verifiedAt.Time
+	}
+	return &link, puuid.String, nil
+}
+
+// VerifiedRiotID returns a user's verified Riot ID, or "" when they have none
+func (ras *RiotAccountService) VerifiedRiotID(userID string) (string, error) {
+	var riotID sql.NullString
+	err := ras.db.QueryRow(`
+        SELECT riot_game_name || '#' || riot_tag_line FROM users
+        WHERE id::text = $1 AND riot_verified_at IS NOT NULL
+    `, userID).Scan(&riotID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load riot id: %w", err)
+	}
+	return riotID.String, nil
+}
+
+// verificationCode returns a random code like "VAL-7KQ2MX"
+func verificationCode() (string, error) {
+	code := make([]byte, 6)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(riotCodeAlphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate verification code: %w", err)
+		}
+		code[i] = riotCodeAlphabet[n.Int64()]
+	}
+	return "VAL-" + string(code), nil
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"valorant-mobile-web/backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// riotAgents maps Riot character IDs to agent names
+var riotAgents = map[string]string{
+	"41fb69c1-4189-7b37-f117-bcaf1e96f1bf": "astra",
+	"5f8d3a7f-467b-97f3-062c-13acf203c006": "breach",
+	"9f0d8ba9-4140-b941-57d3-a7ad57c6b417": "brimstone",
+	"22697a3d-45bf-8dd7-4fec-84a9e28c69d7": "chamber",
+	"1dbf2edd-4729-0984-3115-daa5eed44993": "clove",
+	"117ed9e3-49f3-6512-3ccf-0cada7e3823b": "cypher",
+	"cc8b64c8-4b25-4ff9-6e7f-37b4da43d235": "deadlock",
+	"dade69b4-4f5a-8528-247b-219e5a1facd6": "fade",
+	"e370fa57-4757-3604-3648-499e1f642d3f": "gekko",
+	"95b78ed7-4637-86d9-7e41-71ba8c293152": "harbor",
+	"0e38b510-41a8-5780-5e8f-568b2a4f2d6c": "iso",
+	"add6443a-41bd-e414-f6ad-e58d267f4e95": "jett",
+	"601dbbe7-43ce-be57-2a40-4abd24953621": "kayo",
+	"1e58de9c-4950-5125-93e9-a0aee9f98746": "killjoy",
+	"bb2a4828-46eb-8cd1-e765-15848195d751": "neon",
+	"8e253930-4c05-31dd-1b6c-968525494517": "omen",
+	"eb93336a-449b-9c1b-0a54-a891f7921d69": "phoenix",
+	"f94c3b30-42be-e959-889c-5aa313dba261": "raze",
+	"a3bfb853-43b2-7238-a4f1-ad90e9e46bcc": "reyna",
+	"569fdd95-4d10-43ab-ca70-79becc718b46": "sage",
+	"6f2a04ca-43e0-be17-7f36-b3908627744d": "skye",
+	"320b2a48-4d9b-a075-30f1-1f93a9b638fa": "sova",
+	"b444168c-4e35-8076-db47-ef9bf368f384": "tejo",
+	"707eab51-4836-f488-046a-cda6bf494859": "viper",
+	"efba5359-4016-a1e5-7626-b1ae76895940": "vyse",
+	"df1cb487-4902-002e-5c17-d28e83e78588": "waylay",
+	"7f94d92c-4234-0a36-9646-3a87eb8b5c89": "yoru",
+}
+
+// riotMaps maps the internal map names used in Riot map paths to ours
+var riotMaps = map[string]string{
+	"ascent":  "ascent",
+	"duality": "bind",
+	"triad":   "haven",
+	"bonsai":  "split",
+	"port":    "icebox",
+	"foxtrot": "breeze",
+	"canyon":  "fracture",
+	"pitt":    "pearl",
+	"jam":     "lotus",
+	"juliett": "sunset",
+}
+
+// RiotImport is what a Riot match document says about one of our matches
+type RiotImport struct {
+	RiotMatchID string                             `json:"riot_match_id"`
+	Map         string                             `json:"map"`
+	Result      models.MatchResult                 `json:"result"`
+	Stats       map[string]models.PlayerMatchStats `json:"stats"` // By our user ID
+}
+
+// RiotImportService turns Riot match documents into results and scoreboards
+// for our matches, mapping in-game names to users through their linked Riot IDs
+type RiotImportService struct {
+	db     *sql.DB
+	client RiotClient
+
+	// linkedUsers returns the users with a verified link to riotIDs, keyed by
+	// lower-case "name#tag"
+	linkedUsers func(riotIDs []string) (map[string]string, error)
+}
+
+func NewRiotImportService(db *sql.DB, client RiotClient) *RiotImportService {
+	ris := &RiotImportService{
+		db:     db,
+		client: client,
+	}
+	ris.linkedUsers = ris.queryLinkedUsers
+	return ris
+}
+
+// FetchMatch loads a match document from the Riot API client
+func (ris *RiotImportService) FetchMatch(riotMatchID string) (*models.RiotMatch, error) {
+	if ris.client == nil {
+		return nil, fmt.Errorf("no riot api client configured")
+	}
+	return ris.client.GetMatch(riotMatchID)
+}
+
+// Resolve checks a Riot match document was played by exactly the players and
+// teams of match and extracts the result, map and per-player stats
+func (ris *RiotImportService) Resolve(match *models.Match, doc *models.RiotMatch) (*RiotImport, error) {
+	if !doc.MatchInfo.IsCompleted {
+		return nil, fmt.Errorf("riot match %s is not completed", doc.MatchInfo.MatchID)
+	}
+	if len(match.Team1) == 0 || len(match.Team2) == 0 {
+		return nil, fmt.Errorf("teams have not been set")
+	}
+	if len(doc.Teams) != 2 {
+		return nil, fmt.Errorf("riot match must have two teams, found %d", len(doc.Teams))
+	}
+
+	users, err := ris.usersByRiotID(doc.Players)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every in-game player must be one of ours and every one of ours must have played
+	inMatch := make(map[string]bool, len(match.Players))
+	for _, player := range match.Players {
+		inMatch[player.UserID] = true
+	}
+	riotTeams := make(map[string][]string)
+	byPUUID := make(map[string]string, len(doc.Players))
+	for _, player := range doc.Players {
+		riotID := player.GameName + "#" + player.TagLine
+		userID, ok := users[strings.ToLower(riotID)]
+		if !ok {
+			return nil, fmt.Errorf("%s is not linked to any account", riotID)
+		}
+		if !inMatch[userID] {
+			return nil, fmt.Errorf("%s did not play this match", riotID)
+		}
+		if _, seen := byPUUID[player.PUUID]; seen {
+			return nil, fmt.Errorf("%s appears more than once", riotID)
+		}
+		byPUUID[player.PUUID] = userID
+		riotTeams[player.TeamID] = append(riotTeams[player.TeamID], userID)
+	}
+	if len(byPUUID) != len(match.Players) {
+		return nil, fmt.Errorf("roster mismatch: riot match has %d players, match has %d", len(byPUUID), len(match.Players))
+	}
+
+	// Work out which Riot team is our team1
+	var team1ID, team2ID string
+	for _, team := range doc.Teams {
+		switch {
+		case sameMembers(riotTeams[team.TeamID], match.Team1):
+			team1ID = team.TeamID
+		case sameMembers(riotTeams[team.TeamID], match.Team2):
+			team2ID = team.TeamID
+		}
+	}
+	if team1ID == "" || team2ID == "" {
+		return nil, fmt.Errorf("riot teams do not match the lobby teams")
+	}
+
+	result := models.MatchResult{Winner: models.MatchWinnerTie}
+	for _, team := range doc.Teams {
+		if team.TeamID == team1ID {
+			result.Team1Rounds = team.RoundsWon
+			if team.Won {
+				result.Winner = models.MatchWinnerTeam1
+			}
+		} else {
+			result.Team2Rounds = team.RoundsWon
+			if team.Won {
+				result.Winner = models.MatchWinnerTeam2
+			}
+		}
+	}
+	if err := validateRoundScore(result); err != nil {
+		return nil, err
+	}
+
+	mapName, err := riotMapName(doc.MatchInfo.MapID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := riotPlayerStats(doc, byPUUID, result.Team1Rounds+result.Team2Rounds)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RiotImport{
+		RiotMatchID: doc.MatchInfo.MatchID,
+		Map:         mapName,
+		Result:      result,
+		Stats:       stats,
+	}, nil
+}
+
+// usersByRiotID looks up the users with a verified link to the players of a
+// Riot match, keyed by lower-case "name#tag"
+func (ris *RiotImportService) usersByRiotID(players []models.RiotPlayer) (map[string]string, error) {
+	riotIDs := make([]string, len(players))
+	for i, player := range players {
+		riotIDs[i] = strings.ToLower(player.GameName + "#" + player.TagLine)
+	}
+	return ris.linkedUsers(riotIDs)
+}
+
+func (ris *RiotImportService) queryLinkedUsers(riotIDs []string) (map[string]string, error) {
+	users := make(map[string]string)
+	if ris.db == nil {
+		return users, nil
+	}
+
+	rows, err := ris.db.Query(`
+        SELECT id::text, LOWER(riot_game_name || '#' || riot_tag_line)
+        FROM users
+        WHERE LOWER(riot_game_name || '#' || riot_tag_line) = ANY($1)
+          AND riot_verified_at IS NOT NULL
+    `, pq.Array(riotIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up riot ids: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, riotID string
+		if err := rows.Scan(&userID, &riotID); err != nil {
+			return nil, fmt.Errorf("failed to scan riot id: %w", err)
+		}
+		users[riotID] = userID
+	}
+	return users, rows.Err()
+}
+
+// riotMapName turns a Riot map path like "/Game/Maps/Ascent/Ascent" into one
+// of models.ValorantMaps
+func riotMapName(mapID string) (string, error) {
+	parts := strings.Split(strings.Trim(mapID, "/"), "/")
+	internal := strings.ToLower(parts[len(parts)-1])
+	if name, ok := riotMaps[internal]; ok {
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown map %q", mapID)
+}
+
+// riotPlayerStats builds a scoreboard line per player. ACS is score per
+// round, headshot percentage comes from round damage and a first blood is
+// the earliest kill of a round.
+func riotPlayerStats(doc *models.RiotMatch, byPUUID map[string]string, totalRounds int) (map[string]models.PlayerMatchStats, error) {
+	firstBloods := make(map[string]int)
+	shots := make(map[string][2]int) // puuid -> {headshots, all shots}
+	for _, round := range doc.RoundResults {
+		var first *models.RiotKill
+		for _, playerStats := range round.PlayerStats {
+			for i := range playerStats.Kills {
+				kill := &playerStats.Kills[i]
+				if first == nil || kill.TimeSinceRoundStartMillis < first.TimeSinceRoundStartMillis {
+					first = kill
+				}
+			}
+			for _, damage := range playerStats.Damage {
+				counts := shots[playerStats.PUUID]
+				counts[0] += damage.Headshots
+				counts[1] += damage.Headshots + damage.Bodyshots + damage.Legshots
+				shots[playerStats.PUUID] = counts
+			}
+		}
+		if first != nil {
+			firstBloods[first.Killer]++
+		}
+	}
+
+	stats := make(map[string]models.PlayerMatchStats, len(doc.Players))
+	for _, player := range doc.Players {
+		userID := byPUUID[player.PUUID]
+		if player.Stats == nil {
+			return nil, fmt.Errorf("no stats for %s#%s", player.GameName, player.TagLine)
+		}
+
+		line := models.PlayerMatchStats{
+			UserID:      userID,
+			Agent:       riotAgentName(player.CharacterID),
+			Kills:       player.Stats.Kills,
+			Deaths:      player.Stats.Deaths,
+			Assists:     player.Stats.Assists,
+			FirstBloods: firstBloods[player.PUUID],
+		}
+		if player.Stats.RoundsPlayed > 0 {
+			line.ACS = math.Round(float64(player.Stats.Score)/float64(player.Stats.RoundsPlayed)*10) / 10
+		}
+		if counts := shots[player.PUUID]; counts[1] > 0 {
+			line.HeadshotPercent = math.Round(float64(counts[0])/float64(counts[1])*1000) / 10
+		}
+
+		if err := validatePlayerStats(&line, totalRounds); err != nil {
+			return nil, fmt.Errorf("invalid stats for %s#%s: %w", player.GameName, player.TagLine, err)
+		}
+		stats[userID] = line
+	}
+	return stats, nil
+}
+
+// riotAgentName maps a character ID to an agent, accepting agent names as is
+func riotAgentName(characterID string) string {
+	if name, ok := riotAgents[strings.ToLower(characterID)]; ok {
+		return name
+	}
+	return characterID
+}
+
+// sameMembers reports whether two lists hold the same user IDs
+func sameMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+const ascentFixture = "../../fixtures/riot/matches/fixture-ascent-13-11.json"
+
+// loadRiotFixture reads a Riot match document from the fixtures directory
+func loadRiotFixture(t *testing.T, path string) *models.RiotMatch {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var doc models.RiotMatch
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decode fixture: %v", err)
+	}
+	return &doc
+}
+
+// fixtureImportService links FixturePlayerN#TEST to user-N, without a database
+func fixtureImportService() *RiotImportService {
+	ris := NewRiotImportService(nil, nil)
+	ris.linkedUsers = func(riotIDs []string) (map[string]string, error) {
+		users := make(map[string]string)
+		for _, riotID := range riotIDs {
+			var n int
+			if _, err := fmt.Sscanf(riotID, "fixtureplayer%d#test", &n); err == nil {
+				users[riotID] = fmt.Sprintf("user-%d", n)
+			}
+		}
+		return users, nil
+	}
+	return ris
+}
+
+// fixtureMatch is a match between user-1..5, Red in the fixture, and
+// user-6..10, Blue
+func fixtureMatch(team1, team2 []string) *models.Match {
+	match := &models.Match{ID: "match-1", Status: models.MatchStatusOngoing, Team1: team1, Team2: team2}
+	for _, userID := range append(append([]string{}, team1...), team2...) {
+		match.Players = append(match.Players, models.MatchPlayer{UserID: userID})
+	}
+	return match
+}
+
+func users(from, to int) []string {
+	var ids []string
+	for n := from; n <= to; n++ {
+		ids = append(ids, fmt.Sprintf("user-%d", n))
+	}
+	return ids
+}
+
+func TestResolveMatchingRoster(t *testing.T) {
+	doc := loadRiotFixture(t, ascentFixture)
+	ris := fixtureImportService()
+
+	tests := []struct {
+		name         string
+		match        *models.Match
+		winner       string
+		team1, team2 int
+	}{
+		{"red is team1", fixtureMatch(users(1, 5), users(6, 10)), models.MatchWinnerTeam1, 13, 11},
+		{"blue is team1", fixtureMatch(users(6, 10), users(1, 5)), models.MatchWinnerTeam2, 11, 13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imported, err := ris.Resolve(tt.match, doc)
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if imported.RiotMatchID != "fixture-ascent-13-11" {
+				t.Errorf("riot match id = %q", imported.RiotMatchID)
+			}
+			if imported.Map != "ascent" {
+				t.Errorf("map = %q, want ascent", imported.Map)
+			}
+			result := imported.Result
+			if result.Winner != tt.winner || result.Team1Rounds != tt.team1 || result.Team2Rounds != tt.team2 {
+				t.Errorf("result = %s %d-%d, want %s %d-%d", result.Winner, result.Team1Rounds, result.Team2Rounds,
+					tt.winner, tt.team1, tt.team2)
+			}
+			if len(imported.Stats) != 10 {
+				t.Fatalf("stats for %d players, want 10", len(imported.Stats))
+			}
+		})
+	}
+}
+
+func TestResolveMapsAgentsAndStats(t *testing.T) {
+	doc := loadRiotFixture(t, ascentFixture)
+	imported, err := fixtureImportService().Resolve(fixtureMatch(users(1, 5), users(6, 10)), doc)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	agents := map[string]string{
+		"user-1": "jett", "user-2": "sage", "user-3": "sova", "user-4": "omen", "user-5": "killjoy",
+		"user-6": "reyna", "user-7": "raze", "user-8": "brimstone", "user-9": "fade", "user-10": "chamber",
+	}
+	for userID, agent := range agents {
+		if got := imported.Stats[userID].Agent; got != agent {
+			t.Errorf("%s agent = %q, want %q", userID, got, agent)
+		}
+	}
+
+	line := imported.Stats["user-3"]
+	if line.UserID != "user-3" || line.Kills != 6 || line.Deaths != 4 || line.Assists != 2 {
+		t.Errorf("user-3 scoreboard = %d/%d/%d", line.Kills, line.Deaths, line.Assists)
+	}
+	if line.ACS != 160 {
+		t.Errorf("user-3 acs = %v, want 160", line.ACS)
+	}
+}
+
+func TestResolveMismatchingRoster(t *testing.T) {
+	tests := []struct {
+		name  string
+		match *models.Match
+		edit  func(doc *models.RiotMatch)
+		want  string
+	}{
+		{
+			name:  "player not in match",
+			match: fixtureMatch(append(users(1, 4), "user-11"), users(6, 10)),
+			want:  "FixturePlayer5#TEST did not play this match",
+		},
+		{
+			name:  "player missing from riot match",
+			match: fixtureMatch(users(1, 5), users(6, 10)),
+			edit:  func(doc *models.RiotMatch) { doc.Players = doc.Players[:9] },
+			want:  "roster mismatch",
+		},
+		{
+			name:  "unlinked player",
+			match: fixtureMatch(users(1, 5), users(6, 10)),
+			edit:  func(doc *models.RiotMatch) { doc.Players[0].GameName = "Stranger" },
+			want:  "Stranger#TEST is not linked to any account",
+		},
+		{
+			name:  "teams swapped around",
+			match: fixtureMatch(append(users(1, 4), "user-6"), append([]string{"user-5"}, users(7, 10)...)),
+			want:  "riot teams do not match the lobby teams",
+		},
+		{
+			name:  "unknown map",
+			match: fixtureMatch(users(1, 5), users(6, 10)),
+			edit:  func(doc *models.RiotMatch) { doc.MatchInfo.MapID = "/Game/Maps/Range/Range" },
+			want:  "unknown map",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := loadRiotFixture(t, ascentFixture)
+			if tt.edit != nil {
+				tt.edit(doc)
+			}
+			_, err := fixtureImportService().Resolve(tt.match, doc)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Resolve error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRiotMapName(t *testing.T) {
+	tests := map[string]string{
+		"/Game/Maps/Ascent/Ascent":   "ascent",
+		"/Game/Maps/Duality/Duality": "bind",
+		"/Game/Maps/Triad/Triad":     "haven",
+		"/Game/Maps/Port/Port":       "icebox",
+		"/Game/Maps/Juliett/Juliett": "sunset",
+	}
+	for mapID, want := range tests {
+		if got, err := riotMapName(mapID); err != nil || got != want {
+			t.Errorf("riotMapName(%q) = %q, %v, want %q", mapID, got, err, want)
+		}
+	}
+}
+
+func TestRiotAgentName(t *testing.T) {
+	if got := riotAgentName("ADD6443A-41BD-E414-F6AD-E58D267F4E95"); got != "jett" {
+		t.Errorf("upper-case character id = %q, want jett", got)
+	}
+	if got := riotAgentName("viper"); got != "viper" {
+		t.Errorf("agent name = %q, want it kept as is", got)
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// Keys of the runtime_state rows
+const (
+	runtimeStateQueue      = "queue"
+	runtimeStateMatchRooms = "match_rooms"
+)
+
+// RuntimeStateService saves the in-memory queue and match rooms when the
+// server shuts down and restores them when it starts again, so a deploy
+// doesn't cancel every lobby
+type RuntimeStateService struct {
+	db               *sql.DB
+	queueService     *QueueService
+	matchRoomService *MatchRoomService
+	logger           *slog.Logger
+}
+
+func NewRuntimeStateService(db *sql.DB, queueService *QueueService, matchRoomService *MatchRoomService, logger *slog.Logger) *RuntimeStateService {
+	return &RuntimeStateService{
+		db:               db,
+		queueService:     queueService,
+		matchRoomService: matchRoomService,
+		logger:           logger,
+	}
+}
+
+// Save writes the queue and every match room, replacing any earlier save
+func (rs *RuntimeStateService) Save(ctx context.Context) error {
+	queue, err := rs.queueService.Snapshot()
+	if err != nil {
+		return fmt.Errorf("failed to snapshot queue: %w", err)
+	}
+	rooms, err := rs.matchRoomService.Snapshot()
+	if err != nil {
+		return fmt.Errorf("failed to snapshot match rooms: %w", err)
+	}
+
+	tx, err := rs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save runtime state: %w", err)
+	}
+	defer tx.Rollback()
+
+	for key, data := range map[string][]byte{runtimeStateQueue: queue, runtimeStateMatchRooms: rooms} {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO runtime_state (key, data, saved_at) VALUES ($1, $2, NOW())
+            ON CONFLICT (key) DO UPDATE SET data = EXCLUDED.data, saved_at = EXCLUDED.saved_at
+        `, key, data)
+		if err != nil {
+			return fmt.Errorf("failed to save %s: %w", key, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save runtime state: %w", err)
+	}
+
+	queued, substitutes := rs.queueService.Depths()
+	rs.logger.Info("runtime state saved", "queued", queued, "substitutes", substitutes,
+		"match_rooms", len(rs.matchRoomService.ListActiveRooms()))
+	return nil
+}
+
+// Restore loads the last save into the queue and match rooms and deletes
+// it, so a later crash can't bring back stale state
+func (rs *RuntimeStateService) Restore(ctx context.Context) error {
+	tx, err := rs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to load runtime state: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `DELETE FROM runtime_state RETURNING key, data, saved_at`)
+	if err != nil {
+		return fmt.Errorf("failed to load runtime state: %w", err)
+	}
+	saved := make(map[string][]byte)
+	for rows.Next() {
+		var key string
+		var data []byte
+		var savedAt sql.NullTime
+		if err := rows.Scan(&key, &data, &savedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to load runtime state: %w", err)
+		}
+		saved[key] = data
+		rs.logger.Debug("found saved runtime state", "key", key, "saved_at", savedAt.Time)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load runtime state: %w", err)
+	}
+
+	// Rooms first so restored players aren't matched again before their
+	// match is back
+	if data, ok := saved[runtimeStateMatchRooms]; ok {
+		if _, err := rs.matchRoomService.Restore(data); err != nil {
+			rs.logger.Error("could not restore match rooms", "error", err)
+		}
+	}
+	if data, ok := saved[runtimeStateQueue]; ok {
+		if _, err := rs.queueService.Restore(data); err != nil {
+			rs.logger.Error("could not restore queue", "error", err)
+		}
+	}
+
+	return tx.Commit()
+}
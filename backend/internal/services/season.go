@@ -0,0 +1,321 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// Season defaults, overridable with SEASON_LENGTH_DAYS and SEASON_SOFT_RESET
+const (
+	DefaultSeasonLength    = 90 * 24 * time.Hour
+	DefaultSeasonSoftReset = 0.5
+	seasonResetTarget      = 1000
+)
+
+// SeasonOptions configures how seasons roll over
+type SeasonOptions struct {
+	Length          time.Duration
+	SoftResetFactor float64 // 0 keeps ratings, 1 resets everyone to the target
+}
+
+// SeasonService manages competitive seasons: per-season records, the soft
+// reset at rollover and the permanent end-of-season standings
+type SeasonService struct {
+	db      *sql.DB
+	tiers   *TierService
+	options SeasonOptions
+	logger  *slog.Logger
+}
+
+func NewSeasonService(db *sql.DB, tiers *TierService, options SeasonOptions, logger *slog.Logger) *SeasonService {
+	if options.Length <= 0 {
+		options.Length = DefaultSeasonLength
+	}
+	return &SeasonService{
+		db:      db,
+		tiers:   tiers,
+		options: options,
+		logger:  logger,
+	}
+}
+
+const seasonColumns = `id, name, starts_at, ends_at, soft_reset_factor, reset_target, status, created_at`
+
+func scanSeason(row interface{ Scan(...interface{}) error }) (*models.Season, error) {
+	var season models.Season
+	err := row.Scan(&season.ID, &season.Name, &season.StartsAt, &season.EndsAt,
+		&season.SoftResetFactor, &season.ResetTarget, &season.Status, &season.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &season, nil
+}
+
+// CurrentSeason returns the active season
+func (ss *SeasonService) CurrentSeason() (*models.Season, error) {
+	season, err := scanSeason(ss.db.QueryRow(`SELECT ` + seasonColumns + ` FROM seasons WHERE status = 'active' ORDER BY id DESC LIMIT 1`))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no active season")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load current season: %w", err)
+	}
+	return season, nil
+}
+
+// GetSeason returns a season by ID
+func (ss *SeasonService) GetSeason(seasonID int) (*models.Season, error) {
+	season, err := scanSeason(ss.db.QueryRow(`SELECT `+seasonColumns+` FROM seasons WHERE id = $1`, seasonID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("season not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load season: %w", err)
+	}
+	return season, nil
+}
+
+// ListSeasons returns every season, newest first
+func (ss *SeasonService) ListSeasons() ([]models.Season, error) {
+	rows, err := ss.db.Query(`SELECT ` + seasonColumns + ` FROM seasons ORDER BY id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seasons: %w", err)
+	}
+	defer rows.Close()
+
+	seasons := []models.Season{}
+	for rows.Next() {
+		season, err := scanSeason(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan season: %w", err)
+		}
+		seasons = append(seasons, *season)
+	}
+	return seasons, rows.Err()
+}
+
+// EnsureCurrentSeason starts the first season if there is none and rolls
+// over the active season once its end date has passed
+func (ss *SeasonService) EnsureCurrentSeason(now time.Time) (*models.Season, error) {
+	season, err := ss.CurrentSeason()
+	if err != nil {
+		var count int
+		if countErr := ss.db.QueryRow(`SELECT COUNT(*) FROM seasons`).Scan(&count); countErr != nil {
+			return nil, fmt.Errorf("failed to count seasons: %w", countErr)
+		}
+		if count > 0 {
+			return nil, err
+		}
+		return ss.createFirstSeason(now)
+	}
+
+	for !now.Before(season.EndsAt) {
+		season, err = ss.Rollover(season)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return season, nil
+}
+
+// createFirstSeason starts the first season unless another instance
+// started it first
+func (ss *SeasonService) createFirstSeason(now time.Time) (*models.Season, error) {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start first season: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Held until the transaction ends, so instances starting together
+	// create the first season one after the other
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('seasons'))`); err != nil {
+		return nil, fmt.Errorf("failed to lock seasons: %w", err)
+	}
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM seasons`).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count seasons: %w", err)
+	}
+	if count > 0 {
+		tx.Rollback()
+		return ss.CurrentSeason()
+	}
+
+	season, err := ss.createSeason(tx, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit first season: %w", err)
+	}
+	return season, nil
+}
+
+// Rollover ends season: final ranks are stored in season_standings, every
+// rating is pulled toward the reset target by the season's soft reset factor,
+// tiers follow the new ratings and the next season starts where this one
+// ended. If the season was already
+// ended, e.g. by another instance, nothing changes and the current season is
+// returned.
+func (ss *SeasonService) Rollover(season *models.Season) (*models.Season, error) {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start season rollover: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Ending the season first claims the rollover: a concurrent one waits
+	// for this transaction and then finds the season no longer active
+	result, err := tx.Exec(`UPDATE seasons SET status = 'ended' WHERE id = $1 AND status = 'active'`, season.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to end season: %w", err)
+	}
+	if ended, _ := result.RowsAffected(); ended != 1 {
+		tx.Rollback()
+		ss.logger.Info("season already rolled over", "season", season.Name)
+		return ss.CurrentSeason()
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO season_standings (season_id, user_id, final_rank, final_rating, wins, losses, games_played)
+        SELECT season_id, user_id,
+               ROW_NUMBER() OVER (ORDER BY rating DESC, user_id),
+               rating, wins, losses, games_played
+        FROM season_ratings
+        WHERE season_id = $1
+        ON CONFLICT (season_id, user_id) DO NOTHING
+    `, season.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot season standings: %w", err)
+	}
+
+	// rating = target + (rating - target) * (1 - factor), recorded in the
+	// rating history before users is updated
+	_, err = tx.Exec(`
+        INSERT INTO rating_history (user_id, reason, rating_before, rating_after, delta, created_at)
+        SELECT id, $3, elo, reset, reset - elo, NOW()
+        FROM (SELECT id, elo, ROUND($1::int + (elo - $1::int) * (1 - $2::float))::int AS reset FROM users) r
+        WHERE reset <> elo
+    `, season.ResetTarget, season.SoftResetFactor, models.RatingChangeSeasonReset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record soft reset: %w", err)
+	}
+
+	_, err = tx.Exec(`
+        UPDATE users
+        SET elo = ROUND($1::int + (elo - $1::int) * (1 - $2::float)), updated_at = NOW()
+    `, season.ResetTarget, season.SoftResetFactor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to soft reset ratings: %w", err)
+	}
+	if ss.tiers != nil {
+		if err := ss.tiers.ResetTiers(tx, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	next, err := ss.createSeason(tx, season.EndsAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit season rollover: %w", err)
+	}
+	if ss.tiers != nil {
+		if err := ss.tiers.RefreshRadiant(); err != nil {
+			ss.logger.Warn("could not refresh radiant players", "error", err)
+		}
+	}
+
+	ss.logger.Info("season rolled over", "ended", season.Name, "started", next.Name,
+		"soft_reset_factor", season.SoftResetFactor, "reset_target", season.ResetTarget)
+	return next, nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (ss *SeasonService) createSeason(db execer, startsAt time.Time) (*models.Season, error) {
+	var number int
+	if err := db.QueryRow(`SELECT COUNT(*) + 1 FROM seasons`).Scan(&number); err != nil {
+		return nil, fmt.Errorf("failed to number season: %w", err)
+	}
+
+	season, err := scanSeason(db.QueryRow(`
+        INSERT INTO seasons (name, starts_at, ends_at, soft_reset_factor, reset_target, status)
+        VALUES ($1, $2, $3, $4, $5, 'active')
+        RETURNING `+seasonColumns,
+		fmt.Sprintf("Season %d", number), startsAt, startsAt.Add(ss.options.Length),
+		ss.options.SoftResetFactor, seasonResetTarget))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create season: %w", err)
+	}
+	return season, nil
+}
+
+// RecordResults adds the rating changes of a match to the active season.
+// before and after must hold the same players in the same order.
+func (ss *SeasonService) RecordResults(tx *sql.Tx, before, after []models.Elo) error {
+	for i := range after {
+		_, err := tx.Exec(`
+            INSERT INTO season_ratings (season_id, user_id, rating, wins, losses, games_played)
+            SELECT s.id, u.id, $2, $3, $4, 1
+            FROM seasons s, users u
+            WHERE s.status = 'active' AND u.id::text = $1
+            ON CONFLICT (season_id, user_id) DO UPDATE SET
+                rating = EXCLUDED.rating,
+                wins = season_ratings.wins + EXCLUDED.wins,
+                losses = season_ratings.losses + EXCLUDED.losses,
+                games_played = season_ratings.games_played + 1,
+                updated_at = NOW()
+        `, after[i].UserID, int(math.Round(after[i].Rating)),
+			after[i].Wins-before[i].Wins, after[i].Losses-before[i].Losses)
+		if err != nil {
+			return fmt.Errorf("failed to record season result for %s: %w", after[i].UserID, err)
+		}
+	}
+	return nil
+}
+
+// GetUserSeasons returns a player's record in every season they played
+func (ss *SeasonService) GetUserSeasons(userID string) ([]models.SeasonRating, error) {
+	rows, err := ss.db.Query(`
+        SELECT sr.season_id, s.name, sr.user_id::text, sr.rating, sr.wins, sr.losses, sr.games_played, st.final_rank
+        FROM season_ratings sr
+        JOIN seasons s ON s.id = sr.season_id
+        LEFT JOIN season_standings st ON st.season_id = sr.season_id AND st.user_id = sr.user_id
+        WHERE sr.user_id::text = $1
+        ORDER BY sr.season_id DESC
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user seasons: %w", err)
+	}
+	defer rows.Close()
+
+	seasons := []models.SeasonRating{}
+	for rows.Next() {
+		var record models.SeasonRating
+		var finalRank sql.NullInt64
+		if err := rows.Scan(&record.SeasonID, &record.SeasonName, &record.UserID, &record.Rating,
+			&record.Wins, &record.Losses, &record.GamesPlayed, &finalRank); err != nil {
+			return nil, fmt.Errorf("failed to scan user season: %w", err)
+		}
+		if finalRank.Valid {
+			rank := int(finalRank.Int64)
+			record.FinalRank = &rank
+		}
+		if record.Wins+record.Losses > 0 {
+			record.WinRate = math.Round(float64(record.Wins)/float64(record.Wins+record.Losses)*10000) / 100
+		}
+		seasons = append(seasons, record)
+	}
+	return seasons, rows.Err()
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// ErrSharedStateUnavailable is returned by changes to shared state while the
+// other instances' changes can't be loaded, the cluster-wide lock taken or
+// the change stored. The change was not made.
+var ErrSharedStateUnavailable = errors.New("shared state is temporarily unavailable, please try again")
+
+// sharedMutex guards in-memory state that may be shared with other backend
+// instances. Without a coordinator it is a plain RWMutex. Once shared, Lock
+// also takes the cluster-wide lock and loads what other instances stored,
+// and Unlock stores the state if it changed. RLock stays local, so reads see
+// other instances' changes once the coordinator reports them.
+//
+// Storing is part of the change: Unlock reports a change that could not be
+// stored through the caller's error, after undoing it, so callers unlock with
+//
+//	defer sm.Unlock(&err)
+//
+// and must not report success before Unlock ran.
+type sharedMutex struct {
+	local       sync.RWMutex
+	coordinator Coordinator
+	name        string
+	encode      func() ([]byte, error) // Called with the local lock held
+	decode      func([]byte) error     // Replaces the state, called with the local lock held
+	logger      *slog.Logger
+
+	revision int64          // Of the state last loaded or stored
+	unlock   func()         // Releases the cluster-wide lock
+	before   []byte         // State when the lock was taken
+	outer    *sharedMutex   // Set while locked as part of outer's change, see lockWithin
+	inner    []*sharedMutex // Locked as part of this change
+}
+
+// share starts sharing the state under name. It loads the state other
+// instances stored, if any, and must be called before the state is used.
+func (sm *sharedMutex) share(coordinator Coordinator, name string, encode func() ([]byte, error), decode func([]byte) error, logger *slog.Logger) {
+	sm.local.Lock()
+	defer sm.local.Unlock()
+
+	sm.coordinator = coordinator
+	sm.name = name
+	sm.encode = encode
+	sm.decode = decode
+	sm.logger = logger
+
+	sm.load()
+	coordinator.Watch(name, func() {
+		sm.local.Lock()
+		defer sm.local.Unlock()
+		sm.load()
+	})
+}
+
+// load replaces the state with a newer one stored by another instance.
+// Must be called with the local lock held.
+func (sm *sharedMutex) load() error {
+	data, revision, err := sm.coordinator.Load(context.Background(), sm.name, sm.revision)
+	if err != nil {
+		sm.logger.Error("could not load shared state", "name", sm.name, "error", err)
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+	if err := sm.decode(data); err != nil {
+		sm.logger.Error("could not decode shared state", "name", sm.name, "revision", revision, "error", err)
+		return err
+	}
+	sm.revision = revision
+	sm.logger.Debug("shared state loaded", "name", sm.name, "revision", revision)
+	return nil
+}
+
+// Lock takes the lock for a change. Once shared it fails, leaving no lock
+// held, when the cluster-wide lock can't be taken or the latest state can't
+// be loaded: changing a stale copy would overwrite other instances' changes.
+func (sm *sharedMutex) Lock() error {
+	sm.local.Lock()
+	if sm.coordinator == nil {
+		return nil
+	}
+
+	unlock, err := sm.coordinator.Lock(context.Background(), sm.name)
+	if err != nil {
+		sm.local.Unlock()
+		sm.logger.Error("could not take shared lock", "name", sm.name, "error", err)
+		return ErrSharedStateUnavailable
+	}
+	if err := sm.load(); err != nil {
+		unlock()
+		sm.local.Unlock()
+		return ErrSharedStateUnavailable
+	}
+	sm.before, err = sm.encode()
+	if err != nil {
+		unlock()
+		sm.local.Unlock()
+		sm.logger.Error("could not encode shared state", "name", sm.name, "error", err)
+		return ErrSharedStateUnavailable
+	}
+	sm.unlock = unlock
+	return nil
+}
+
+// lockWithin takes the lock as part of the change outer, locked by the
+// caller, is making. The state is then stored with outer's in one
+// transaction and the lock is held until outer unlocks, so either both
+// changes are kept or neither is. Locks must always nest in the same order.
+func (sm *sharedMutex) lockWithin(outer *sharedMutex) error {
+	if slices.Contains(outer.inner, sm) {
+		return nil
+	}
+	if err := sm.Lock(); err != nil {
+		return err
+	}
+	sm.outer = outer
+	outer.inner = append(outer.inner, sm)
+	return nil
+}
+
+// Unlock stores the state, and that of the locks taken within this one, if
+// it changed and releases the locks. If it can't be stored every change is
+// undone and *err is set to ErrSharedStateUnavailable, whatever the change
+// returned. Locks taken with lockWithin are released by their outer lock.
+func (sm *sharedMutex) Unlock(err *error) {
+	if sm.outer != nil {
+		return
+	}
+
+	locks := append([]*sharedMutex{sm}, sm.inner...)
+	if sm.coordinator != nil {
+		if storeErr := sm.store(locks); storeErr != nil {
+			for _, lock := range locks {
+				if err := lock.decode(lock.before); err != nil {
+					lock.logger.Error("could not undo unstored change to shared state", "name", lock.name, "error", err)
+				}
+			}
+			*err = ErrSharedStateUnavailable
+		}
+	}
+
+	for i := len(locks) - 1; i >= 0; i-- {
+		lock := locks[i]
+		if lock.unlock != nil {
+			lock.unlock()
+		}
+		lock.unlock, lock.before, lock.outer, lock.inner = nil, nil, nil, nil
+		lock.local.Unlock()
+	}
+}
+
+// store saves, in one transaction, the states of locks that changed while
+// they were held
+func (sm *sharedMutex) store(locks []*sharedMutex) error {
+	changed := make(map[string][]byte)
+	byName := make(map[string]*sharedMutex)
+	for _, lock := range locks {
+		after, err := lock.encode()
+		if err != nil {
+			lock.logger.Error("could not encode shared state", "name", lock.name, "error", err)
+			return err
+		}
+		if !bytes.Equal(lock.before, after) {
+			changed[lock.name] = after
+			byName[lock.name] = lock
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	revisions, err := sm.coordinator.Store(context.Background(), changed)
+	if err != nil {
+		sm.logger.Error("could not store shared state, undoing the change", "name", sm.name, "error", err)
+		return err
+	}
+	for name, revision := range revisions {
+		byName[name].revision = revision
+	}
+	return nil
+}
+
+func (sm *sharedMutex) RLock() {
+	sm.local.RLock()
+}
+
+func (sm *sharedMutex) RUnlock() {
+	sm.local.RUnlock()
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// RequestSubstitute lets a captain replace a player who left the match. The
+// queued player with the closest ELO is offered the spot and has to accept
+// it; the leaver stays in the match until someone does.
+func (mrs *MatchRoomService) RequestSubstitute(matchID, requesterID, leaverID string) (_ *models.Match, err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer mrs.mutex.Unlock(&err)
+
+	match, exists := mrs.rooms[matchID]
+	if !exists {
+		return nil, fmt.Errorf("match room not found")
+	}
+
+	switch match.Status {
+	case models.MatchStatusPending, models.MatchStatusCancelled, models.MatchStatusCompleted, models.MatchStatusReporting:
+		return nil, fmt.Errorf("cannot substitute players in a %s match", match.Status)
+	}
+	if len(match.Team1) == 0 || len(match.Team2) == 0 {
+		return nil, fmt.Errorf("teams have not been set")
+	}
+	if requesterID != match.Captain1 && requesterID != match.Captain2 {
+		return nil, fmt.Errorf("only captains can request a substitute")
+	}
+	if requesterID == leaverID {
+		return nil, fmt.Errorf("captains cannot request a substitute for themselves")
+	}
+	if !isMatchPlayer(match, leaverID) {
+		return nil, fmt.Errorf("player not in match")
+	}
+
+	if err := mrs.offerSubstitute(match, leaverID, requesterID); err != nil {
+		return nil, err
+	}
+	return match, nil
+}
+
+// offerSubstitute offers the spot of leaverID to the best candidate. Must be
+// called with mrs.mutex held.
+func (mrs *MatchRoomService) offerSubstitute(match *models.Match, leaverID, requesterID string) error {
+	for _, offer := range match.SubstituteOffers {
+		if offer.LeaverID == leaverID {
+			return fmt.Errorf("a substitute has already been requested for this player")
+		}
+	}
+
+	offer := models.SubstituteOffer{
+		LeaverID:    leaverID,
+		RequestedBy: requesterID,
+	}
+	if !mrs.nextSubstituteCandidate(match, &offer, time.Now()) {
+		return fmt.Errorf("no substitutes available")
+	}
+	match.SubstituteOffers = append(match.SubstituteOffers, offer)
+	match.UpdatedAt = time.Now()
+	return nil
+}
+
+// nextSubstituteCandidate points offer at the closest ELO player who is not
+// playing or being asked to substitute elsewhere and has not declined it.
+// Returns false when nobody is left. Must be called with mrs.mutex held.
+func (mrs *MatchRoomService) nextSubstituteCandidate(match *models.Match, offer *models.SubstituteOffer, now time.Time) bool {
+	leaverELO := 0
+	for _, player := range match.Players {
+		if player.UserID == offer.LeaverID {
+			leaverELO = player.ELO
+		}
+	}
+
+	exclude := make(map[string]bool)
+	for _, userID := range offer.Declined {
+		exclude[userID] = true
+	}
+	for _, room := range mrs.rooms {
+		if room.Status == models.MatchStatusCancelled || room.Status == models.MatchStatusCompleted {
+			continue
+		}
+		for _, player := range room.Players {
+			exclude[player.UserID] = true
+		}
+		for _, other := range room.SubstituteOffers {
+			exclude[other.CandidateID] = true
+		}
+	}
+
+	candidate, found := mrs.queueService.FindSubstitute(leaverELO, exclude)
+	if !found {
+		return false
+	}
+
+	offer.CandidateID = candidate.UserID
+	offer.OfferedAt = now
+	offer.ExpiresAt = now.Add(mrs.options.SubstituteOfferTimeout)
+
+	mrs.logger.Info("substitute offered", "match_id", match.ID, "candidate_id", candidate.UserID,
+		"candidate_elo", candidate.ELO, "leaver_id", offer.LeaverID, "leaver_elo", leaverELO)
+	return true
+}
+
+// AcceptSubstitute swaps a player who accepted a substitute offer into the
+// leaver's spot on the same team. A leaving captain hands the captaincy to
+// their highest ELO teammate, and a leaving lobby host means the lobby has
+// to be set up again.
+func (mrs *MatchRoomService) AcceptSubstitute(matchID, userID string) (_ *models.Match, _ *models.Substitution, err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return nil, nil, err
+	}
+	defer mrs.mutex.Unlock(&err)
+
+	match, exists := mrs.rooms[matchID]
+	if !exists {
+		return nil, nil, fmt.Errorf("match room not found")
+	}
+
+	index := -1
+	for i, offer := range match.SubstituteOffers {
+		if offer.CandidateID == userID {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, nil, fmt.Errorf("no substitute offer for this player")
+	}
+	offer := match.SubstituteOffers[index]
+
+	now := time.Now()
+	if now.After(offer.ExpiresAt) {
+		mrs.declineOffer(match, index, now)
+		return nil, nil, fmt.Errorf("the substitute offer has expired")
+	}
+
+	entry, err := mrs.queueService.takeSubstitute(userID, &mrs.mutex)
+	if err != nil {
+		mrs.declineOffer(match, index, now)
+		return nil, nil, err
+	}
+
+	leaverIndex := -1
+	for i, player := range match.Players {
+		if player.UserID == offer.LeaverID {
+			leaverIndex = i
+		}
+	}
+	if leaverIndex < 0 {
+		match.SubstituteOffers = append(match.SubstituteOffers[:index], match.SubstituteOffers[index+1:]...)
+		return nil, nil, fmt.Errorf("player %s is no longer in the match", offer.LeaverID)
+	}
+	leaver := match.Players[leaverIndex]
+
+	match.Players[leaverIndex] = models.MatchPlayer{
+		UserID:      entry.UserID,
+		Username:    entry.Username,
+		ELO:         entry.ELO,
+		Accepted:    true,
+		Provisional: entry.Provisional,
+		Tier:        entry.Tier,
+		RiotID:      entry.RiotID,
+		Team:        leaver.Team,
+		Role:        "player",
+	}
+
+	team := models.MatchWinnerTeam1
+	for i, id := range match.Team2 {
+		if id == leaver.UserID {
+			match.Team2[i] = entry.UserID
+			team = models.MatchWinnerTeam2
+		}
+	}
+	for i, id := range match.Team1 {
+		if id == leaver.UserID {
+			match.Team1[i] = entry.UserID
+		}
+	}
+
+	if leaver.UserID == match.Captain1 {
+		match.Captain1 = promoteCaptain(match, leaver.Team, entry.UserID)
+	}
+	if leaver.UserID == match.Captain2 {
+		match.Captain2 = promoteCaptain(match, leaver.Team, entry.UserID)
+	}
+
+	// Once the match is under way the substitute joins the running game,
+	// before that they get a fresh deadline to join the lobby
+	if lobby := match.Lobby; lobby != nil && match.Status != models.MatchStatusOngoing {
+		if lobby.HostID == leaver.UserID {
+			// The in-game lobby left with its host
+			match.Lobby = nil
+		} else if lobby.JoinDeadline != nil {
+			delete(lobby.CheckIns, leaver.UserID)
+			deadline := now.Add(mrs.options.LobbyJoinTimeout)
+			if deadline.After(*lobby.JoinDeadline) {
+				lobby.JoinDeadline = &deadline
+			}
+		}
+	}
+
+	substitution := models.Substitution{
+		LeaverID:       leaver.UserID,
+		LeaverUsername: leaver.Username,
+		SubstituteID:   entry.UserID,
+		Team:           team,
+		RequestedBy:    offer.RequestedBy,
+		SubstitutedAt:  now,
+	}
+	match.Substitutions = append(match.Substitutions, substitution)
+	match.SubstituteOffers = append(match.SubstituteOffers[:index], match.SubstituteOffers[index+1:]...)
+	match.UpdatedAt = now
+
+	mrs.logger.Info("player substituted", "match_id", matchID, "substitute_id", entry.UserID,
+		"leaver_id", leaver.UserID, "team", team)
+	return match, &substitution, nil
+}
+
+// promoteCaptain makes the highest ELO player of a team its captain and
+// returns their ID. The substitute only captains a team of one. Must be
+// called with mrs.mutex held.
+func promoteCaptain(match *models.Match, team, substituteID string) string {
+	captain := -1
+	for i, player := range match.Players {
+		if player.Team != team || player.UserID == substituteID {
+			continue
+		}
+		if captain < 0 || player.ELO > match.Players[captain].ELO {
+			captain = i
+		}
+	}
+	if captain < 0 {
+		for i, player := range match.Players {
+			if player.UserID == substituteID {
+				captain = i
+			}
+		}
+	}
+	match.Players[captain].Role = "captain"
+	return match.Players[captain].UserID
+}
+
+// DeclineSubstitute turns down a substitute offer, which moves on to the next candidate
+func (mrs *MatchRoomService) DeclineSubstitute(matchID, userID string) (_ *models.Match, err error) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer mrs.mutex.Unlock(&err)
+
+	match, exists := mrs.rooms[matchID]
+	if !exists {
+		return nil, fmt.Errorf("match room not found")
+	}
+
+	for i, offer := range match.SubstituteOffers {
+		if offer.CandidateID == userID {
+			mrs.logger.Info("substitute declined", "match_id", matchID, "candidate_id", userID)
+			mrs.declineOffer(match, i, time.Now())
+			return match, nil
+		}
+	}
+	return nil, fmt.Errorf("no substitute offer for this player")
+}
+
+// declineOffer asks the next candidate or drops the offer when nobody is
+// left. Must be called with mrs.mutex held.
+func (mrs *MatchRoomService) declineOffer(match *models.Match, index int, now time.Time) {
+	offer := &match.SubstituteOffers[index]
+	offer.Declined = append(offer.Declined, offer.CandidateID)
+	if !mrs.nextSubstituteCandidate(match, offer, now) {
+		mrs.logger.Info("no substitute found", "match_id", match.ID, "leaver_id", offer.LeaverID)
+		match.SubstituteOffers = append(match.SubstituteOffers[:index], match.SubstituteOffers[index+1:]...)
+	}
+	match.UpdatedAt = now
+}
+
+// ExpireSubstituteOffers moves every offer that was not answered in time on
+// to the next candidate. Returns how many offers expired.
+func (mrs *MatchRoomService) ExpireSubstituteOffers(now time.Time) (expired int) {
+	if err := mrs.mutex.Lock(); err != nil {
+		return 0
+	}
+	var err error
+	defer func() {
+		mrs.mutex.Unlock(&err)
+		if err != nil {
+			expired = 0
+		}
+	}()
+
+	for _, match := range mrs.rooms {
+		for i := len(match.SubstituteOffers) - 1; i >= 0; i-- {
+			if now.Before(match.SubstituteOffers[i].ExpiresAt) {
+				continue
+			}
+			mrs.logger.Info("substitute offer expired", "match_id", match.ID, "candidate_id", match.SubstituteOffers[i].CandidateID)
+			mrs.declineOffer(match, i, now)
+			expired++
+		}
+	}
+	return expired
+}
+
+// GetSubstituteOffer returns the match and offer a player is being asked to substitute in
+func (mrs *MatchRoomService) GetSubstituteOffer(userID string) (*models.Match, *models.SubstituteOffer, error) {
+	mrs.mutex.RLock()
+	defer mrs.mutex.RUnlock()
+
+	for _, match := range mrs.rooms {
+		for i, offer := range match.SubstituteOffers {
+			if offer.CandidateID == userID {
+				return match, &match.SubstituteOffers[i], nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("no substitute offer for this player")
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+	"valorant-mobile-web/backend/internal/models"
+)
+
+// maxExactBalancePlayers bounds the exhaustive team search (2^n splits)
+const maxExactBalancePlayers = 16
+
+// BalanceTeams splits players into two teams whose average ELO is as close as
+// possible. Every split is evaluated, so the result is optimal for the usual
+// 10 player lobby. Players sharing a PartyID are never separated.
+// Returns the indexes of the players in each team.
+func BalanceTeams(players []models.MatchPlayer) ([]int, []int, error) {
+	n := len(players)
+	if n < 2 {
+		return nil, nil, fmt.Errorf("not enough players to balance teams")
+	}
+	if n > maxExactBalancePlayers {
+		return nil, nil, fmt.Errorf("too many players to balance teams: %d", n)
+	}
+
+	// Bitmask of the players in each party
+	parties := make(map[string]uint32)
+	for i, player := range players {
+		if player.PartyID != "" {
+			parties[player.PartyID] |= 1 << uint(i)
+		}
+	}
+
+	team1Size := n / 2
+	all := uint32(1)<<uint(n) - 1
+	bestMask := uint32(0)
+	bestDiff := math.Inf(1)
+	found := false
+
+	// Player 0 is always on team 1 so mirrored splits are only checked once
+	for mask := uint32(1); mask <= all; mask += 2 {
+		size := bits.OnesCount32(mask)
+		if size != team1Size && size != n-team1Size {
+			continue
+		}
+
+		splitsParty := false
+		for _, party := range parties {
+			if inTeam := mask & party; inTeam != 0 && inTeam != party {
+				splitsParty = true
+				break
+			}
+		}
+		if splitsParty {
+			continue
+		}
+
+		sum1, sum2 := 0, 0
+		for i, player := range players {
+			if mask&(1<<uint(i)) != 0 {
+				sum1 += player.ELO
+			} else {
+				sum2 += player.ELO
+			}
+		}
+		diff := math.Abs(float64(sum1)/float64(size) - float64(sum2)/float64(n-size))
+
+		if diff < bestDiff {
+			bestDiff = diff
+			bestMask = mask
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, nil, fmt.Errorf("no team split keeps every party together")
+	}
+
+	var team1, team2 []int
+	for i := range players {
+		if bestMask&(1<<uint(i)) != 0 {
+			team1 = append(team1, i)
+		} else {
+			team2 = append(team2, i)
+		}
+	}
+
+	return team1, team2, nil
+}
+
+// autoBalanceTeams fills both teams from BalanceTeams, makes the highest ELO
+// player of each team its captain and records the predicted win probability.
+// Must be called with mrs.mutex held.
+func (mrs *MatchRoomService) autoBalanceTeams(match *models.Match) error {
+	team1, team2, err := BalanceTeams(match.Players)
+	if err != nil {
+		return err
+	}
+
+	assign := func(indexes []int, team string) ([]string, string, int) {
+		ids := make([]string, 0, len(indexes))
+		captain := indexes[0]
+		sum := 0
+		for _, i := range indexes {
+			ids = append(ids, match.Players[i].UserID)
+			sum += match.Players[i].ELO
+			if match.Players[i].ELO > match.Players[captain].ELO {
+				captain = i
+			}
+		}
+		for _, i := range indexes {
+			match.Players[i].Team = team
+			match.Players[i].Role = "player"
+		}
+		match.Players[captain].Role = "captain"
+		return ids, match.Players[captain].UserID, sum / len(indexes)
+	}
+
+	var team1Avg, team2Avg int
+	match.Team1, match.Captain1, team1Avg = assign(team1, "A")
+	match.Team2, match.Captain2, team2Avg = assign(team2, "B")
+
+	eloService := NewELOService()
+	match.Team1WinProbability = eloService.WinProbability(team1Avg, team2Avg)
+	match.Team2WinProbability = 1 - match.Team1WinProbability
+
+	match.Status = models.MatchStatusMapBan
+	match.UpdatedAt = time.Now()
+
+	mrs.logger.Info("teams auto-balanced", "match_id", match.ID,
+		"team1_average_elo", team1Avg, "team1_win_probability", match.Team1WinProbability,
+		"team2_average_elo", team2Avg, "team2_win_probability", match.Team2WinProbability)
+	return nil
+}
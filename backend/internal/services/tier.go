@@ -0,0 +1,346 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"valorant-mobile-web/backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// Tier defaults, overridable with RADIANT_SLOTS and DEMOTION_SHIELD_GAMES
+const (
+	DefaultRadiantSlots        = 10
+	DefaultDemotionShieldGames = 3
+)
+
+// TierOptions configures the rank tiers
+type TierOptions struct {
+	Tiers            []models.RankTier // Empty uses DefaultTierTable
+	RadiantSlots     int               // Top-N ranked players shown as Radiant
+	ShieldGames      int               // Games after a promotion during which a player cannot be demoted
+	PlacementMatches int
+}
+
+// TierService maps ratings to Valorant-style tiers and keeps each player's
+// tier, demotion shield and promotion/demotion history
+type TierService struct {
+	db      *sql.DB
+	options TierOptions
+	radiant map[string]bool
+	mutex   sync.RWMutex
+	logger  *slog.Logger
+}
+
+func NewTierService(db *sql.DB, options TierOptions, logger *slog.Logger) *TierService {
+	if len(options.Tiers) == 0 {
+		options.Tiers = DefaultTierTable()
+	}
+	tiers := make([]models.RankTier, len(options.Tiers))
+	copy(tiers, options.Tiers)
+	sort.SliceStable(tiers, func(i, j int) bool {
+		return tiers[i].MinRating < tiers[j].MinRating
+	})
+	options.Tiers = tiers
+
+	return &TierService{
+		db:      db,
+		options: options,
+		radiant: make(map[string]bool),
+		logger:  logger,
+	}
+}
+
+// DefaultTierTable returns Iron 1 through Immortal 3, one division every 50 points
+// with Silver 3 at the starting rating of 1000
+func DefaultTierTable() []models.RankTier {
+	names := []string{"Iron", "Bronze", "Silver", "Gold", "Platinum", "Diamond", "Ascendant", "Immortal"}
+
+	var tiers []models.RankTier
+	for i, name := range names {
+		for division := 1; division <= 3; division++ {
+			step := i*3 + division - 1
+			minRating := 600 + 50*step
+			if step == 0 {
+				minRating = 0
+			}
+			tiers = append(tiers, models.RankTier{Name: name, Division: division, MinRating: minRating})
+		}
+	}
+	return tiers
+}
+
+// ParseTierTable reads a tier table from JSON (a list of models.RankTier)
+func ParseTierTable(data []byte) ([]models.RankTier, error) {
+	var tiers []models.RankTier
+	if err := json.Unmarshal(data, &tiers); err != nil {
+		return nil, fmt.Errorf("invalid tier table: %w", err)
+	}
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("tier table is empty")
+	}
+
+	seen := make(map[int]bool)
+	for _, tier := range tiers {
+		if tier.Name == "" {
+			return nil, fmt.Errorf("tier table has a tier without a name")
+		}
+		if seen[tier.MinRating] {
+			return nil, fmt.Errorf("tier table has two tiers starting at %d", tier.MinRating)
+		}
+		seen[tier.MinRating] = true
+	}
+	return tiers, nil
+}
+
+// Tiers returns the tier table from lowest to highest
+func (ts *TierService) Tiers() []models.RankTier {
+	return ts.options.Tiers
+}
+
+// TierIndex returns the position in the tier table of a rating
+func (ts *TierService) TierIndex(rating int) int {
+	index := 0
+	for i, tier := range ts.options.Tiers {
+		if rating >= tier.MinRating {
+			index = i
+		}
+	}
+	return index
+}
+
+// TierAt returns the tier at a position of the tier table
+func (ts *TierService) TierAt(index int) models.PlayerTier {
+	if index < 0 || index >= len(ts.options.Tiers) {
+		return models.PlayerTier{Tier: models.TierUnranked, Label: models.TierUnranked}
+	}
+	tier := ts.options.Tiers[index]
+	return models.PlayerTier{Tier: tier.Name, Division: tier.Division, Label: tier.Label()}
+}
+
+// TierFor returns the tier shown for a player. storedIndex is the tier kept
+// in the database (which may lag the rating while a demotion shield holds),
+// or -1 to derive it from rating.
+func (ts *TierService) TierFor(userID string, rating, storedIndex int, provisional bool) models.PlayerTier {
+	if provisional {
+		return ts.TierAt(-1)
+	}
+
+	ts.mutex.RLock()
+	isRadiant := ts.radiant[userID]
+	ts.mutex.RUnlock()
+	if isRadiant {
+		return models.PlayerTier{Tier: models.TierRadiant, Label: models.TierRadiant}
+	}
+
+	if storedIndex < 0 {
+		storedIndex = ts.TierIndex(rating)
+	}
+	return ts.TierAt(storedIndex)
+}
+
+// GetPlayerTier loads a player's rating and stored tier and returns the tier shown for them
+func (ts *TierService) GetPlayerTier(userID string, provisional bool) (models.PlayerTier, error) {
+	if ts.db == nil || provisional {
+		return ts.TierAt(-1), nil
+	}
+
+	var rating, storedIndex int
+	err := ts.db.QueryRow(`SELECT elo, COALESCE(rank_tier, -1) FROM users WHERE id::text = $1`, userID).Scan(&rating, &storedIndex)
+	if err != nil {
+		return ts.TierAt(-1), fmt.Errorf("failed to load tier for %s: %w", userID, err)
+	}
+	return ts.TierFor(userID, rating, storedIndex, false), nil
+}
+
+// radiantMinRating is the lowest rating of the top tier (e.g. Immortal 1)
+func (ts *TierService) radiantMinRating() int {
+	top := ts.options.Tiers[len(ts.options.Tiers)-1]
+	minRating := top.MinRating
+	for _, tier := range ts.options.Tiers {
+		if tier.Name == top.Name && tier.MinRating < minRating {
+			minRating = tier.MinRating
+		}
+	}
+	return minRating
+}
+
+// RefreshRadiant reloads the top-N ranked players shown as Radiant
+func (ts *TierService) RefreshRadiant() error {
+	if ts.db == nil || ts.options.RadiantSlots <= 0 {
+		return nil
+	}
+
+	rows, err := ts.db.Query(`
+        SELECT id::text FROM users
+        WHERE elo >= $1 AND COALESCE(games_played, 0) >= $2
+        ORDER BY elo DESC, id
+        LIMIT $3
+    `, ts.radiantMinRating(), ts.options.PlacementMatches, ts.options.RadiantSlots)
+	if err != nil {
+		return fmt.Errorf("failed to load radiant players: %w", err)
+	}
+	defer rows.Close()
+
+	radiant := make(map[string]bool)
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return fmt.Errorf("failed to scan radiant player: %w", err)
+		}
+		radiant[userID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	ts.mutex.Lock()
+	ts.radiant = radiant
+	ts.mutex.Unlock()
+	return nil
+}
+
+// ResetTiers stores, as part of tx, the tier matching the current rating of
+// every placed player in userIDs, or of every placed player if userIDs is
+// nil, and drops their demotion shield. It is called with every rating change
+// made outside a match, such as decay and the season soft reset: shields only
+// absorb match losses. Call RefreshRadiant once tx is committed.
+func (ts *TierService) ResetTiers(tx *sql.Tx, userIDs []string) error {
+	minRatings := make([]int64, len(ts.options.Tiers))
+	for i, tier := range ts.options.Tiers {
+		minRatings[i] = int64(tier.MinRating)
+	}
+
+	// The index of the highest tier starting at or below the rating, as TierIndex
+	_, err := tx.Exec(`
+        UPDATE users
+        SET rank_tier = GREATEST((SELECT COUNT(*) FROM unnest($1::int[]) AS tiers(min_rating) WHERE min_rating <= users.elo) - 1, 0),
+            demotion_shield = 0
+        WHERE rank_tier IS NOT NULL AND ($2::text[] IS NULL OR id::text = ANY($2))
+    `, pq.Array(minRatings), pq.Array(userIDs))
+	if err != nil {
+		return fmt.Errorf("failed to reset tiers: %w", err)
+	}
+	return nil
+}
+
+// ApplyRatingChanges updates the stored tier of every rated player after a
+// match and returns the promotion, demotion and shield events it produced.
+// A promotion grants ShieldGames games during which drops below the new tier
+// are absorbed instead of demoting the player.
+func (ts *TierService) ApplyRatingChanges(matchID string, ratings []models.Elo) ([]models.TierEvent, error) {
+	events := []models.TierEvent{}
+	if ts.db == nil || len(ratings) == 0 {
+		return events, nil
+	}
+
+	userIDs := make([]string, len(ratings))
+	for i, rating := range ratings {
+		userIDs[i] = rating.UserID
+	}
+
+	type tierState struct {
+		index  int
+		shield int
+	}
+	states := make(map[string]tierState)
+	rows, err := ts.db.Query(`
+        SELECT id::text, COALESCE(rank_tier, -1), COALESCE(demotion_shield, 0)
+        FROM users WHERE id::text = ANY($1)
+    `, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tiers: %w", err)
+	}
+	for rows.Next() {
+		var userID string
+		var state tierState
+		if err := rows.Scan(&userID, &state.index, &state.shield); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan tier: %w", err)
+		}
+		states[userID] = state
+	}
+	rows.Close()
+
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start tier transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, rating := range ratings {
+		state, exists := states[rating.UserID]
+		if !exists || rating.IsProvisional(ts.options.PlacementMatches) {
+			continue
+		}
+
+		newIndex := ts.TierIndex(int(math.Round(rating.Rating)))
+		event := models.TierEvent{
+			UserID:    rating.UserID,
+			MatchID:   matchID,
+			FromTier:  ts.TierAt(state.index).Label,
+			ToTier:    ts.TierAt(newIndex).Label,
+			CreatedAt: now,
+		}
+
+		switch {
+		case state.index < 0:
+			event.Type = models.TierEventPlaced
+			state = tierState{index: newIndex}
+		case newIndex > state.index:
+			event.Type = models.TierEventPromotion
+			state = tierState{index: newIndex, shield: ts.options.ShieldGames}
+		case newIndex < state.index && state.shield > 0:
+			event.Type = models.TierEventShielded
+			event.ToTier = event.FromTier
+			state.shield--
+		case newIndex < state.index:
+			event.Type = models.TierEventDemotion
+			state = tierState{index: newIndex}
+		default:
+			if state.shield > 0 {
+				state.shield--
+			}
+		}
+
+		_, err := tx.Exec(`UPDATE users SET rank_tier = $2, demotion_shield = $3 WHERE id::text = $1`,
+			rating.UserID, state.index, state.shield)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save tier for %s: %w", rating.UserID, err)
+		}
+
+		if event.Type == "" {
+			continue
+		}
+		event.ShieldGamesLeft = state.shield
+
+		_, err = tx.Exec(`
+            INSERT INTO tier_events (user_id, match_id, event_type, from_tier, to_tier, shield_games_left, created_at)
+            SELECT id, $2, $3, $4, $5, $6, $7 FROM users WHERE id::text = $1
+        `, event.UserID, event.MatchID, event.Type, event.FromTier, event.ToTier, event.ShieldGamesLeft, event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save tier event for %s: %w", rating.UserID, err)
+		}
+
+		ts.logger.Info("tier changed", "event", event.Type, "user_id", event.UserID,
+			"from_tier", event.FromTier, "to_tier", event.ToTier, "match_id", matchID)
+		events = append(events, event)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tiers: %w", err)
+	}
+
+	if err := ts.RefreshRadiant(); err != nil {
+		ts.logger.Warn("could not refresh radiant players", "error", err)
+	}
+
+	return events, nil
+}
@@ -26,15 +26,23 @@ export type MatchStatus =
   | 'completed'         // Match finished
   | 'disputed';         // Result disputed
 
-export type CaptainSelectionMethod = 'voting' | 'random' | '';
+export type CaptainSelectionMethod = 'voting' | 'random' | 'auto_balance' | '';
+
+export interface CaptainVotingRules {
+  duration_seconds: number;
+  allow_self_vote: boolean;
+  max_candidates: number; // 0 means every player is a candidate
+}
 
 export interface MatchPlayer {
   user_id: string;
   username: string;
   elo: number;
   accepted: boolean;
+  party_id?: string;
   team?: string;      // "A" or "B"
   role?: string;      // "captain" or "player"
+  riot_id?: string;   // Verified "name#tag"
 }
 
 export interface Match {
@@ -48,15 +56,59 @@ export interface Match {
     captain_selection_method: CaptainSelectionMethod;
     captain_votes: { [key: string]: string };
     captain_candidates: string[];
+    captain_voting_rules?: CaptainVotingRules;
+    captain_voting_deadline?: string;
+    captain_vote_seed?: number;
+    team1_win_probability?: number;
+    team2_win_probability?: number;
     selected_map?: string;
     banned_maps?: string[];
+    lobby?: MatchLobby;
+    substitute_offers?: SubstituteOffer[];
+    substitutions?: Substitution[];
     winner?: string;
+    result?: MatchResult;
     start_time: string;
     expire_time: string;
     created_at: string;
     updated_at: string;
 }
 
+// The party code is only returned by the lobby endpoint, to match players
+export interface MatchLobby {
+    host_id: string;
+    code_posted_at?: string;
+    join_deadline?: string;
+    check_ins: { [userId: string]: string };
+    no_shows?: string[];
+}
+
+export interface SubstituteOffer {
+    leaver_id: string;
+    candidate_id: string;
+    requested_by?: string;
+    offered_at: string;
+    expires_at: string;
+    declined?: string[];
+}
+
+export interface Substitution {
+    leaver_id: string;
+    leaver_username: string;
+    substitute_id: string;
+    team: 'team1' | 'team2';
+    requested_by?: string;
+    substituted_at: string;
+}
+
+export interface MatchResult {
+    winner: 'team1' | 'team2' | 'tie';
+    team1_rounds: number;
+    team2_rounds: number;
+    reported_by: string;
+    reported_at: string;
+}
+
 export interface MatchRoom extends Match {
     // Alias for clarity in match room context
 }
@@ -69,6 +121,13 @@ export interface QueueData {
     max_players?: number;
     is_queue_full?: boolean;
     should_create_match?: boolean;
+    substitutes_waiting?: number;
+}
+
+export interface PlayerTier {
+    tier: string;
+    division?: number;
+    label: string;
 }
 
 export interface LeaderboardEntry {
@@ -76,8 +135,126 @@ export interface LeaderboardEntry {
     username: string;
     elo: number;
     rank?: string;
+    tier?: PlayerTier;
     wins?: number;
     losses?: number;
+    metric?: LeaderboardMetric;
+}
+
+export interface LeaderboardMetric {
+    name: string;
+    value: number;
+    games: number;
+}
+
+export interface Friend {
+    user_id: string;
+    username: string;
+    elo: number;
+    since: string;
+}
+
+export interface LeaderboardPage {
+    leaderboard: LeaderboardEntry[];
+    total: number;
+    sort: 'elo' | 'conservative';
+    limit?: number;
+    offset?: number;
+    next_cursor?: string;
+    around?: string;
+    rank?: number;
+    range?: number;
+    name?: string;
+    filters?: Record<string, string>;
+}
+
+export interface ArchivedMatchPlayer {
+    user_id: string;
+    username: string;
+    team: 'team1' | 'team2';
+    role?: string;
+    outcome: 'win' | 'loss' | 'draw';
+    rating_before: number;
+    rating_after: number;
+    rating_delta: number;
+    stats?: PlayerMatchStats;
+}
+
+export interface PlayerMatchStats {
+    user_id: string;
+    agent: string;
+    kills: number;
+    deaths: number;
+    assists: number;
+    acs: number;
+    headshot_percent: number;
+    first_bloods: number;
+    submitted_by?: string;
+    submitted_at?: string;
+}
+
+export interface MapStats {
+    map: string;
+    played: number;
+    wins: number;
+    losses: number;
+    draws: number;
+    win_rate: number;
+}
+
+export interface CareerStats {
+    season_id?: number;
+    matches_played: number;
+    matches_with_stats: number;
+    kills: number;
+    deaths: number;
+    assists: number;
+    kd: number;
+    average_acs: number;
+    headshot_percent: number;
+    first_bloods: number;
+    most_played_agent?: string;
+    maps: MapStats[];
+}
+
+export interface ArchivedMatch {
+    id: string;
+    season_id?: number;
+    selected_map: string;
+    banned_maps: string[];
+    captain1: string;
+    captain2: string;
+    captain_selection_method: string;
+    result: MatchResult;
+    team1_win_probability: number;
+    players: ArchivedMatchPlayer[];
+    started_at: string;
+    completed_at: string;
+}
+
+export interface RatingHistoryEntry {
+    id: string;
+    user_id: string;
+    reason: 'match' | 'decay' | 'adjustment' | 'season_reset';
+    rating_before: number;
+    rating_after: number;
+    delta: number;
+    deviation_before?: number;
+    deviation_after?: number;
+    match_id?: string;
+    note?: string;
+    created_at: string;
+}
+
+export interface RiotLink {
+    user_id: string;
+    riot_id: string;
+    game_name: string;
+    tag_line: string;
+    region: 'na' | 'eu' | 'ap' | 'kr' | 'latam' | 'br';
+    verified: boolean;
+    verified_at?: string;
+    verification_code?: string; // Only shown to the owner while pending
 }
 
 export interface AuthContextType {